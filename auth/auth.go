@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -19,14 +20,20 @@ import (
 
 type singleUseTokens struct {
 	mutex  *sync.RWMutex
-	tokens map[string]time.Time
+	tokens map[string]singleUseToken
 	maxAge float64 // max age in seconds
 }
 
+// singleUseToken holds the creation time of a token, and the user who issued it (empty if unknown)
+type singleUseToken struct {
+	created time.Time
+	issuer  string
+}
+
 // NB! not thread safe -- mutex should be locked before calling
 func (sit *singleUseTokens) purge() {
-	for token, created := range sit.tokens {
-		if time.Since(created).Seconds() > sit.maxAge {
+	for token, info := range sit.tokens {
+		if time.Since(info.created).Seconds() > sit.maxAge {
 			delete(sit.tokens, token)
 			log.Printf("Expired token: %s", token)
 		}
@@ -34,9 +41,16 @@ func (sit *singleUseTokens) purge() {
 	log.Printf("Purged single use tokens db")
 }
 
+// ErrUserDisabled is returned by Login for users with a disabled account
+var ErrUserDisabled = errors.New("user account is disabled")
+
+// ErrPasswordChangeRequired is returned by Login for users who have to change password before logging in (see ChangePassword)
+var ErrPasswordChangeRequired = errors.New("password change required")
+
 // Auth struct for authentication management, using a user database along with sessions and cookies
 type Auth struct {
 	sessionName     string
+	db              *userdb.DB
 	userDB          *userdb.UserDB
 	roleDB          *userdb.RoleDB
 	cookieStore     *sessions.CookieStore
@@ -52,14 +66,16 @@ func NewAuth(sessionName string, userDB *userdb.UserDB, roleDB *userdb.RoleDB, c
 		cookieStore: cookieStore,
 		singleUseTokens: singleUseTokens{
 			mutex:  &sync.RWMutex{},
-			tokens: make(map[string]time.Time),
+			tokens: make(map[string]singleUseToken),
 			maxAge: 86400 * 7, // one week in seconds
 		},
 	}
-	err := userdb.Validate(res.userDB, res.roleDB)
+	db, err := userdb.NewDB(res.userDB, res.roleDB)
 	if err != nil {
 		return res, fmt.Errorf("userdb/roledb validation failed : %v", err)
 	}
+	db.AddDependent(tokenDependent{tokens: &res.singleUseTokens})
+	res.db = db
 	return res, nil
 }
 
@@ -71,6 +87,12 @@ func (a *Auth) Login(w http.ResponseWriter, r *http.Request, userName, password
 		return fmt.Errorf("login failed : %v", err)
 	}
 	if ok {
+		if disabled, susp := a.userDB.IsDisabled(userName); disabled {
+			return fmt.Errorf("login failed : %w : %v", ErrUserDisabled, susp)
+		}
+		if required, reason := a.userDB.PasswordChangeRequired(userName); required {
+			return fmt.Errorf("login failed : %w : %s", ErrPasswordChangeRequired, reason)
+		}
 		session, err := a.cookieStore.Get(r, a.sessionName)
 		if err != nil {
 			return fmt.Errorf("couldn't get session : %v", err)
@@ -85,17 +107,42 @@ func (a *Auth) Login(w http.ResponseWriter, r *http.Request, userName, password
 
 		// Set user as authenticated
 		session.Values["authenticated-user"] = userName
+		session.Values["authenticated-time"] = time.Now().UnixNano()
 		session.Save(r, w)
 		return nil
 	}
 	return fmt.Errorf("login failed")
 }
 
+// ChangePassword changes the password for the specified user, if the old password is correct. The new password is validated according to the user database's password policy.
+func (a *Auth) ChangePassword(userName, oldPassword, newPassword string) error {
+	ok, err := a.userDB.Authorized(userName, oldPassword)
+	if err != nil {
+		return fmt.Errorf("password change failed : %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("password change failed")
+	}
+	if disabled, susp := a.userDB.IsDisabled(userName); disabled {
+		return fmt.Errorf("password change failed : %w : %v", ErrUserDisabled, susp)
+	}
+	err = a.userDB.UpdatePassword(userName, newPassword)
+	if err != nil {
+		return fmt.Errorf("password change failed : %w", err)
+	}
+	return nil
+}
+
 // CreateSingleUseToken creates a single use token, useful for signup invitations
 func (a *Auth) CreateSingleUseToken() (string, error) {
+	return a.CreateSingleUseTokenFor("")
+}
+
+// CreateSingleUseTokenFor creates a single use token issued by the specified user, useful for signup invitations. Unused tokens are removed if the issuing user is deleted.
+func (a *Auth) CreateSingleUseTokenFor(issuer string) (string, error) {
 	token := uuid.New().String()
-	a.singleUseTokens.mutex.RLock()
-	defer a.singleUseTokens.mutex.RUnlock()
+	a.singleUseTokens.mutex.Lock()
+	defer a.singleUseTokens.mutex.Unlock()
 
 	a.singleUseTokens.purge()
 
@@ -105,25 +152,28 @@ func (a *Auth) CreateSingleUseToken() (string, error) {
 		//http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return "", err
 	}
-	a.singleUseTokens.tokens[token] = time.Now()
+	if issuer != "" {
+		_, issuer = a.userDB.UserExists(issuer)
+	}
+	a.singleUseTokens.tokens[token] = singleUseToken{created: time.Now(), issuer: issuer}
 	return token, nil
 }
 
 // SignupUser creates a new user with the specified userName and password, using the specified token. If the signup is successful, the token will be consumed.
 func (a *Auth) SignupUser(userName, password, singleUseToken string) error {
-	a.singleUseTokens.mutex.RLock()
-	defer a.singleUseTokens.mutex.RUnlock()
+	a.singleUseTokens.mutex.Lock()
+	defer a.singleUseTokens.mutex.Unlock()
 
 	a.singleUseTokens.purge()
 
 	// verify token
-	created, tokenExists := a.singleUseTokens.tokens[singleUseToken]
+	info, tokenExists := a.singleUseTokens.tokens[singleUseToken]
 	if !tokenExists {
 		err := fmt.Errorf("invalid token : %s", singleUseToken)
 		log.Println(err)
 		return err
 	}
-	if time.Since(created).Seconds() > a.singleUseTokens.maxAge {
+	if time.Since(info.created).Seconds() > a.singleUseTokens.maxAge {
 		delete(a.singleUseTokens.tokens, singleUseToken)
 		err := fmt.Errorf("expired token: %s", singleUseToken)
 		log.Println(err)
@@ -132,7 +182,7 @@ func (a *Auth) SignupUser(userName, password, singleUseToken string) error {
 
 	err := a.userDB.InsertUser(userName, password)
 	if err != nil {
-		err := fmt.Errorf("signup failed : %v", err)
+		err := fmt.Errorf("signup failed : %w", err)
 		log.Println(err)
 		return err
 	}
@@ -163,7 +213,18 @@ func (a *Auth) IsLoggedIn(r *http.Request) (bool, string) {
 		return false, ""
 	}
 	if auth, ok := session.Values["authenticated-user"].(string); ok && auth != "" {
-		return a.userDB.UserExists(auth)
+		exists, userName := a.userDB.UserExists(auth)
+		if !exists {
+			return false, ""
+		}
+		if disabled, _ := a.userDB.IsDisabled(userName); disabled {
+			return false, ""
+		}
+		created, _ := session.Values["authenticated-time"].(int64)
+		if !a.userDB.SessionValid(userName, time.Unix(0, created)) {
+			return false, ""
+		}
+		return true, userName
 	}
 	return false, ""
 }
@@ -236,6 +297,48 @@ func (a *Auth) ListUsers() []string {
 	return a.userDB.GetUsers()
 }
 
+// DeleteUser deletes a user, along with the user's roles, sessions and unused invitation tokens. If dryRun is true, nothing is deleted, but the returned report lists what would have been removed.
+func (a *Auth) DeleteUser(userName string, dryRun bool) (userdb.Report, error) {
+	return a.db.DeleteUser(userName, dryRun)
+}
+
+// RenameUser changes the user name of a user, and updates the user's roles. Sessions for the old user name are invalidated. If dryRun is true, nothing is changed, but the returned report lists what would have been updated.
+func (a *Auth) RenameUser(oldName, newName string, dryRun bool) (userdb.Report, error) {
+	return a.db.RenameUser(oldName, newName, dryRun)
+}
+
+// DisableUser disables a user account, and invalidates the user's sessions. The reason is optional. If until is non-zero, the account is automatically re-enabled at that time.
+func (a *Auth) DisableUser(userName, reason string, until time.Time) error {
+	err := a.userDB.DisableUser(userName, reason, until)
+	if err != nil {
+		return err
+	}
+	return a.userDB.RevokeSessions(userName)
+}
+
+// EnableUser re-enables a disabled user account
+func (a *Auth) EnableUser(userName string) error {
+	return a.userDB.EnableUser(userName)
+}
+
+// SetUserAttribute sets a profile attribute of a user, such as gender (see userdb.UserDB.SetAttribute). An empty value removes the attribute.
+func (a *Auth) SetUserAttribute(userName, name, value string) error {
+	return a.userDB.SetAttribute(userName, name, value)
+}
+
+// UserAttributes returns the profile attributes of the logged-in user, if any. It can be used to choose between select variants of translations (see i18n.UserAttributes).
+func (a *Auth) UserAttributes(r *http.Request) (map[string]string, bool) {
+	ok, userName := a.IsLoggedIn(r)
+	if !ok {
+		return nil, false
+	}
+	attrs, err := a.userDB.Attributes(userName)
+	if err != nil {
+		return nil, false
+	}
+	return attrs, true
+}
+
 // SaveUserDB save user database to disk
 func (a *Auth) SaveUserDB() error {
 	return a.userDB.SaveFile()
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+)
+
+// tokenDependent exposes the single use tokens issued by a user (e.g. signup invitations) to userdb.DB
+type tokenDependent struct {
+	tokens *singleUseTokens
+}
+
+func (td tokenDependent) Name() string {
+	return "invitation tokens"
+}
+
+func (td tokenDependent) ListUserRecords(userName string) []string {
+	td.tokens.mutex.RLock()
+	defer td.tokens.mutex.RUnlock()
+	res := []string{}
+	for token, info := range td.tokens.tokens {
+		if info.issuer == userName {
+			res = append(res, fmt.Sprintf("%s (created %s)", token, info.created.Format("2006-01-02 15:04:05 MST")))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// DeleteUserRecords removes the unused tokens issued by the user
+func (td tokenDependent) DeleteUserRecords(userName string) error {
+	td.tokens.mutex.Lock()
+	defer td.tokens.mutex.Unlock()
+	for token, info := range td.tokens.tokens {
+		if info.issuer == userName {
+			delete(td.tokens.tokens, token)
+		}
+	}
+	return nil
+}
+
+// RenameUserRecords moves the unused tokens issued by the user to the new user name
+func (td tokenDependent) RenameUserRecords(oldName, newName string) error {
+	td.tokens.mutex.Lock()
+	defer td.tokens.mutex.Unlock()
+	for token, info := range td.tokens.tokens {
+		if info.issuer == oldName {
+			info.issuer = newName
+			td.tokens.tokens[token] = info
+		}
+	}
+	return nil
+}
@@ -1,39 +1,278 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/stts-se/weblib/i18n"
 )
 
+var extractFlags = flag.NewFlagSet("extract", flag.ExitOnError)
+var extractSource = extractFlags.String("source", "en", "source `locale`, i.e., the locale of the keys in the code")
+var extractUpdate = extractFlags.Bool("update", false, "add missing keys to the property files, next to the keys around them in the code (existing lines are kept in order)")
+var extractOutput = extractFlags.String("o", "", "write the extracted keys to `file`, in any catalog format (e.g. messages.pot)")
+var extractReceivers = extractFlags.String("receivers", strings.Join(i18n.ExtractReceivers, ","), "comma separated `list` of the names of the variables, fields and methods holding an I18N instance in Go code (as loc in loc.S(\"Login\"))")
+
+var suggestFlags = flag.NewFlagSet("suggest", flag.ExitOnError)
+var suggestOutput = suggestFlags.String("o", ".", "output `folder` for the suggestion files (<locale>_suggestions.txt)")
+var suggestMinScore = suggestFlags.Float64("min", 0.5, "minimum similarity `score` (0-1) of suggested keys")
+var suggestMax = suggestFlags.Int("n", 3, "max `number` of suggestions per missing key (0 for no limit)")
+
+var statsFlags = flag.NewFlagSet("stats", flag.ExitOnError)
+var statsDefault = statsFlags.String("default", "en", "default `locale`, that the other locales are compared with")
+var statsJSON = statsFlags.Bool("json", false, "print the stats as JSON")
+
+var syntax = flag.String("syntax", "tab", "property file `syntax`: tab (key<TAB>value) or java (standard Java .properties)")
+var report = flag.String("report", i18n.ReportText, "cross validation report `format`: text, json or junit (written to stdout)")
+var severities = flag.String("severity", "", "comma separated `list` of severities (error, warning or ignore) by kind of finding, e.g. key-order=ignore,item-count=error")
+var ref = flag.String("ref", "", "reference `locale` for cross validation (default: the first locale in alphabetical order)")
+var fix = flag.Bool("fix", false, "reorder the keys in the property files to match the reference locale, before validating")
+
 func printHelp() {
-	fmt.Fprintf(os.Stderr, "Cmd line validation for i18n property files\n")
-	fmt.Fprintf(os.Stderr, "Usage: i18n <i18n files>\n")
+	fmt.Fprintf(os.Stderr, "Cmd line tools for i18n files\n")
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> <i18n files>                 validate i18n files\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> <i18n dir>                   validate i18n dir (including namespace subfolders)\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> convert <input> <output>     convert between file formats\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> extract <extract options> <i18n folder> <source files/folders>\n")
+	fmt.Fprintf(os.Stderr, "                                              extract keys from Go code and templates, and compare them to the i18n files\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> suggest <suggest options> <i18n dir>\n")
+	fmt.Fprintf(os.Stderr, "                                              suggest similar translated keys for the keys missing in each locale\n")
+	fmt.Fprintf(os.Stderr, "  i18n <options> stats <stats options> <i18n dir>\n")
+	fmt.Fprintf(os.Stderr, "                                              print the translation coverage of each locale\n")
+	fmt.Fprintf(os.Stderr, "Formats (selected by file extension):\n")
+	for _, f := range i18n.CatalogFormats() {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", f.Extension, f.Name)
+	}
+	fmt.Fprintf(os.Stderr, "Kinds of cross validation findings (default severity):\n")
+	for _, kind := range i18n.FindingKinds {
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", kind, i18n.DefaultSeverities[kind])
+	}
+	fmt.Fprintf(os.Stderr, "Validation exits with status 1 if there are findings with severity error.\n")
+	fmt.Fprintf(os.Stderr, "Options:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Extract options:\n")
+	extractFlags.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Suggest options:\n")
+	suggestFlags.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Stats options:\n")
+	statsFlags.PrintDefaults()
 }
 
-func main() {
-	if len(os.Args) < 2 {
+func printParseErrors(err error) {
+	if errs, ok := err.(i18n.ParseErrors); ok {
+		fmt.Fprintf(os.Stderr, "Parse errors\n")
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%v\n", e)
+		}
+		log.Fatal("Parsing failed")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func validate(syn i18n.Syntax, files []string) {
+	sevs, err := i18n.ParseSeverities(*severities)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir := filepath.Dir(files[0])
+	isDir := false
+	if fi, err := os.Stat(files[0]); err == nil && fi.IsDir() && len(files) == 1 {
+		dir, isDir = files[0], true
+	}
+	db := i18n.NewI18NDB(dir, "")
+	db.Syntax = syn
+	db.Severities = sevs
+	db.ReferenceLocale = *ref
+	load := func() {
+		if isDir {
+			printParseErrors(db.Load())
+		} else {
+			printParseErrors(db.LoadFiles(files))
+		}
+	}
+	load()
+	if *fix {
+		fixed, err := db.FixKeyOrder()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Fixed key order in %d files\n", len(fixed))
+		if len(fixed) > 0 {
+			load()
+		}
+	}
+	crossValidate(db)
+}
+
+func crossValidate(db *i18n.I18NDB) {
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := i18n.WriteFindings(os.Stdout, findings, *report); err != nil {
+		log.Fatal(err)
+	}
+	if i18n.HasErrors(findings) {
+		log.Fatal("Cross validation failed")
+	}
+}
+
+func convert(syn i18n.Syntax, input, output string) {
+	cat, err := i18n.ReadCatalog(input, syn)
+	printParseErrors(err)
+	if err := cat.WriteFile(output, syn); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "Converted %d entries from %s to %s\n", len(cat.Entries), input, output)
+}
+
+func extract(syn i18n.Syntax, args []string) {
+	extractFlags.Parse(args)
+	args = extractFlags.Args()
+	if len(args) < 2 {
 		printHelp()
-		os.Exit(0)
+		os.Exit(1)
 	}
+	dir, paths := args[0], args[1:]
 
-	dir := filepath.Dir(os.Args[1])
-	db, err := i18n.ReadI18NPropFiles(dir, os.Args[1:], "")
+	i18n.ExtractReceivers = []string{}
+	for _, r := range strings.Split(*extractReceivers, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			i18n.ExtractReceivers = append(i18n.ExtractReceivers, r)
+		}
+	}
+	keys, err := i18n.Extract(paths)
 	if err != nil {
 		log.Fatal(err)
 	}
-	msgs, err := db.CrossValidate()
+	for _, k := range keys {
+		fmt.Println(k.Key)
+	}
+	fmt.Fprintf(os.Stderr, "Extracted %d keys from %v\n", len(keys), paths)
+	if *extractOutput != "" {
+		if err := i18n.NewExtractedCatalog(*extractSource, keys).WriteFile(*extractOutput, syn); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	db := i18n.NewI18NDB(dir, *extractSource)
+	db.Syntax = syn
+	printParseErrors(db.Load())
+	for _, rep := range db.CompareKeys(keys) {
+		for _, k := range rep.Missing {
+			fmt.Fprintf(os.Stderr, "Missing key in %s\t%s\t%s\n", rep.Locale, k.Key, strings.Join(k.Positions, " "))
+		}
+		for _, k := range rep.Unused {
+			fmt.Fprintf(os.Stderr, "Unused key in %s\t%s\n", rep.Locale, k)
+		}
+		if !*extractUpdate || len(rep.Missing) == 0 {
+			continue
+		}
+		if filepath.Ext(rep.File) != ".properties" {
+			fmt.Fprintf(os.Stderr, "Can't add keys to %s (only property files can be updated)\n", rep.File)
+			continue
+		}
+		if err := i18n.AddKeys(rep.File, rep.Locale, keys, rep.Missing, syn, rep.Locale == *extractSource); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Added %d keys to %s\n", len(rep.Missing), rep.File)
+	}
+}
+
+func suggest(syn i18n.Syntax, args []string) {
+	suggestFlags.Parse(args)
+	args = suggestFlags.Args()
+	if len(args) != 1 {
+		printHelp()
+		os.Exit(1)
+	}
+	db := i18n.NewI18NDB(args[0], "")
+	db.Syntax = syn
+	db.ReferenceLocale = *ref
+	printParseErrors(db.Load())
+
+	reps := db.Suggest(*suggestMinScore, *suggestMax)
+	for _, rep := range reps {
+		fmt.Fprintf(os.Stderr, "Missing %d keys in %s, with suggestions for %d keys\n", len(rep.Missing), rep.Locale, len(rep.Suggestions))
+	}
+	files, err := i18n.WriteSuggestionFiles(*suggestOutput, reps)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if len(msgs) > 0 {
-		fmt.Fprintf(os.Stderr, "Cross validation errors\n")
-		for _, msg := range msgs {
-			fmt.Fprintf(os.Stderr, "%s\n", msg)
+	for _, f := range files {
+		fmt.Fprintf(os.Stderr, "Wrote suggestions to %s\n", f)
+	}
+}
+
+func stats(syn i18n.Syntax, args []string) {
+	statsFlags.Parse(args)
+	args = statsFlags.Args()
+	if len(args) != 1 {
+		printHelp()
+		os.Exit(1)
+	}
+	db := i18n.NewI18NDB(args[0], *statsDefault)
+	db.Syntax = syn
+	printParseErrors(db.Load())
+
+	res := db.Stats()
+	if *statsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			log.Fatal(err)
 		}
-		log.Fatal("Cross validation failed")
+		return
+	}
+	fmt.Printf("%-10s %6s %10s %9s %7s %8s  %s\n", "locale", "keys", "translated", "identical", "missing", "coverage", "modified")
+	for _, st := range res {
+		modified := ""
+		if !st.Modified.IsZero() {
+			modified = st.Modified.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-10s %6d %10d %9d %7d %7.1f%%  %s\n", st.Locale, st.Keys, st.Translated, st.Identical, st.Missing, 100*st.Coverage, modified)
+	}
+}
+
+func main() {
+	flag.Usage = printHelp
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 {
+		printHelp()
+		os.Exit(0)
+	}
+
+	syn, err := i18n.ParseSyntax(*syntax)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "extract":
+		extract(syn, args[1:])
+		return
+	case "stats":
+		stats(syn, args[1:])
+		return
+	case "suggest":
+		suggest(syn, args[1:])
+		return
+	case "convert":
+		if len(args) != 3 {
+			printHelp()
+			os.Exit(1)
+		}
+		convert(syn, args[1], args[2])
+		return
 	}
+	validate(syn, args)
 }
@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -22,6 +24,10 @@ func promptPassword() (string, error) {
 	return password, nil
 }
 
+var roleDBFile = flag.String("r", "", "role `database` (required for delete and rename, so that user changes are propagated to role memberships)")
+var dryRun = flag.Bool("dryrun", false, "list the changes without saving them")
+var breachedPasswords = flag.String("breached", "", "breached passwords `list` to reject, in Have I Been Pwned range format (folder of range files, or a single file)")
+
 func getUserDB(dbFile string) *userdb.UserDB {
 	userDB, err := userdb.ReadUserDB(dbFile)
 	if err != nil {
@@ -34,14 +40,16 @@ func getUserDB(dbFile string) *userdb.UserDB {
 		if len(userName) < 4 {
 			return false, "username must have min 4 chars"
 		}
-		if len(password) == 0 {
-			return false, "empty password"
-		}
-		if len(password) < 4 {
-			return false, "password must have min 4 chars"
-		}
 		return true, ""
 	}
+	userDB.PasswordPolicy = userdb.PasswordPolicy{MinLength: 4, MaxLength: 256, CheckUserName: true}
+	if *breachedPasswords != "" {
+		breached, err := userdb.NewBreachedPasswords(*breachedPasswords)
+		if err != nil {
+			log.Fatalf("Couldn't read breached passwords : %v", err)
+		}
+		userDB.PasswordPolicy.Breached = breached
+	}
 	fmt.Fprintf(os.Stderr, "Loaded user db from file %s\n", dbFile)
 	return userDB
 }
@@ -82,19 +90,117 @@ func insertUser(meta meta, dbFile string, args []string) {
 	}
 }
 
-func deleteUsers(meta meta, dbFile string, args []string) {
+// getDB returns a userdb.DB for the user database and the role database given by -r. The role database is required, so that deleted or renamed users are not left behind in the roles. Changes made through the DB are appended to the database files, so there is no need to save them.
+func getDB(dbFile string, cmdName string) *userdb.DB {
+	if *roleDBFile == "" {
+		log.Fatalf("Command %s requires a role database (-r), or the user would be left in the roles", cmdName)
+	}
 	userDB := getUserDB(dbFile)
+	roleDB, err := userdb.ReadRoleDB(*roleDBFile)
+	if err != nil {
+		log.Fatalf("Could't read role db : %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Loaded role db from file %s\n", *roleDBFile)
+	db, err := userdb.NewDB(userDB, roleDB)
+	if err != nil {
+		log.Fatalf("Invalid user/role db : %v", err)
+	}
+	return db
+}
+
+func deleteUsers(meta meta, dbFile string, args []string) {
+	db := getDB(dbFile, meta.name)
 	userNames := meta.getArgValues(args, "usernames*")
 	for _, userName := range userNames {
-		err := userDB.DeleteUser(userName)
+		report, err := db.DeleteUser(userName, *dryRun)
 		if err != nil {
 			log.Fatalf("Couldn't delete user : %v", err)
 		}
-		fmt.Fprintf(os.Stderr, "Deleted user %s\n", userName)
+		fmt.Fprintf(os.Stderr, "%s\n", report)
 	}
-	err := userDB.SaveFile()
+}
+
+func renameUser(meta meta, dbFile string, args []string) {
+	db := getDB(dbFile, meta.name)
+	userName := meta.getArgValue(args, "username")
+	newName := meta.getArgValue(args, "newname")
+	report, err := db.RenameUser(userName, newName, *dryRun)
 	if err != nil {
-		log.Fatalf("Couldn't save db : %v", err)
+		log.Fatalf("Couldn't rename user : %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", report)
+}
+
+// parseUntil parses a reactivation time, either as a duration (e.g. 72h), a date (YYYY-MM-DD) or an RFC3339 timestamp. The empty string yields the zero time.
+func parseUntil(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func disableUser(meta meta, dbFile string, args []string) {
+	userDB := getUserDB(dbFile)
+	userName := meta.getArgValue(args, "username")
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("Reason (optional): ")
+	reason, err := reader.ReadString('\n')
+	if err != nil {
+		log.Fatalf("Could't read reason from terminal : %v", err)
+	}
+	reason = strings.TrimSpace(reason)
+
+	fmt.Printf("Re-enable after (optional; duration, YYYY-MM-DD or RFC3339): ")
+	untilString, err := reader.ReadString('\n')
+	if err != nil {
+		log.Fatalf("Could't read time from terminal : %v", err)
+	}
+	until, err := parseUntil(strings.TrimSpace(untilString))
+	if err != nil {
+		log.Fatalf("Invalid time : %v", err)
+	}
+
+	err = userDB.DisableUser(userName, reason, until)
+	if err != nil {
+		log.Fatalf("Couldn't disable user : %v", err)
+	}
+	// sessions created before the suspension stay invalid when the user is re-enabled
+	err = userDB.RevokeSessions(userName)
+	if err != nil {
+		log.Fatalf("Couldn't revoke sessions : %v", err)
+	}
+	_, susp := userDB.IsDisabled(userName)
+	fmt.Fprintf(os.Stderr, "Disabled user %s: %v\n", userName, susp)
+}
+
+func enableUsers(meta meta, dbFile string, args []string) {
+	userDB := getUserDB(dbFile)
+	userNames := meta.getArgValues(args, "usernames*")
+	for _, userName := range userNames {
+		err := userDB.EnableUser(userName)
+		if err != nil {
+			log.Fatalf("Couldn't enable user : %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Enabled user %s\n", userName)
+	}
+}
+
+func expirePasswords(meta meta, dbFile string, args []string) {
+	userDB := getUserDB(dbFile)
+	userNames := meta.getArgValues(args, "usernames*")
+	for _, userName := range userNames {
+		err := userDB.RequirePasswordChange(userName)
+		if err != nil {
+			log.Fatalf("Couldn't expire password : %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "User %s has to change password on next login\n", userName)
 	}
 }
 
@@ -118,7 +224,11 @@ func listUsers(meta meta, dbFile string, args []string) {
 	userDB := getUserDB(dbFile)
 	users := userDB.GetUsers()
 	for _, u := range users {
-		fmt.Println(u)
+		if disabled, susp := userDB.IsDisabled(u); disabled {
+			fmt.Printf("%s\t%v\n", u, susp)
+		} else {
+			fmt.Println(u)
+		}
 	}
 	pluralS := "s"
 	if len(users) == 1 {
@@ -144,6 +254,38 @@ var cmds = []cmd{
 		},
 		f: deleteUsers,
 	},
+	{
+		meta: meta{
+			name:     "rename",
+			desc:     "Rename user",
+			argNames: []string{"username", "newname"},
+		},
+		f: renameUser,
+	},
+	{
+		meta: meta{
+			name:     "disable",
+			desc:     "Disable user",
+			argNames: []string{"username"},
+		},
+		f: disableUser,
+	},
+	{
+		meta: meta{
+			name:     "enable",
+			desc:     "Enable users",
+			argNames: []string{"usernames*"},
+		},
+		f: enableUsers,
+	},
+	{
+		meta: meta{
+			name:     "expire",
+			desc:     "Require password change on next login",
+			argNames: []string{"usernames*"},
+		},
+		f: expirePasswords,
+	},
 	{
 		meta: meta{
 			name:     "list",
@@ -232,7 +374,10 @@ func (c cmd) apply(dbFile string, args []string) {
 }
 
 func printHelp() {
-	fmt.Fprintf(os.Stderr, "userdb <dbfile> <command> <args>\n")
+	fmt.Fprintf(os.Stderr, "userdb <options> <dbfile> <command> <args>\n")
+	fmt.Fprintf(os.Stderr, "Options:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Commands:\n")
 	fmt.Fprintf(os.Stderr, " %s\n", "help")
 	for _, c := range cmds {
 		args := []string{}
@@ -245,7 +390,9 @@ func printHelp() {
 }
 
 func main() {
-	args := os.Args[1:]
+	flag.Usage = printHelp
+	flag.Parse()
+	args := flag.Args()
 	if len(args) < 2 || args[0] == "help" {
 		printHelp()
 		os.Exit(0)
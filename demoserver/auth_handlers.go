@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,9 +9,31 @@ import (
 	"strings"
 
 	"github.com/stts-se/weblib/auth"
+	"github.com/stts-se/weblib/i18n"
+	"github.com/stts-se/weblib/userdb"
 	"github.com/stts-se/weblib/util"
 )
 
+// passwordPolicyError writes localized password policy violations to the response, if err is a userdb.PolicyError. Returns false if err is another type of error.
+func passwordPolicyError(w http.ResponseWriter, cli18n *i18n.I18N, err error) bool {
+	var policyErr *userdb.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	msgs := []string{}
+	for _, v := range policyErr.Violations {
+		if len(v.Args) == 1 {
+			if n, ok := v.Args[0].(int); ok {
+				msgs = append(msgs, cli18n.N(v.Message, n))
+				continue
+			}
+		}
+		msgs = append(msgs, cli18n.S(v.Message, v.Args...))
+	}
+	http.Error(w, strings.Join(msgs, "\n"), http.StatusBadRequest)
+	return true
+}
+
 type authHandlers struct {
 	Auth *auth.Auth
 }
@@ -29,11 +52,11 @@ func (a *authHandlers) helloWorld(w http.ResponseWriter, r *http.Request) {
 func (a *authHandlers) message(msg string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cli18n := i18nCache.GetI18NFromRequest(r)
-		msgLoc := cli18n.S(msg)
+		args := map[string]interface{}{}
 		if ok, uName := a.Auth.IsLoggedIn(r); ok {
-			msgLoc = strings.Replace(msgLoc, "${username}", uName, -1)
+			args["username"] = uName
 		}
-		fmt.Fprintf(w, "%s\n", msgLoc)
+		fmt.Fprintf(w, "%s\n", cli18n.M(msg, args))
 	}
 }
 
@@ -57,6 +80,11 @@ func (a *authHandlers) login(w http.ResponseWriter, r *http.Request) {
 		password := form["password"]
 
 		err = a.Auth.Login(w, r, userName, password)
+		if errors.Is(err, auth.ErrPasswordChangeRequired) {
+			log.Printf("Login failed : %v", err)
+			http.Redirect(w, r, fmt.Sprintf("/auth/change_password?username=%s", url.QueryEscape(userName)), http.StatusSeeOther)
+			return
+		}
 		if err != nil {
 			log.Printf("Login failed : %v", err)
 			http.Error(w, "Login failed", http.StatusUnauthorized)
@@ -83,7 +111,8 @@ func (a *authHandlers) invite(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	case "POST":
-		token, err := a.Auth.CreateSingleUseToken()
+		_, userName := a.Auth.IsLoggedIn(r)
+		token, err := a.Auth.CreateSingleUseTokenFor(userName)
 		if err != nil {
 			log.Printf("Couldn't create invitation token : %s", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -91,10 +120,12 @@ func (a *authHandlers) invite(w http.ResponseWriter, r *http.Request) {
 		}
 
 		link := fmt.Sprintf("%s/auth/signup?token=%s", util.GetServerURL(r), url.PathEscape(token))
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		log.Printf("Created invitation link: %s", link)
-		msg := fmt.Sprintf("%s: <a href='%s'>%s</a>\n", cli18n.S("Invitation link"), link, link)
-		fmt.Fprint(w, msg)
+		err = templates.ExecuteTemplate(w, "invitation.html", TemplateData{Loc: cli18n, Data: link})
+		if err != nil {
+			log.Printf("Couldn't execute template : %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 	default:
 		http.NotFound(w, r)
 	}
@@ -137,6 +168,10 @@ func (a *authHandlers) signup(w http.ResponseWriter, r *http.Request) {
 		token := form["token"]
 
 		err = a.Auth.SignupUser(userName, password, token)
+		if passwordPolicyError(w, cli18n, err) {
+			log.Printf("Couldn't create user : %s", err)
+			return
+		}
 		if err != nil {
 			log.Printf("Couldn't create user : %s", err)
 			http.Error(w, "Internal server error", http.StatusUnauthorized)
@@ -152,6 +187,66 @@ func (a *authHandlers) signup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *authHandlers) changePassword(w http.ResponseWriter, r *http.Request) {
+	cli18n := i18nCache.GetI18NFromRequest(r)
+	switch r.Method {
+	case "GET":
+		data := struct{ UserName string }{UserName: util.GetParam(r, "username")}
+		err := templates.ExecuteTemplate(w, "change_password.html", TemplateData{Loc: cli18n, Data: data})
+		if err != nil {
+			log.Printf("Couldn't execute template : %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	case "POST":
+		form, err := util.ParseForm(r, []string{"username", "old_password", "password"})
+		if err != nil {
+			log.Printf("Couldn't parse form : %v", err)
+			http.Error(w, "Incomplete credentials", http.StatusUnauthorized)
+			return
+		}
+		userName := form["username"]
+		err = a.Auth.ChangePassword(userName, form["old_password"], form["password"])
+		if passwordPolicyError(w, cli18n, err) {
+			log.Printf("Couldn't change password : %v", err)
+			return
+		}
+		if err != nil {
+			log.Printf("Couldn't change password : %v", err)
+			http.Error(w, "Password change failed", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Changed password for user %s", userName)
+		msg := cli18n.S("Changed password for user %s", userName) + "\n"
+		fmt.Fprint(w, msg)
+		return
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// genders are the values of the gender attribute accepted by the profile handler (used to choose between select variants of translations)
+var genders = map[string]bool{"female": true, "male": true, "other": true}
+
+// profile sets the gender attribute of the logged-in user (the gender param)
+func (a *authHandlers) profile(w http.ResponseWriter, r *http.Request) {
+	cli18n := i18nCache.GetI18NFromRequest(r)
+	gender := util.GetParam(r, "gender")
+	if !genders[gender] {
+		http.Error(w, cli18n.S("Invalid gender: %s", gender), http.StatusBadRequest)
+		return
+	}
+	_, userName := a.Auth.IsLoggedIn(r)
+	if err := a.Auth.SetUserAttribute(userName, "gender", gender); err != nil {
+		log.Printf("Couldn't set gender for user %s : %v", userName, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// the attributes of the request were resolved before the update, so the new gender is passed explicitly
+	fmt.Fprintf(w, "%s\n", cli18n.Select("Profile saved: you will be referred to as they", map[string]string{"gender": gender}))
+}
+
 func (a *authHandlers) logout(w http.ResponseWriter, r *http.Request) {
 	cli18n := i18nCache.GetI18NFromRequest(r)
 	switch r.Method {
@@ -186,3 +281,23 @@ func (a *authHandlers) listUsers(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "- %s\n", uName)
 	}
 }
+
+func (a *authHandlers) deleteUser(w http.ResponseWriter, r *http.Request) {
+	userName := util.GetParam(r, "username")
+	switch r.Method {
+	case "GET", "POST":
+		dryRun := r.Method == "GET"
+		report, err := a.Auth.DeleteUser(userName, dryRun)
+		if err != nil {
+			log.Printf("Couldn't delete user : %v", err)
+			http.Error(w, "Couldn't delete user", http.StatusBadRequest)
+			return
+		}
+		if !dryRun {
+			log.Printf("Deleted user %s", userName)
+		}
+		fmt.Fprintf(w, "%s\n", report)
+	default:
+		http.NotFound(w, r)
+	}
+}
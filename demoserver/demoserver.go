@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -76,9 +77,11 @@ func main() {
 	serverKeyFile := flags.String("key", "server_config/serverkey", "server key `file` for session cookies")
 	userDBFile := flags.String("u", "", "user `database` (required)")
 	roleDBFile := flags.String("r", "", "role `database` (required)")
+	breachedPasswords := flags.String("breached", "", "breached passwords `list` to reject on signup, in Have I Been Pwned range format (folder of range files, or a single file)")
 
-	i18nDir := flags.String("i18n", "i18n", "i18n translation `folder`")
-	logI18NToTemplate := flags.Bool("i18n-gen", false, fmt.Sprintf("generate i18n templates for all undefined locale/strings processed by i18n (template files are saved to the i18n folder on server shutdown)"))
+	i18nDir := flags.String("i18n", "i18n", "i18n translation `folder` (by default, the i18n files in the working directory override the i18n files embedded in the binary)")
+	i18nWatch := flags.Duration("i18n-watch", 0, "poll the i18n folder for changes at the specified `interval` (e.g. 2s), and reload changed translation files (default disabled)")
+	logI18NToTemplate := flags.Bool("i18n-gen", false, fmt.Sprintf("generate i18n templates for all missing translations processed by i18n (template files are saved to the i18n folder on server shutdown; missing translations are also listed at /admin/i18n/missing)"))
 
 	// go run /usr/local/go/src/crypto/tls/generate_cert.go
 	tlsCert := flags.String("tlsCert", "", "tls certificate `file` (generate with golang's crypto/tls/generate_cert.go) (default disabled)")
@@ -121,10 +124,26 @@ func main() {
 	}
 
 	i18n.LogToTemplate = *logI18NToTemplate
-	err = initI18NPropFiles(*i18nDir)
+	if usedFlags["i18n"] {
+		// only the specified folder is used
+		err = initI18NPropFiles(nil, *i18nDir)
+	} else {
+		err = initI18NPropFiles(defaultFS(), *i18nDir)
+	}
 	if err != nil {
 		log.Fatalf("Couldn't read i18n properties : %v", err)
 	}
+	err = initTemplates(defaultFS())
+	if err != nil {
+		log.Fatalf("Couldn't parse templates : %v", err)
+	}
+	if *i18nWatch > 0 {
+		stopWatch, err := i18nCache.Watch(*i18nWatch, logI18NReload)
+		if err != nil {
+			log.Fatalf("Couldn't watch i18n folder : %v", err)
+		}
+		defer stopWatch()
+	}
 
 	tlsEnabled := false
 	protocol := "http"
@@ -145,12 +164,14 @@ func main() {
 		log.Fatalf("Cookie store init failed : %v", err)
 	}
 
-	userDB, err := initUserDB(*userDBFile)
-	roleDB, err := initRoleDB(*roleDBFile)
-
+	userDB, err := initUserDB(*userDBFile, *breachedPasswords)
 	if err != nil {
 		log.Fatalf("UserDB init failed : %v", err)
 	}
+	roleDB, err := initRoleDB(*roleDBFile)
+	if err != nil {
+		log.Fatalf("RoleDB init failed : %v", err)
+	}
 
 	auth, err := auth.NewAuth("auth-user-weblib", userDB, roleDB, cookieStore)
 	if err != nil {
@@ -158,9 +179,15 @@ func main() {
 	}
 	authHandlers := authHandlers{Auth: auth}
 
+	localeMiddleware := i18n.NewLocaleMiddleware(i18nCache)
+	localeMiddleware.Cookie.Secure = tlsEnabled
+	localeMiddleware.Users = newUserLocales(auth)
+	localeMiddleware.Attributes = auth
+
 	r := mux.NewRouter()
 	r.StrictSlash(true)
 	r.Use(logging)
+	r.Use(localeMiddleware.Handler)
 
 	r.HandleFunc("/", authHandlers.helloWorld)
 	r.HandleFunc("/doc/", simpleDoc(r, make(map[string]string)))
@@ -168,9 +195,11 @@ func main() {
 
 	authR := r.PathPrefix("/auth").Subrouter()
 	authR.HandleFunc("/", authHandlers.message("User authorization"))
-	authR.HandleFunc("/login", auth.ServeAuthUserOrElse(authHandlers.message("You are already logged in as user ${username}"), authHandlers.login))
+	authR.HandleFunc("/login", auth.ServeAuthUserOrElse(authHandlers.message("You are already logged in as user {username}"), authHandlers.login))
 	authR.HandleFunc("/logout", auth.ServeAuthUser(authHandlers.logout))
 	authR.HandleFunc("/signup", authHandlers.signup)
+	authR.HandleFunc("/change_password", authHandlers.changePassword)
+	authR.HandleFunc("/profile", auth.ServeAuthUser(authHandlers.profile))
 
 	protectedR := r.PathPrefix("/protected").Subrouter()
 	auth.RequireAuthUser(protectedR)
@@ -178,6 +207,8 @@ func main() {
 
 	localeR := r.PathPrefix("/locale").Subrouter()
 	localeR.HandleFunc("/list", listLocales)
+	localeR.HandleFunc("/set", localeMiddleware.SetLocaleHandler)
+	localeR.HandleFunc("/stats", i18nCache.StatsHandler)
 	localeR.HandleFunc("/translate/{input}", translate)
 
 	adminR := r.PathPrefix("/admin").Subrouter()
@@ -185,6 +216,8 @@ func main() {
 	adminR.HandleFunc("/", authHandlers.message("Admin area (open for admin users)"))
 	adminR.HandleFunc("/invite", authHandlers.invite)
 	adminR.HandleFunc("/list_users", authHandlers.listUsers)
+	adminR.HandleFunc("/delete_user/{username}", authHandlers.deleteUser)
+	adminR.HandleFunc("/i18n/missing", i18nCache.MissingTranslationsHandler)
 
 	r.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("static/"))))
 
@@ -205,8 +238,8 @@ func main() {
 
 	stop := make(chan os.Signal, 1)
 
-	//signal.Notify(stop, os.Interrupt)
-	signal.Notify(stop) // will exit nicely on Ctrl-C and kill signals
+	// will exit nicely on Ctrl-C and kill signals (not on all signals, since the go runtime uses SIGURG internally)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
 		if tlsEnabled {
 			err = httpSrv.ListenAndServeTLS(*tlsCert, *tlsKey)
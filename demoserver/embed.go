@@ -0,0 +1,19 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// embedded contains the default i18n files and templates, so that the server can be started from any folder
+//
+//go:embed i18n templates
+var embedded embed.FS
+
+// defaultFS returns the file system for the i18n files and templates: files in the working directory (e.g. i18n/sv.properties) override the embedded defaults
+func defaultFS() fs.FS {
+	return util.NewOverlayFS(os.DirFS("."), embedded)
+}
@@ -88,12 +88,12 @@ func listLocales(w http.ResponseWriter, r *http.Request) {
 	cli18n := i18nCache.GetI18NFromRequest(r)
 	msg := cli18n.S("Locales") + "\n"
 	fmt.Fprint(w, msg)
-	for _, loc := range i18nCache.ListLocales() {
-		if loc == i18nCache.DefaultLocale {
-			fmt.Fprintf(w, "- %s (default)\n", loc)
-		} else {
-			fmt.Fprintf(w, "- %s\n", loc)
+	for _, loc := range i18nCache.ListLocaleInfo() {
+		info := loc.Direction
+		if loc.Default {
+			info += ", default"
 		}
+		fmt.Fprintf(w, "- %s: %s (%s)\n", loc.Locale, loc.Name, info)
 	}
 }
 
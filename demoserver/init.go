@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"math/rand"
@@ -18,13 +20,17 @@ import (
 	"github.com/stts-se/weblib/util"
 )
 
-func initI18NPropFiles(dir string) error {
+// initI18NPropFiles reads the i18n files in dir. If fsys is not nil, dir is a folder in fsys (see defaultFS).
+func initI18NPropFiles(fsys fs.FS, dir string) error {
 	var msgs []string
 	var err error
 	var db *i18n.I18NDB
 
-	db, err = i18n.ReadI18NPropDir(dir, "en")
-	if err != nil {
+	db = i18n.NewI18NDB(dir, "en")
+	db.FS = fsys
+	// pseudo locale for testing the user interface, selected by ?locale=en-XA
+	db.PseudoLocale = "en-XA"
+	if err = db.Load(); err != nil {
 		return err
 	}
 	msgs, err = db.CrossValidate()
@@ -38,11 +44,28 @@ func initI18NPropFiles(dir string) error {
 		}
 		return fmt.Errorf("I18N cross validation failed")
 	}
+	db.Sanitizer = i18n.BasicSanitizer
 	i18nCache = db
 	return nil
 }
 
-func initUserDB(dbFile string) (*userdb.UserDB, error) {
+// logI18NReload logs reloads of the i18n property files (see i18n.I18NDB.Watch)
+func logI18NReload(e i18n.ReloadEvent) {
+	var valErr *i18n.ValidationError
+	switch {
+	case errors.As(e.Err, &valErr):
+		log.Printf("I18N files %v not reloaded. Cross validation errors below.", e.Changed)
+		for _, msg := range valErr.Messages {
+			fmt.Fprintf(os.Stderr, "I18N validation error : %s\n", msg)
+		}
+	case e.Err != nil:
+		log.Printf("I18N files %v not reloaded : %v", e.Changed, e.Err)
+	default:
+		log.Printf("I18N files reloaded: %v", e.Changed)
+	}
+}
+
+func initUserDB(dbFile, breachedPasswordsFile string) (*userdb.UserDB, error) {
 	var constraints = func(userName, password string) (bool, string) {
 		if len(userName) == 0 {
 			return false, "empty user name"
@@ -50,14 +73,16 @@ func initUserDB(dbFile string) (*userdb.UserDB, error) {
 		if len(userName) < 4 {
 			return false, "username must have min 4 chars"
 		}
-		if len(password) == 0 {
-			return false, "empty password"
-		}
-		if len(password) < 4 {
-			return false, "password must have min 4 chars"
-		}
 		return true, ""
 	}
+	policy := userdb.PasswordPolicy{MinLength: 4, MaxLength: 256, CheckUserName: true}
+	if breachedPasswordsFile != "" {
+		breached, err := userdb.NewBreachedPasswords(breachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
 
 	loadedOrCreated := "Loaded"
 	if !util.FileExists(dbFile) {
@@ -69,6 +94,7 @@ func initUserDB(dbFile string) (*userdb.UserDB, error) {
 		return userDB, fmt.Errorf("couldn't read user db : %v", err)
 	}
 	userDB.Constraints = constraints
+	userDB.PasswordPolicy = policy
 	err = userDB.SaveFile()
 	if err != nil {
 		return userDB, fmt.Errorf("couldn't save user db : %v", err)
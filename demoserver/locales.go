@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/stts-se/weblib/auth"
+)
+
+// userLocales stores the locales chosen by logged-in users, in memory (see i18n.UserLocales)
+type userLocales struct {
+	auth    *auth.Auth
+	mutex   *sync.RWMutex
+	locales map[string]string // user name -> locale
+}
+
+func newUserLocales(a *auth.Auth) *userLocales {
+	return &userLocales{auth: a, mutex: &sync.RWMutex{}, locales: make(map[string]string)}
+}
+
+func (ul *userLocales) UserLocale(r *http.Request) (string, bool) {
+	ok, userName := ul.auth.IsLoggedIn(r)
+	if !ok {
+		return "", false
+	}
+	ul.mutex.RLock()
+	defer ul.mutex.RUnlock()
+	locale, ok := ul.locales[userName]
+	return locale, ok
+}
+
+func (ul *userLocales) SetUserLocale(r *http.Request, locale string) error {
+	ok, userName := ul.auth.IsLoggedIn(r)
+	if !ok {
+		return nil
+	}
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	ul.locales[userName] = locale
+	return nil
+}
@@ -3,26 +3,41 @@ package main
 import (
 	"fmt"
 	"html/template"
-	"path/filepath"
+	"io/fs"
+	"path"
 
 	"github.com/stts-se/weblib/i18n"
 )
 
-// TemplateData used to execute a html/template/Template. If properly used, it will fill in the correct i18n values in the template
+// TemplateData used to execute a html/template/Template. If properly used, it will fill in the correct i18n values in the template (using the i18n template functions, e.g. {{t . "Login"}})
 type TemplateData struct {
 	Loc  *i18n.I18N
 	Data interface{}
 }
 
+// I18N returns the I18N instance of the template data (see i18n.FuncMap)
+func (d TemplateData) I18N() *i18n.I18N {
+	return d.Loc
+}
+
 const templatesFolder = "templates"
 
 func templateFromName(templateName string) string {
-	return filepath.Join(templatesFolder, fmt.Sprintf("%s.html", templateName))
+	return path.Join(templatesFolder, fmt.Sprintf("%s.html", templateName))
 }
 
-var templates = template.Must(template.ParseFiles(
-	templateFromName("login"),
-	templateFromName("logout"),
-	templateFromName("invite"),
-	templateFromName("signup"),
-))
+var templates *template.Template
+
+// initTemplates parses the templates in fsys (see defaultFS), with the i18n template functions for the i18n cache
+func initTemplates(fsys fs.FS) error {
+	var err error
+	templates, err = template.New("").Funcs(i18n.FuncMap(i18nCache)).ParseFS(fsys,
+		templateFromName("login"),
+		templateFromName("logout"),
+		templateFromName("invite"),
+		templateFromName("invitation"),
+		templateFromName("signup"),
+		templateFromName("change_password"),
+	)
+	return err
+}
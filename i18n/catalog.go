@@ -0,0 +1,341 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Catalog is a list of translations for a locale, including the metadata used by translation tools (message contexts, plural forms and comments). Catalogs are used to convert between file formats (see ReadCatalog and Catalog.WriteFile).
+type Catalog struct {
+	Locale  string
+	Entries []*CatalogEntry
+	// Metadata is the locale metadata in the catalog header, if any
+	Metadata LocaleMetadata
+}
+
+// pluralLocale returns the locale used for plural rules: the plural rule id of the metadata, if defined
+func (c *Catalog) pluralLocale() string {
+	if c.Metadata.PluralRule != "" {
+		return c.Metadata.PluralRule
+	}
+	return c.Locale
+}
+
+// CatalogEntry is a translation in a Catalog
+type CatalogEntry struct {
+	// Context is used to disambiguate identical keys (gettext msgctxt)
+	Context string
+	// Key is the source string (gettext msgid)
+	Key string
+	// KeyPlural is the plural source string (gettext msgid_plural), if any
+	KeyPlural string
+	// Value is the translation, for entries without plural forms
+	Value string
+	// Plurals are the translations of a plural entry, by CLDR plural category (see PluralCategories)
+	Plurals map[string]string
+
+	// Comments are translator comments
+	Comments []string
+	// ExtractedComments are comments extracted from the source code (gettext #.)
+	ExtractedComments []string
+	// References are source code references (gettext #:)
+	References []string
+	// Flags are gettext flags, such as fuzzy (gettext #,)
+	Flags []string
+}
+
+// IsPlural checks if the entry has plural forms
+func (e *CatalogEntry) IsPlural() bool {
+	return e.KeyPlural != "" || len(e.Plurals) > 0
+}
+
+// IsFuzzy checks if the entry is marked as fuzzy, i.e., the translation needs to be reviewed
+func (e *CatalogEntry) IsFuzzy() bool {
+	return contains(e.Flags, "fuzzy")
+}
+
+// IsTranslated checks if the entry has a (non-empty) translation
+func (e *CatalogEntry) IsTranslated() bool {
+	if !e.IsPlural() {
+		return e.Value != ""
+	}
+	for _, v := range e.Plurals {
+		if v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogFormat is a file format for catalogs. New formats can be added using RegisterCatalogFormat.
+type CatalogFormat struct {
+	// Name of the format
+	Name string
+	// Extension is the file name extension, including the leading dot. It may contain several dots (as in .nested.json), in which case it takes precedence over shorter extensions.
+	Extension string
+	// Template is true for formats used for translation templates only (such as .pot). Template files are not loaded into an I18NDB.
+	Template bool
+	// Read reads a catalog from file. The syntax is the property file syntax of the I18NDB (ignored by most formats).
+	Read func(fName string, syntax Syntax) (*Catalog, error)
+	// ReadFS reads a catalog from a file in fsys (or from the OS file system, if fsys is nil). If ReadFS is nil, catalogs in the format can only be read from the OS file system (see ReadCatalogFS).
+	ReadFS func(fsys iofs.FS, fName string, syntax Syntax) (*Catalog, error)
+	// Write writes a catalog
+	Write func(c *Catalog, w io.Writer, syntax Syntax) error
+}
+
+var catalogFormats = make(map[string]CatalogFormat)
+
+func init() {
+	RegisterCatalogFormat(CatalogFormat{Name: "properties", Extension: i18nExtension, Read: ReadPropertiesCatalog, ReadFS: readPropertiesCatalog, Write: (*Catalog).WriteProperties})
+	for _, ext := range []string{".po", ".pot"} {
+		RegisterCatalogFormat(CatalogFormat{Name: "po", Extension: ext, Template: ext == ".pot",
+			Read:   func(fName string, _ Syntax) (*Catalog, error) { return ReadPO(fName) },
+			ReadFS: func(fsys iofs.FS, fName string, _ Syntax) (*Catalog, error) { return readPO(fsys, fName) },
+			Write:  func(c *Catalog, w io.Writer, _ Syntax) error { return c.WritePO(w) },
+		})
+	}
+	RegisterCatalogFormat(CatalogFormat{Name: "mo", Extension: ".mo",
+		Read:   func(fName string, _ Syntax) (*Catalog, error) { return ReadMO(fName) },
+		ReadFS: func(fsys iofs.FS, fName string, _ Syntax) (*Catalog, error) { return readMO(fsys, fName) },
+		Write:  func(c *Catalog, w io.Writer, _ Syntax) error { return c.WriteMO(w) },
+	})
+	RegisterCatalogFormat(CatalogFormat{Name: "json", Extension: ".json",
+		Read:   func(fName string, _ Syntax) (*Catalog, error) { return ReadJSON(fName, false) },
+		ReadFS: func(fsys iofs.FS, fName string, _ Syntax) (*Catalog, error) { return readJSON(fsys, fName, false) },
+		Write:  func(c *Catalog, w io.Writer, _ Syntax) error { return c.WriteJSON(w, false) },
+	})
+	RegisterCatalogFormat(CatalogFormat{Name: "nested json", Extension: ".nested.json",
+		Read:   func(fName string, _ Syntax) (*Catalog, error) { return ReadJSON(fName, true) },
+		ReadFS: func(fsys iofs.FS, fName string, _ Syntax) (*Catalog, error) { return readJSON(fsys, fName, true) },
+		Write:  func(c *Catalog, w io.Writer, _ Syntax) error { return c.WriteJSON(w, true) },
+	})
+	RegisterCatalogFormat(CatalogFormat{Name: "xliff", Extension: ".xlf",
+		Read:   func(fName string, _ Syntax) (*Catalog, error) { return ReadXLIFF(fName) },
+		ReadFS: func(fsys iofs.FS, fName string, _ Syntax) (*Catalog, error) { return readXLIFF(fsys, fName) },
+		Write:  func(c *Catalog, w io.Writer, _ Syntax) error { return c.WriteXLIFF(w) },
+	})
+}
+
+// RegisterCatalogFormat adds a catalog format, replacing any format previously registered for the same extension
+func RegisterCatalogFormat(f CatalogFormat) {
+	catalogFormats[f.Extension] = f
+}
+
+// CatalogFormats lists the registered catalog formats, sorted by extension
+func CatalogFormats() []CatalogFormat {
+	res := []CatalogFormat{}
+	for _, f := range catalogFormats {
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Extension < res[j].Extension })
+	return res
+}
+
+// catalogFormatForFile returns the format with the longest extension matching the file name
+func catalogFormatForFile(fName string) (CatalogFormat, bool) {
+	var res CatalogFormat
+	found := false
+	base := filepath.Base(fName)
+	for ext, f := range catalogFormats {
+		if strings.HasSuffix(base, ext) && len(base) > len(ext) && len(ext) > len(res.Extension) {
+			res, found = f, true
+		}
+	}
+	return res, found
+}
+
+// ReadCatalog reads a catalog from file. The format is selected by the file extension (see CatalogFormats): .properties (using the specified syntax), .po or .pot (gettext PO), .mo (gettext MO), .json (flat JSON), .nested.json (nested JSON) or .xlf (XLIFF 2.0).
+func ReadCatalog(fName string, syntax Syntax) (*Catalog, error) {
+	f, ok := catalogFormatForFile(fName)
+	if !ok {
+		return nil, fmt.Errorf("unknown catalog format for file %s", fName)
+	}
+	return f.Read(fName, syntax)
+}
+
+// ReadCatalogFS reads a catalog from a file in fsys, in any registered format (see ReadCatalog). If fsys is nil, the file is read from the OS file system.
+func ReadCatalogFS(fsys iofs.FS, fName string, syntax Syntax) (*Catalog, error) {
+	f, ok := catalogFormatForFile(fName)
+	if !ok {
+		return nil, fmt.Errorf("unknown catalog format for file %s", fName)
+	}
+	if f.ReadFS != nil {
+		return f.ReadFS(fsys, fName, syntax)
+	}
+	if fsys == nil {
+		return f.Read(fName, syntax)
+	}
+	return nil, fmt.Errorf("catalog format %s can't be read from a file system (iofs.FS)", f.Name)
+}
+
+// WriteFile writes the catalog to file. The format is selected by the file extension (see ReadCatalog).
+func (c *Catalog) WriteFile(fName string, syntax Syntax) error {
+	f, ok := catalogFormatForFile(fName)
+	if !ok {
+		return fmt.Errorf("unknown catalog format for file %s", fName)
+	}
+
+	fh, err := os.Create(fName)
+	if err != nil {
+		return fmt.Errorf("couldn't create file : %v", err)
+	}
+	bw := bufio.NewWriter(fh)
+	if err := f.Write(c, bw, syntax); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// catalogUnit is a single translation of a catalog entry, keyed as in an I18N, i.e., with the message context (see ContextKey) and the plural category of plural forms (e.g. "%d users[one]")
+type catalogUnit struct {
+	key   string
+	value string
+	entry *CatalogEntry
+}
+
+// units lists the translations of the catalog. Unless all is true, untranslated and fuzzy entries are left out (as when compiling gettext MO files). With all, plural entries have a unit for each plural category of the locale.
+func (c *Catalog) units(all bool) []catalogUnit {
+	res := []catalogUnit{}
+	for _, e := range c.Entries {
+		if !all && (!e.IsTranslated() || e.IsFuzzy()) {
+			continue
+		}
+		key := ContextKey(e.Context, e.Key)
+		if !e.IsPlural() {
+			res = append(res, catalogUnit{key, e.Value, e})
+			continue
+		}
+		cats := PluralCategories(c.pluralLocale())
+		for _, cat := range pluralCategories {
+			if _, ok := e.Plurals[cat]; ok || (all && contains(cats, cat)) {
+				res = append(res, catalogUnit{fmt.Sprintf("%s[%s]", key, cat), e.Plurals[cat], e})
+			}
+		}
+	}
+	return res
+}
+
+// newCatalog creates a catalog from units. Plural forms (e.g. "%d users[one]") are combined into a plural entry. The comments and flags of an entry are taken from its first unit.
+func newCatalog(locale string, units []catalogUnit) *Catalog {
+	res := &Catalog{Locale: locale}
+	plurals := make(map[string]*CatalogEntry)
+	for _, u := range units {
+		key, cat := splitPluralKey(u.key)
+		ctx, text := splitContextKey(key)
+		if cat == "" {
+			e := *u.entry
+			e.Context, e.Key, e.Value = ctx, text, u.value
+			res.Entries = append(res.Entries, &e)
+			continue
+		}
+		e, ok := plurals[key]
+		if !ok {
+			cp := *u.entry
+			e = &cp
+			e.Context, e.Key, e.KeyPlural, e.Plurals = ctx, text, text, make(map[string]string)
+			plurals[key] = e
+			res.Entries = append(res.Entries, e)
+		}
+		e.Plurals[cat] = u.value
+	}
+	return res
+}
+
+// ReadPropertiesCatalog reads a property file as a catalog. Plural variants (e.g. "%d users[one]") are combined into a plural entry. Sections (e.g. [auth.login]) are read as message contexts, and metadata lines in the header (e.g. "#@dir: rtl") as the locale metadata. The locale is taken from the file name.
+func ReadPropertiesCatalog(fName string, syntax Syntax) (*Catalog, error) {
+	return readPropertiesCatalog(nil, fName, syntax)
+}
+
+func readPropertiesCatalog(fsys iofs.FS, fName string, syntax Syntax) (*Catalog, error) {
+	locale := locNameFromFile(fName)
+	lines, err := readLinesFS(fsys, fName)
+	if err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+	meta, lines, err := splitMetadataHeader(fName, lines)
+	if err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+	entries, err := parseProperties(fName, lines, syntax)
+	if err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+	units := []catalogUnit{}
+	for _, e := range entries {
+		units = append(units, catalogUnit{e.key, e.value, &CatalogEntry{Comments: e.comments}})
+	}
+	res := newCatalog(locale, units)
+	res.Metadata = meta
+	return res, nil
+}
+
+// WriteProperties writes the catalog in property file format, with the locale metadata (if any) in the header. Untranslated and fuzzy entries are left out (as when compiling gettext MO files). Entries with a message context are written in sections (e.g. [auth.login]), after the entries without context.
+func (c *Catalog) WriteProperties(w io.Writer, syntax Syntax) error {
+	units := c.units(false)
+	sort.SliceStable(units, func(i, j int) bool { return units[i].entry.Context < units[j].entry.Context })
+
+	if fields := c.Metadata.fields(); len(fields) > 0 {
+		for _, f := range fields {
+			if strings.ContainsAny(f.value, "\n") {
+				return fmt.Errorf("newlines are not supported in metadata: %s", f.key)
+			}
+			if _, err := fmt.Fprintf(w, "%s%s: %s\n", metadataPrefix, f.key, f.value); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	var prev *CatalogEntry
+	section := ""
+	for _, u := range units {
+		if strings.ContainsAny(u.entry.Context, "[]\t\n") {
+			return fmt.Errorf("invalid message context for property files: %s", u.entry.Context)
+		}
+		if u.entry.Context != section {
+			if _, err := fmt.Fprintf(w, "\n[%s]\n", u.entry.Context); err != nil {
+				return err
+			}
+			section = u.entry.Context
+		}
+		_, text := splitContextKey(u.key)
+		u.key = text
+		if u.entry != prev {
+			for _, comment := range u.entry.Comments {
+				if _, err := fmt.Fprintf(w, "# %s\n", comment); err != nil {
+					return err
+				}
+			}
+			prev = u.entry
+		}
+		var err error
+		switch syntax {
+		case SyntaxTab:
+			if strings.ContainsAny(u.key+u.value, "\t\n") {
+				return fmt.Errorf("tabs and newlines are not supported in the tab syntax: %s", u.entry.Key)
+			}
+			_, err = fmt.Fprintf(w, "%s\t%s\n", u.key, u.value)
+		case SyntaxJava:
+			_, err = fmt.Fprintf(w, "%s = %s\n", escapeJava(u.key, true), escapeJava(u.value, false))
+		default:
+			err = fmt.Errorf("unknown property file syntax: %v", syntax)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_LocNameFromFile(t *testing.T) {
+	for fName, exp := range map[string]string{
+		"i18n/sv.properties":     "sv",
+		"i18n/sv-FI.json":        "sv-FI",
+		"i18n/fr.nested.json":    "fr",
+		"i18n/de.xlf":            "de",
+		"i18n/messages.pot":      "messages",
+		"i18n/sv.unknown.format": "sv.unknown",
+	} {
+		if w, g := exp, locNameFromFile(fName); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+	if w, g := false, isI18NFile("messages.pot"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Load_Formats(t *testing.T) {
+	db, err := ReadI18NPropDir("test_files/formats", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "[de en fr sv]", fmt.Sprintf("%v", db.ListLocales()); w != g {
+		t.Errorf(fs, w, g)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if len(msgs) > 0 {
+		t.Errorf("Unexpected cross validation errors : %v", msgs)
+	}
+	for loc, exp := range map[string]string{
+		"sv": "Öppna|Inloggad som <b>anna</b>|2 användare",
+		"fr": "Ouvrir|Connecté en tant que anna|2 utilisateurs",
+		"de": "Öffnen|Angemeldet als anna|2 Benutzer",
+	} {
+		i := db.GetOrDefault(loc)
+		got := strings.Join([]string{i.S("menu.file.open"), i.S("Logged in as user %s", "anna"), i.N("%d users", 2)}, "|")
+		if w, g := exp, got; w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_Load_DuplicateLocale(t *testing.T) {
+	_, err := ReadI18NPropDir("test_files/duplicate", "sv")
+	if err == nil {
+		t.Fatalf("Expected error for duplicate locale, got nil")
+	}
+	if w, g := "locale sv is defined in more than one file: test_files/duplicate/sv.json and test_files/duplicate/sv.properties", err.Error(); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_ReadJSON_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for json, exp := range map[string]string{
+		"{\n\"a\": \"b\",\n\"c\": 1\n}": "sv.json:3: expected string value for key c, found 1",
+		"{\n\"a\": {\"b\": \"c\"}\n}":   "sv.json:2: nested objects are not supported in flat JSON (use the .nested.json extension): a",
+		"{\n\"a\": \"b\"\n}\n{}":        "sv.json:4: unexpected data after JSON object",
+		"[\"a\"]":                       "sv.json:1: expected object, found [",
+	} {
+		fName := filepath.Join(dir, "sv.json")
+		if err := ioutil.WriteFile(fName, []byte(json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadJSON(fName, false)
+		if err == nil {
+			t.Errorf("Expected error for %s, got nil", json)
+			continue
+		}
+		if w, g := exp, strings.TrimPrefix(err.Error(), dir+"/"); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func unitMap(c *Catalog) map[string]string {
+	res := make(map[string]string)
+	for _, u := range c.units(true) {
+		res[u.key] = u.value
+	}
+	return res
+}
+
+func Test_Catalog_Formats_RoundTrip(t *testing.T) {
+	cat, err := ReadCatalog("test_files/formats/fr.nested.json", SyntaxTab)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	dir, err := ioutil.TempDir("", "formats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, ext := range []string{".json", ".nested.json", ".xlf", ".properties", ".po", ".mo"} {
+		fName := filepath.Join(dir, "fr"+ext)
+		if err := cat.WriteFile(fName, SyntaxTab); err != nil {
+			t.Errorf("Unexpected error for %s : %v", ext, err)
+			continue
+		}
+		res, err := ReadCatalog(fName, SyntaxTab)
+		if err != nil {
+			t.Errorf("Unexpected error for %s : %v", ext, err)
+			continue
+		}
+		// MO files are sorted by key
+		if w, g := unitMap(cat), unitMap(res); !reflect.DeepEqual(w, g) {
+			t.Errorf("%s: "+fs, ext, w, g)
+		}
+	}
+
+	// nested JSON output
+	var b bytes.Buffer
+	if err := cat.WriteJSON(&b, true); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	orig, err := ioutil.ReadFile("test_files/formats/fr.nested.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, g := string(orig), b.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// conflicting keys in nested JSON
+	conflict := &Catalog{Locale: "fr", Entries: []*CatalogEntry{{Key: "menu", Value: "Menu"}, {Key: "menu.open", Value: "Ouvrir"}}}
+	if err := conflict.WriteJSON(&b, true); err == nil {
+		t.Errorf("Expected error for conflicting keys, got nil")
+	}
+}
+
+func Test_XLIFF(t *testing.T) {
+	cat, err := ReadXLIFF("test_files/formats/de.xlf")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "de", cat.Locale; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := []string{"Login button"}, cat.Entries[0].Comments; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+
+	// untranslated and fuzzy entries are exported
+	cat.Entries = append(cat.Entries,
+		&CatalogEntry{Key: "Logout"},
+		&CatalogEntry{Key: "Register", Value: "Registrieren", Flags: []string{"fuzzy"}},
+	)
+	var b bytes.Buffer
+	if err := cat.WriteXLIFF(&b); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for _, exp := range []string{
+		`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="de">`,
+		`<unit id="u6" name="Logout">
+      <segment state="initial">
+        <source>Logout</source>
+      </segment>`,
+		`<segment state="initial">
+        <source>Register</source>
+        <target>Registrieren</target>`,
+	} {
+		if !strings.Contains(b.String(), exp) {
+			t.Errorf("Expected XLIFF to contain %s, got %s", exp, b.String())
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "xliff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fName := filepath.Join(dir, "de.xlf")
+	if err := ioutil.WriteFile(fName, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ReadXLIFF(fName)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := len(cat.Entries), len(res.Entries); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := false, res.Entries[4].IsTranslated(); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := true, res.Entries[5].IsFuzzy(); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
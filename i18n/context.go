@@ -0,0 +1,62 @@
+package i18n
+
+import (
+	"log"
+	"strings"
+)
+
+// contextSeparator separates the message context from the key (as in gettext MO files)
+const contextSeparator = "\x04"
+
+// ContextKey returns the internal key for s in a message context (or namespace), e.g. auth.login. Context keys can be used with N and M. Property files define context keys in sections (see SyntaxTab), or in namespace files (see I18NDB.Load).
+func ContextKey(ctx, s string) string {
+	if ctx == "" {
+		return s
+	}
+	return ctx + contextSeparator + s
+}
+
+// splitContextKey splits a key into the message context (if any) and the source string
+func splitContextKey(key string) (string, string) {
+	if i := strings.Index(key, contextSeparator); i >= 0 {
+		return key[:i], key[i+len(contextSeparator):]
+	}
+	return "", key
+}
+
+// keyText returns the source string of a key, without message context
+func keyText(key string) string {
+	_, s := splitContextKey(key)
+	return s
+}
+
+// displayKey returns a key in the format used in messages: [context] source string
+func displayKey(key string) string {
+	if ctx, s := splitContextKey(key); ctx != "" {
+		return "[" + ctx + "] " + s
+	}
+	return key
+}
+
+// keyNamespace returns the namespace of a key, i.e., its message context ("" for keys without context)
+func keyNamespace(key string) string {
+	ctx, _ := splitContextKey(key)
+	return ctx
+}
+
+// SC is used to look up the localized version of the input string (s) in a message context (or namespace), such as auth.login.button. This way, the same source string can have different translations in different contexts. If s isn't defined for the context, the translation of s without context is used (see S). The arguments (args) are filled in using fmt.Sprintf.
+func (i *I18N) SC(ctx, s string, args ...interface{}) string {
+	key := ContextKey(ctx, s)
+	i.checkMissing(key)
+
+	res := s
+	if r, ok := i.lookup(key); ok {
+		res = r
+	} else if r, ok := i.lookup(s); ok {
+		res = r
+	} else {
+		log.Printf("Missing %s localization for input string %s", i.locale, displayKey(key))
+	}
+
+	return sprintf(res, args...)
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseSections(t *testing.T) {
+	for _, syn := range []Syntax{SyntaxTab, SyntaxJava} {
+		sep := "\t"
+		if syn == SyntaxJava {
+			sep = " = "
+		}
+		lines := []string{
+			"Open" + sep + "Öppna",
+			"[menu.file]",
+			"Open" + sep + "Öppna fil",
+			"[]",
+			"Close" + sep + "Stäng",
+		}
+		entries, err := parseProperties("sv.properties", lines, syn)
+		if err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+		i := newI18N("sv")
+		for _, e := range entries {
+			if err := i.add(e.key, e.value); err != nil {
+				t.Fatalf("Unexpected error : %v", err)
+			}
+		}
+		if w, g := "[Open menu.file\x04Open Close]", fmt.Sprintf("%v", i.keys); w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := "Öppna|Öppna fil|Stäng|Stäng", strings.Join([]string{i.S("Open"), i.SC("menu.file", "Open"), i.S("Close"), i.SC("menu.file", "Close")}, "|"); w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := "Save", i.SC("menu.file", "Save"); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_Namespaces(t *testing.T) {
+	db, err := ReadI18NPropDir("test_files/namespaces", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	sv := db.GetOrDefault("sv")
+	for _, test := range []struct{ exp, got string }{
+		{"Logga in", sv.S("Login")},
+		{"Inloggning", sv.SC("auth", "Login")},
+		{"Logga in", sv.SC("auth.button", "Login")},
+		{"Lösenord", sv.SC("auth", "Password")},
+		{"Öppna fil", sv.SC("menu.file", "Open")},
+		{"Users", sv.SC("admin", "Users")},
+	} {
+		if test.exp != test.got {
+			t.Errorf(fs, test.exp, test.got)
+		}
+	}
+
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "[namespace in en is not present in sv\tadmin]", fmt.Sprintf("%v", msgs); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_CrossValidate_Namespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, loc := range []string{"en", "sv"} {
+		if err := os.Mkdir(filepath.Join(dir, loc), 0755); err != nil {
+			t.Fatalf("Couldn't create dir : %v", err)
+		}
+	}
+	now := time.Now()
+	writePropFile(t, filepath.Join(dir, "en.properties"), "Users\tUsers\n", now)
+	writePropFile(t, filepath.Join(dir, "sv.properties"), "Users\tAnvändare\n", now)
+	writePropFile(t, filepath.Join(dir, "en", "auth.properties"), "Login\tLogin\nPassword\tPassword\n", now)
+	writePropFile(t, filepath.Join(dir, "sv", "auth.properties"), "Password\tLösenord\nLogin\tLogga in\nUser\tAnvändare\n", now)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	exp := []string{
+		"mismatching number of items (namespace auth); en:2 vs. sv:3",
+		"key in sv is not present in en\t[auth] User",
+		"mismatching key for line 1 (namespace auth) (en vs. sv)\t[auth] Login\t[auth] Password",
+		"mismatching key for line 2 (namespace auth) (en vs. sv)\t[auth] Password\t[auth] Login",
+		"key no. 3 (namespace auth) in sv is not present in en\t[auth] User",
+	}
+	if w, g := strings.Join(exp, "\n"), strings.Join(msgs, "\n"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// reloading a namespace file
+	writePropFile(t, filepath.Join(dir, "sv", "auth.properties"), "Login\tLogga in\nPassword\tLösenordet\n", now.Add(time.Second))
+	if err := db.Reload(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "Lösenordet|Användare", db.GetOrDefault("sv").SC("auth", "Password")+"|"+db.GetOrDefault("sv").S("Users"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
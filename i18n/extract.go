@@ -0,0 +1,525 @@
+package i18n
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// ExtractedKey is an i18n key found in Go source code or templates (see Extract)
+type ExtractedKey struct {
+	// Context is the message context of the key, if any (see SC)
+	Context string
+	Key     string
+	// Plural is true if the key is used with I18N.N
+	Plural bool
+	// Positions lists the source positions (file:line) where the key is used
+	Positions []string
+}
+
+// extractMethods are the I18N methods taking a key as first argument
+var extractMethods = map[string]bool{"S": true, "N": true, "M": true, "Select": true}
+
+// ExtractReceivers are the names of the variables and fields holding an I18N instance in Go code (as loc in loc.S("Login")), and of the methods returning one (as GetOrDefault in db.GetOrDefault("sv").S("Login")). Since the I18N method names are common, calls on other receivers are not extracted from Go code. Names are matched case-insensitively, so that loc also matches the field Loc.
+var ExtractReceivers = []string{"loc", "i18n", "cli18n", "GetI18NFromRequest", "GetOrDefault", "GetOrCreate", "WithAttributes", "I18N"}
+
+// receiverName returns the name of the variable, field or function of a receiver expression (loc, Loc and GetOrDefault for loc, data.Loc and db.GetOrDefault("sv"))
+func receiverName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.CallExpr:
+		return receiverName(e.Fun)
+	case *ast.ParenExpr:
+		return receiverName(e.X)
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	}
+	return ""
+}
+
+func isExtractReceiver(e ast.Expr) bool {
+	name := receiverName(e)
+	for _, r := range ExtractReceivers {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractContextMethods are the I18N methods taking a message context and a key as the first two arguments
+var extractContextMethods = map[string]bool{"SC": true}
+
+// extractTemplateFuncs are the template functions taking the template data and a key as the first two arguments (see FuncMap)
+var extractTemplateFuncs = map[string]bool{"t": true, "tn": true, "thtml": true}
+
+// templateExtensions are the file extensions of the templates scanned by Extract
+var templateExtensions = map[string]bool{".html": true, ".tmpl": true, ".gohtml": true}
+
+// keyCollector collects extracted keys in order of first occurrence
+type keyCollector struct {
+	keys  []*ExtractedKey
+	index map[string]*ExtractedKey
+}
+
+func newKeyCollector() *keyCollector {
+	return &keyCollector{index: make(map[string]*ExtractedKey)}
+}
+
+func (c *keyCollector) add(ctx, key string, plural bool, pos string) {
+	id := ContextKey(ctx, key)
+	k, ok := c.index[id]
+	if !ok {
+		k = &ExtractedKey{Context: ctx, Key: key}
+		c.keys = append(c.keys, k)
+		c.index[id] = k
+	}
+	k.Plural = k.Plural || plural
+	k.Positions = append(k.Positions, pos)
+}
+
+func (c *keyCollector) result() []ExtractedKey {
+	res := []ExtractedKey{}
+	for _, k := range c.keys {
+		res = append(res, *k)
+	}
+	return res
+}
+
+// ExtractGo finds the keys used in a Go source file, i.e., the string literals passed as first argument to methods named S, N, M or Select (as in loc.S("Login")), and the contexts and keys passed to SC (as in loc.SC("auth", "Login")), called on one of the ExtractReceivers. Keys passed as variables or constants can't be found.
+func ExtractGo(fName string) ([]ExtractedKey, error) {
+	c := newKeyCollector()
+	if err := c.extractGo(fName); err != nil {
+		return nil, err
+	}
+	return c.result(), nil
+}
+
+func (c *keyCollector) extractGo(fName string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fName, nil, 0)
+	if err != nil {
+		return fmt.Errorf("couldn't parse Go file : %v", err)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isExtractReceiver(sel.X) {
+			return true
+		}
+		str := func(e ast.Expr) (string, bool) {
+			lit, ok := e.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return "", false
+			}
+			s, err := strconv.Unquote(lit.Value)
+			return s, err == nil
+		}
+		ctx, key, ok := "", "", false
+		switch {
+		case extractMethods[sel.Sel.Name]:
+			key, ok = str(call.Args[0])
+		case extractContextMethods[sel.Sel.Name] && len(call.Args) > 1:
+			if ctx, ok = str(call.Args[0]); ok {
+				key, ok = str(call.Args[1])
+			}
+		}
+		if !ok {
+			return true
+		}
+		pos := fset.Position(call.Pos())
+		c.add(ctx, key, sel.Sel.Name == "N", fmt.Sprintf("%s:%d", pos.Filename, pos.Line))
+		return true
+	})
+	return nil
+}
+
+// ExtractTemplate finds the keys used in a (text or html) template file, i.e., the string constants passed to methods named S, N or M (as in {{.Loc.S "Login"}}), the contexts and keys passed to SC, and the keys passed to the template functions t, tn and thtml (as in {{t . "Login"}}, see FuncMap).
+func ExtractTemplate(fName string) ([]ExtractedKey, error) {
+	c := newKeyCollector()
+	if err := c.extractTemplate(fName); err != nil {
+		return nil, err
+	}
+	return c.result(), nil
+}
+
+func (c *keyCollector) extractTemplate(fName string) error {
+	bts, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return err
+	}
+	text := string(bts)
+	t := parse.New(fName)
+	t.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	if _, err := t.Parse(text, "", "", trees); err != nil {
+		return fmt.Errorf("couldn't parse template : %v", err)
+	}
+	names := []string{}
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, n := range n.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 1 {
+				var ident []string
+				fun := ""
+				switch f := n.Args[0].(type) {
+				case *parse.FieldNode:
+					ident = f.Ident
+				case *parse.VariableNode:
+					ident = f.Ident
+				case *parse.IdentifierNode:
+					fun = f.Ident
+				}
+				method := ""
+				if len(ident) > 0 {
+					method = ident[len(ident)-1]
+				}
+				s1, ok1 := n.Args[1].(*parse.StringNode)
+				line := 1 + strings.Count(text[:int(n.Position())], "\n")
+				pos := fmt.Sprintf("%s:%d", fName, line)
+				switch {
+				case extractMethods[method] && ok1:
+					c.add("", s1.Text, method == "N", pos)
+				case extractContextMethods[method] && ok1 && len(n.Args) > 2:
+					if s2, ok2 := n.Args[2].(*parse.StringNode); ok2 {
+						c.add(s1.Text, s2.Text, false, pos)
+					}
+				case extractTemplateFuncs[fun] && len(n.Args) > 2:
+					if s2, ok2 := n.Args[2].(*parse.StringNode); ok2 {
+						c.add("", s2.Text, fun == "tn", pos)
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	for _, name := range names {
+		walk(trees[name].Root)
+	}
+	return nil
+}
+
+// Extract finds the keys used in the specified files and folders. Folders are searched recursively for Go files (except tests) and templates (.html, .tmpl and .gohtml files). Hidden folders and vendor folders are skipped. Keys are listed in order of first occurrence.
+func Extract(paths []string) ([]ExtractedKey, error) {
+	c := newKeyCollector()
+	extract := func(fName string) error {
+		switch {
+		case filepath.Ext(fName) == ".go":
+			return c.extractGo(fName)
+		case templateExtensions[filepath.Ext(fName)]:
+			return c.extractTemplate(fName)
+		}
+		return nil
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := extract(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = filepath.Walk(p, func(fName string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if fName != p && (strings.HasPrefix(info.Name(), ".") || info.Name() == "vendor") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(fName, "_test.go") {
+				return nil
+			}
+			return extract(fName)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.result(), nil
+}
+
+// NewExtractedCatalog creates an (untranslated) catalog for the extracted keys, with source references, e.g. for writing a gettext template (.pot) file
+func NewExtractedCatalog(locale string, keys []ExtractedKey) *Catalog {
+	res := &Catalog{Locale: locale}
+	for _, k := range keys {
+		e := &CatalogEntry{Context: k.Context, Key: k.Key, References: k.Positions}
+		if k.Plural {
+			e.KeyPlural = k.Key
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res
+}
+
+// KeyReport compares the extracted keys to the keys of a locale (see CompareKeys)
+type KeyReport struct {
+	Locale string
+	// File is the i18n file of the locale (not including namespace files)
+	File string
+	// Missing lists the extracted keys that are not defined for the locale
+	Missing []ExtractedKey
+	// Unused lists the keys of the locale that were not extracted ("[context] key" for keys with a message context). NB that keys used dynamically (e.g. loc.S(msg)) can't be extracted, and will be reported as unused.
+	Unused []string
+}
+
+// CompareKeys compares the extracted keys to the keys of each locale. Regional locales with a parent locale in the db (e.g. sv-FI, with parent sv), and locales with a fallback locale in the metadata, are not checked for missing keys, since they inherit keys from the parent.
+func (db *I18NDB) CompareKeys(keys []ExtractedKey) []KeyReport {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	used := make(map[string]bool)
+	for _, k := range keys {
+		used[ContextKey(k.Context, k.Key)] = true
+		// trusted HTML translations (see I18N.HTML)
+		used[ContextKey(k.Context, k.Key+htmlKeySuffix)] = true
+	}
+	files := make(map[string]string)
+	for f := range db.files {
+		if !isI18NFile(f) {
+			continue
+		}
+		if locName, ns := i18nFileID(db.Dir, f); ns == "" {
+			files[locName] = f
+		}
+	}
+
+	res := []KeyReport{}
+	for _, locName := range sortedKeysString2I18N(db.data) {
+		loc := db.data[locName]
+		if loc.pseudo {
+			continue
+		}
+		rep := KeyReport{Locale: locName, File: files[locName], Missing: []ExtractedKey{}, Unused: []string{}}
+		if _, regional := db.fallbackLocale(locName); !regional {
+			for _, k := range keys {
+				if !loc.hasKey(ContextKey(k.Context, k.Key)) && !loc.hasKey(ContextKey(k.Context, k.Key+htmlKeySuffix)) {
+					rep.Missing = append(rep.Missing, k)
+				}
+			}
+		}
+		for _, k := range loc.keys {
+			if !used[k] && !contains(rep.Unused, displayKey(k)) {
+				rep.Unused = append(rep.Unused, displayKey(k))
+			}
+		}
+		res = append(res, rep)
+	}
+	return res
+}
+
+// AddKeys adds the missing keys to a property file (in the specified syntax) in place, keeping the existing lines in their order. Each missing key is inserted next to the closest of the extracted keys (in order of occurrence, see Extract) preceding it, or else following it, in the same section of the file, or else at the end of its section. Keys with a message context for which there is no section in the file are added in new sections (e.g. [auth.login]) at the end of the file. The key itself is used as value; unless translated is true, each key is preceded by a "TODO translate" comment. Plural keys are added with the plural categories of the locale (e.g. "%d users[one]"), or of the plural rule in the metadata of the file.
+func AddKeys(fName, locale string, keys, missing []ExtractedKey, syntax Syntax, translated bool) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	lines := []string{}
+	if _, err := os.Stat(fName); err == nil {
+		if lines, err = util.ReadLines(fName); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	entries, err := parseProperties(fName, lines, syntax)
+	if err != nil {
+		return fmt.Errorf("couldn't add keys to %s : %v", fName, err)
+	}
+	// plural rule of the locale metadata (see LocaleMetadata), if any
+	pluralLocale := locale
+	if meta, _, err := splitMetadataHeader(fName, lines); err == nil && meta.PluralRule != "" {
+		pluralLocale = meta.PluralRule
+	}
+	isComment := func(l string) bool {
+		l = strings.TrimSpace(l)
+		return strings.HasPrefix(l, "#") || (syntax == SyntaxJava && strings.HasPrefix(l, "!"))
+	}
+
+	// the first and last line of each key in the file (including preceding comments, and all plural variants), and the last line of each section
+	type span struct{ start, end int }
+	spans := make(map[string]span)
+	sectionEnd := make(map[string]int)
+	for _, e := range entries {
+		start, end := e.line-1, e.line-1
+		for start > 0 && isComment(lines[start-1]) {
+			start--
+		}
+		for syntax == SyntaxJava && endsWithContinuation(lines[end]) && end+1 < len(lines) {
+			end++
+		}
+		id := strings.TrimSuffix(baseKey(e.key), htmlKeySuffix)
+		if sp, ok := spans[id]; ok && sp.start < start {
+			start = sp.start
+		}
+		spans[id] = span{start, end}
+		ctx, _ := splitContextKey(e.key)
+		sectionEnd[ctx] = end
+	}
+	// the section at the end of the file
+	section := ""
+	for _, l := range lines {
+		if name, ok := parseSection(l); ok {
+			section = name
+		}
+	}
+
+	entryLines := func(k ExtractedKey) ([]string, error) {
+		res := []string{}
+		if !translated {
+			res = append(res, "# TODO translate")
+		}
+		pks := []string{k.Key}
+		if k.Plural {
+			pks = []string{}
+			for _, cat := range PluralCategories(pluralLocale) {
+				pks = append(pks, fmt.Sprintf("%s[%s]", k.Key, cat))
+			}
+		}
+		for _, pk := range pks {
+			switch syntax {
+			case SyntaxTab:
+				if strings.ContainsAny(k.Key, "\t\n") {
+					return res, fmt.Errorf("tabs and newlines are not supported in the tab syntax: %s", k.Key)
+				}
+				res = append(res, fmt.Sprintf("%s\t%s", pk, k.Key))
+			case SyntaxJava:
+				res = append(res, fmt.Sprintf("%s = %s", escapeJava(pk, true), escapeJava(k.Key, false)))
+			default:
+				return res, fmt.Errorf("unknown property file syntax: %v", syntax)
+			}
+		}
+		return res, nil
+	}
+
+	// the missing keys are inserted before or after a line of the file, or at the end of the file, in a new section
+	type anchor struct {
+		line   int
+		before bool
+	}
+	inserts := make(map[anchor][]string)
+	placed := make(map[string]anchor)
+	newSections := []string{}
+	appended := make(map[string][]string)
+	isMissing := make(map[string]bool)
+	for _, k := range missing {
+		isMissing[ContextKey(k.Context, k.Key)] = true
+	}
+	keys = append(append([]ExtractedKey{}, keys...), missing...)
+	for i, k := range keys {
+		id := ContextKey(k.Context, k.Key)
+		if !isMissing[id] {
+			continue
+		}
+		delete(isMissing, id)
+		ls, err := entryLines(k)
+		if err != nil {
+			return err
+		}
+		a, ok := anchor{}, false
+		for j := i - 1; j >= 0 && !ok; j-- {
+			if keys[j].Context != k.Context {
+				continue
+			}
+			prev := ContextKey(keys[j].Context, keys[j].Key)
+			if a, ok = placed[prev]; !ok {
+				if sp, found := spans[prev]; found {
+					a, ok = anchor{line: sp.end}, true
+				}
+			}
+		}
+		for j := i + 1; j < len(keys) && !ok; j++ {
+			if sp, found := spans[ContextKey(keys[j].Context, keys[j].Key)]; found && keys[j].Context == k.Context {
+				a, ok = anchor{line: sp.start, before: true}, true
+			}
+		}
+		if end, found := sectionEnd[k.Context]; !ok && found {
+			a, ok = anchor{line: end}, true
+		}
+		if !ok {
+			if _, found := appended[k.Context]; !found {
+				newSections = append(newSections, k.Context)
+			}
+			appended[k.Context] = append(appended[k.Context], ls...)
+			continue
+		}
+		placed[id] = a
+		inserts[a] = append(inserts[a], ls...)
+	}
+
+	out := []string{}
+	for i, l := range lines {
+		out = append(out, inserts[anchor{line: i, before: true}]...)
+		out = append(out, l)
+		out = append(out, inserts[anchor{line: i}]...)
+	}
+	for _, ctx := range newSections {
+		if ctx != section {
+			out = append(out, "["+ctx+"]")
+			section = ctx
+		}
+		out = append(out, appended[ctx]...)
+	}
+	if err := ioutil.WriteFile(fName, []byte(strings.Join(out, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("couldn't write file : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Extract(t *testing.T) {
+	keys, err := Extract([]string{"test_files/extract/src"})
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, k := range keys {
+		got = append(got, fmt.Sprintf("%s %v %v", displayKey(ContextKey(k.Context, k.Key)), k.Plural, k.Positions))
+	}
+	exp := []string{
+		"Login false [test_files/extract/src/handlers.go:6 test_files/extract/src/handlers.go:11 test_files/extract/src/handlers.go:14 test_files/extract/src/page.html:2]",
+		"Logged in as user %s false [test_files/extract/src/handlers.go:7 test_files/extract/src/page.html:5]",
+		"%d users true [test_files/extract/src/handlers.go:8]",
+		"Welcome, {name}! false [test_files/extract/src/handlers.go:9]",
+		"[auth.button] Login false [test_files/extract/src/handlers.go:12 test_files/extract/src/page.html:10]",
+		"Logout false [test_files/extract/src/page.html:7]",
+		"%d items true [test_files/extract/src/page.html:9]",
+	}
+	if w, g := fmt.Sprintf("%v", exp), fmt.Sprintf("%v", got); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_CompareKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, loc := range []string{"en", "sv", "sv-FI"} {
+		bts, err := ioutil.ReadFile(filepath.Join("test_files/extract/i18n", loc+".properties"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, loc+".properties"), bts, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := Extract([]string{"test_files/extract/src"})
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, rep := range db.CompareKeys(keys) {
+		missing := []string{}
+		for _, k := range rep.Missing {
+			missing = append(missing, displayKey(ContextKey(k.Context, k.Key)))
+		}
+		got = append(got, fmt.Sprintf("%s %q %q", rep.Locale, missing, rep.Unused))
+
+		if err := AddKeys(rep.File, rep.Locale, keys, rep.Missing, SyntaxTab, rep.Locale == "en"); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	exp := []string{
+		`en ["Logged in as user %s" "%d users" "Welcome, {name}!" "[auth.button] Login" "%d items"] ["Unused"]`,
+		`sv ["Logged in as user %s" "Welcome, {name}!" "[auth.button] Login" "Logout" "%d items"] ["[menu] Open"]`,
+		`sv-FI [] []`,
+	}
+	if w, g := fmt.Sprintf("%v", exp), fmt.Sprintf("%v", got); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// the existing lines are kept in order, and missing keys are inserted next to the keys around them in the code
+	bts, err := ioutil.ReadFile(filepath.Join(dir, "sv.properties"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expSv := "Login\tLogga in\n" +
+		"# TODO translate\nLogged in as user %s\tLogged in as user %s\n" +
+		"%d users[one]\t%d användare\n%d users[other]\t%d användare\n" +
+		"# TODO translate\nWelcome, {name}!\tWelcome, {name}!\n" +
+		"# TODO translate\nLogout\tLogout\n" +
+		"# TODO translate\n%d items[one]\t%d items\n%d items[other]\t%d items\n" +
+		"\n[menu]\nOpen\tÖppna\n" +
+		"[auth.button]\n" +
+		"# TODO translate\nLogin\tLogin\n"
+	if w, g := expSv, string(bts); w != g {
+		t.Errorf(fs, w, g)
+	}
+	bts, err = ioutil.ReadFile(filepath.Join(dir, "en.properties"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expEn := "Login\tLogin\n" +
+		"Logged in as user %s\tLogged in as user %s\n" +
+		"%d users[one]\t%d users\n%d users[other]\t%d users\n" +
+		"Welcome, {name}!\tWelcome, {name}!\n" +
+		"Logout\tLogout\n" +
+		"%d items[one]\t%d items\n%d items[other]\t%d items\n" +
+		"Unused\tUnused\n" +
+		"[auth.button]\n" +
+		"Login\tLogin\n"
+	if w, g := expEn, string(bts); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// after the update, no keys are missing, and the files are valid
+	db, err = ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for _, rep := range db.CompareKeys(keys) {
+		if w, g := 0, len(rep.Missing); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_ExtractTemplate_FuncMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fName := filepath.Join(dir, "page.html")
+	tmpl := "<p>{{t . \"Login\"}}</p>\n<p>{{tn . \"%d users\" .Count}} {{thtml . \"Logged in as <b>%s</b>\" .User}}</p>\n"
+	if err := ioutil.WriteFile(fName, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ExtractTemplate(fName)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, k := range keys {
+		got = append(got, fmt.Sprintf("%s %v %v", k.Key, k.Plural, k.Positions))
+	}
+	exp := []string{
+		"Login false [" + fName + ":1]",
+		"%d users true [" + fName + ":2]",
+		"Logged in as <b>%s</b> false [" + fName + ":2]",
+	}
+	if w, g := fmt.Sprintf("%v", exp), fmt.Sprintf("%v", got); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_AddKeys_BeforeFollowingKey(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"sv.properties": "#@name: Svenska\n\n[menu]\n# file menu\nClose\tStäng\n",
+	})
+	defer os.RemoveAll(dir)
+	fName := filepath.Join(dir, "sv.properties")
+	keys := []ExtractedKey{{Context: "menu", Key: "Open"}, {Context: "menu", Key: "Close"}, {Key: "Login"}}
+	if err := AddKeys(fName, "sv", keys, []ExtractedKey{keys[0], keys[2]}, SyntaxTab, false); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	bts, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "#@name: Svenska\n\n[menu]\n# TODO translate\nOpen\tOpen\n# file menu\nClose\tStäng\n[]\n# TODO translate\nLogin\tLogin\n"
+	if w, g := exp, string(bts); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
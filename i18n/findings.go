@@ -0,0 +1,272 @@
+package i18n
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FindingKind is the kind of a cross validation finding (see CrossValidateFindings)
+type FindingKind string
+
+// Kinds of cross validation findings
+const (
+	// FindingMissingKey is a key defined in one locale, but not in another
+	FindingMissingKey FindingKind = "missing-key"
+	// FindingMissingNamespace is a namespace (message context) defined in one locale, but not in another
+	FindingMissingNamespace FindingKind = "missing-namespace"
+	// FindingPlaceholders is a translation with other placeholders than in the reference locale
+	FindingPlaceholders FindingKind = "placeholders"
+	// FindingPluralCategory is a plural key missing a plural category required by the locale
+	FindingPluralCategory FindingKind = "plural-category"
+	// FindingUnusedPluralCategory is a plural key with a category not used by the locale
+	FindingUnusedPluralCategory FindingKind = "unused-plural-category"
+	// FindingSelectValues is a key with other select variants (selector name or values) than in the reference locale
+	FindingSelectValues FindingKind = "select-values"
+	// FindingItemCount is a mismatching number of keys
+	FindingItemCount FindingKind = "item-count"
+	// FindingKeyOrder is a key in another position than in the reference locale
+	FindingKeyOrder FindingKind = "key-order"
+	// FindingFallback is a fallback locale (in the locale metadata) that is not defined, or leads to a cycle
+	FindingFallback FindingKind = "fallback"
+	// FindingDirection is a text direction (in the locale metadata) that doesn't match the script of the locale
+	FindingDirection FindingKind = "direction"
+	// FindingMetadata is locale metadata defined for some locales, but not for all
+	FindingMetadata FindingKind = "metadata"
+	// FindingInvalidMessage is a translation with ICU MessageFormat arguments that is not a valid ICU MessageFormat message (it is used as literal text)
+	FindingInvalidMessage FindingKind = "invalid-message"
+)
+
+// FindingKinds lists all kinds of findings
+var FindingKinds = []FindingKind{FindingMissingKey, FindingMissingNamespace, FindingPlaceholders, FindingSelectValues, FindingPluralCategory, FindingUnusedPluralCategory, FindingItemCount, FindingKeyOrder, FindingFallback, FindingDirection, FindingMetadata, FindingInvalidMessage}
+
+// Severity of a cross validation finding
+type Severity string
+
+// Severities of findings. Findings with severity ignore are left out.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityIgnore  Severity = "ignore"
+)
+
+// DefaultSeverities are the severities used for kinds of findings not listed in I18NDB.Severities. Missing keys and broken translations are errors, while differences in key order and count (which follow from missing keys), and metadata differences, are warnings.
+var DefaultSeverities = map[FindingKind]Severity{
+	FindingMissingKey:           SeverityError,
+	FindingMissingNamespace:     SeverityError,
+	FindingPlaceholders:         SeverityError,
+	FindingSelectValues:         SeverityError,
+	FindingPluralCategory:       SeverityError,
+	FindingUnusedPluralCategory: SeverityWarning,
+	FindingItemCount:            SeverityWarning,
+	FindingKeyOrder:             SeverityWarning,
+	FindingFallback:             SeverityError,
+	FindingDirection:            SeverityWarning,
+	FindingMetadata:             SeverityWarning,
+	FindingInvalidMessage:       SeverityError,
+}
+
+// ParseSeverities parses a comma separated list of severities by kind, e.g. "key-order=ignore,item-count=error"
+func ParseSeverities(s string) (map[FindingKind]Severity, error) {
+	res := make(map[FindingKind]Severity)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		fs := strings.SplitN(kv, "=", 2)
+		if len(fs) != 2 {
+			return res, fmt.Errorf("invalid severity (expected kind=severity): %s", kv)
+		}
+		kind, sev := FindingKind(strings.TrimSpace(fs[0])), Severity(strings.TrimSpace(fs[1]))
+		if _, ok := DefaultSeverities[kind]; !ok {
+			return res, fmt.Errorf("unknown kind of finding: %s", kind)
+		}
+		if sev != SeverityError && sev != SeverityWarning && sev != SeverityIgnore {
+			return res, fmt.Errorf("unknown severity: %s (expected error, warning or ignore)", sev)
+		}
+		res[kind] = sev
+	}
+	return res, nil
+}
+
+// Finding is a problem found by cross validation (see CrossValidateFindings)
+type Finding struct {
+	Kind     FindingKind `json:"kind"`
+	Severity Severity    `json:"severity"`
+	// Locale is the locale with the problem (e.g. the locale missing a key)
+	Locale string `json:"locale"`
+	// Ref is the locale that Locale was compared with, if any
+	Ref string `json:"ref,omitempty"`
+	// Key is the key of the finding, if any, printed as "[context] key"
+	Key string `json:"key,omitempty"`
+	// File and Line are the position of the key, if known (the line is 0 for catalog formats without line numbers)
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Message is the finding as printed by CrossValidate
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	pos := ""
+	if f.File != "" && f.Line > 0 {
+		pos = fmt.Sprintf("%s:%d: ", f.File, f.Line)
+	} else if f.File != "" {
+		pos = f.File + ": "
+	}
+	return fmt.Sprintf("%s%s: %s", pos, f.Severity, f.Message)
+}
+
+// HasErrors checks if any of the findings has severity error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// severity returns the severity of a kind of finding (see Severities)
+func (db *I18NDB) severity(kind FindingKind) Severity {
+	if sev, ok := db.Severities[kind]; ok {
+		return sev
+	}
+	if sev, ok := DefaultSeverities[kind]; ok {
+		return sev
+	}
+	return SeverityError
+}
+
+// keySource is the position of a key in an i18n file
+type keySource struct {
+	file string
+	line int
+}
+
+// setSource saves the position of key (without plural category or select value), unless already known
+func (i *I18N) setSource(key, file string, line int) {
+	base := baseKey(key)
+	if _, ok := i.sources[base]; !ok {
+		i.sources[base] = keySource{file, line}
+	}
+}
+
+// finding returns a finding for key, positioned in the i18n file of this locale
+func (i *I18N) finding(kind FindingKind, ref, key, msg string) Finding {
+	res := Finding{Kind: kind, Locale: i.locale, Ref: ref, Message: msg}
+	if key != "" {
+		res.Key = displayKey(key)
+		src := i.sources[key]
+		res.File, res.Line = src.file, src.line
+	}
+	return res
+}
+
+// Report formats for findings (see WriteFindings)
+const (
+	ReportText  = "text"
+	ReportJSON  = "json"
+	ReportJUnit = "junit"
+)
+
+// WriteFindings writes the findings in a report format: text (one finding per line, see Finding.String), json (an array of findings), or junit (JUnit XML, for CI servers, with a failing test case for each error and a passing test case with the message as output for each warning)
+func WriteFindings(w io.Writer, findings []Finding, format string) error {
+	switch format {
+	case ReportText:
+		for _, f := range findings {
+			if _, err := fmt.Fprintln(w, f.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if findings == nil {
+			findings = []Finding{}
+		}
+		return enc.Encode(findings)
+	case ReportJUnit:
+		return writeJUnit(w, findings)
+	}
+	return fmt.Errorf("unknown report format: %s (expected text, json or junit)", format)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the findings as JUnit XML, with a test suite for each locale
+func writeJUnit(w io.Writer, findings []Finding) error {
+	byLocale := make(map[string][]Finding)
+	locs := []string{}
+	for _, f := range findings {
+		if _, ok := byLocale[f.Locale]; !ok {
+			locs = append(locs, f.Locale)
+		}
+		byLocale[f.Locale] = append(byLocale[f.Locale], f)
+	}
+	sort.Strings(locs)
+
+	doc := junitSuites{}
+	if len(findings) == 0 {
+		doc.Suites = append(doc.Suites, junitSuite{Name: "i18n", Tests: 1, Cases: []junitCase{{ClassName: "i18n", Name: "cross validation"}}})
+	}
+	for _, loc := range locs {
+		suite := junitSuite{Name: "i18n." + loc}
+		for _, f := range byLocale[loc] {
+			name := string(f.Kind)
+			if f.Key != "" {
+				name += ": " + f.Key
+			}
+			c := junitCase{ClassName: suite.Name, Name: name, File: f.File, Line: f.Line}
+			if f.Severity == SeverityError {
+				c.Failure = &junitFailure{Type: string(f.Kind), Message: f.Message, Text: f.String()}
+				suite.Failures++
+			} else {
+				c.SystemOut = f.String()
+			}
+			suite.Cases = append(suite.Cases, c)
+			suite.Tests++
+		}
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
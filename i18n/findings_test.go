@@ -0,0 +1,201 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var findingsTestFiles = map[string]string{
+	"en.properties": "Login\tLogin\nLogout\tLogout\nHello, %s\tHello, %s\n",
+	"sv.properties": "# comment\nLogout\tLogga ut\nLogin\tLogga in\nHello, %s\tHej!\n",
+}
+
+func Test_CrossValidateFindings(t *testing.T) {
+	dir := writeTestFiles(t, findingsTestFiles)
+	defer os.RemoveAll(dir)
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, fmt.Sprintf("%s %s %s/%s %s %s:%d", f.Kind, f.Severity, f.Locale, f.Ref, f.Key, filepath.Base(f.File), f.Line))
+	}
+	exp := []string{
+		"placeholders error sv/en Hello, %s sv.properties:4",
+		"key-order warning sv/en Logout sv.properties:2",
+		"key-order warning sv/en Login sv.properties:3",
+	}
+	if w, g := strings.Join(exp, "\n"), strings.Join(got, "\n"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := true, HasErrors(findings); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// configured severities
+	db.Severities = map[FindingKind]Severity{FindingKeyOrder: SeverityIgnore, FindingPlaceholders: SeverityWarning}
+	findings, err = db.CrossValidateFindings()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 1, len(findings); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := false, HasErrors(findings); w != g {
+		t.Errorf(fs, w, g)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "mismatching placeholders; en:[%s] vs. sv:[]\tHello, %s", strings.Join(msgs, "\n"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// reference locale
+	db.Severities = nil
+	db.ReferenceLocale = "sv"
+	msgs, err = db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "mismatching key for line 1 (sv vs. en)\tLogout\tLogin", msgs[1]; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_ParseSeverities(t *testing.T) {
+	sevs, err := ParseSeverities("key-order=ignore, item-count = error")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "map[item-count:error key-order:ignore]", fmt.Sprintf("%v", sevs); w != g {
+		t.Errorf(fs, w, g)
+	}
+	for _, s := range []string{"key-order", "order=ignore", "key-order=fatal"} {
+		if _, err := ParseSeverities(s); err == nil {
+			t.Errorf("Expected error for %s, got nil", s)
+		}
+	}
+}
+
+func Test_WriteFindings(t *testing.T) {
+	findings := []Finding{
+		{Kind: FindingMissingKey, Severity: SeverityError, Locale: "sv", Ref: "en", Key: "Login", File: "en.properties", Line: 2, Message: "key in en is not present in sv\tLogin"},
+		{Kind: FindingItemCount, Severity: SeverityWarning, Locale: "sv", Ref: "en", Message: "mismatching number of items; en:2 vs. sv:1"},
+	}
+
+	var b bytes.Buffer
+	if err := WriteFindings(&b, findings, ReportText); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "en.properties:2: error: key in en is not present in sv\tLogin\nwarning: mismatching number of items; en:2 vs. sv:1\n", b.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	b.Reset()
+	if err := WriteFindings(&b, findings, ReportJSON); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	var read []Finding
+	if err := json.Unmarshal(b.Bytes(), &read); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := fmt.Sprintf("%v", findings), fmt.Sprintf("%v", read); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	b.Reset()
+	if err := WriteFindings(&b, findings, ReportJUnit); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for _, exp := range []string{
+		`<testsuite name="i18n.sv" tests="2" failures="1">`,
+		`<testcase classname="i18n.sv" name="missing-key: Login" file="en.properties" line="2">`,
+		`<failure type="missing-key" message="key in en is not present in sv&#x9;Login">`,
+		`<system-out>warning: mismatching number of items; en:2 vs. sv:1</system-out>`,
+	} {
+		if !strings.Contains(b.String(), exp) {
+			t.Errorf("Expected JUnit report to contain %s, got %s", exp, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := WriteFindings(&b, nil, ReportJUnit); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if exp := `<testcase classname="i18n" name="cross validation"></testcase>`; !strings.Contains(b.String(), exp) {
+		t.Errorf("Expected JUnit report to contain %s, got %s", exp, b.String())
+	}
+
+	if err := WriteFindings(&b, findings, "html"); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+func Test_FixKeyOrder(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties":      "#@name: English\n\nLogin\tLogin\n%d users[one]\t%d user\n%d users[other]\t%d users\n\n[menu]\nOpen\tOpen\nClose\tClose\n",
+		"sv.properties":      "#@name: Svenska\n\n[menu]\nClose\tStäng\nOpen\tÖppna\n[]\n# plural\n%d users[one]\t%d användare\n%d users[other]\t%d användare\nExtra\tExtra\nLogin\tLogga in\n",
+		"sv-FI.properties":   "Open\tAvaa\nLogin\tKirjaudu\n",
+		"en/auth.properties": "Password\tPassword\nUsername\tUsername\n",
+		"sv/auth.properties": "Username\tAnvändarnamn\nPassword\tLösenord\n",
+	})
+	defer os.RemoveAll(dir)
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	fixed, err := db.FixKeyOrder()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for i, f := range fixed {
+		fixed[i], _ = filepath.Rel(dir, f)
+	}
+	if w, g := "sv.properties sv/auth.properties", strings.Join(fixed, " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	bts, err := ioutil.ReadFile(filepath.Join(dir, "sv.properties"))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	exp := "#@name: Svenska\n\n[menu]\nOpen\tÖppna\nClose\tStäng\n[]\nLogin\tLogga in\n# plural\n%d users[one]\t%d användare\n%d users[other]\t%d användare\nExtra\tExtra\n"
+	if w, g := exp, string(bts); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	if err := db.Load(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for _, f := range findings {
+		if f.Kind == FindingKeyOrder && f.Key != "Extra" {
+			t.Errorf("Unexpected finding after fix: %v", f)
+		}
+	}
+
+	// nothing more to fix
+	fixed, err = db.FixKeyOrder()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(fixed); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
@@ -0,0 +1,179 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// FixKeyOrder rewrites the property files of the locales, so that the keys are in the same order as in the reference locale (see ReferenceLocale), as checked by CrossValidate. Regional locales (and locales with a fallback locale in the metadata) are not checked for key order, and are left as they are. Within each section of a file, entries are sorted by the position of the key in the reference locale, and keys not defined in the reference locale are moved to the end of the section. Entries keep their preceding comment lines; the file header (e.g. metadata) is kept at the top. Files in other catalog formats are not changed. The loaded data is not updated (call Load or Reload to re-read the files). The rewritten files are returned.
+func (db *I18NDB) FixKeyOrder() ([]string, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	res := []string{}
+	if db.FS != nil {
+		return res, fmt.Errorf("can't fix key order of files in a file system (fs.FS)")
+	}
+	locs := []string{}
+	for _, loc := range sortedKeysString2I18N(db.data) {
+		if _, ok := db.fallbackLocale(loc); !ok {
+			locs = append(locs, loc)
+		}
+	}
+	locs = db.withReferenceFirst(locs)
+	if len(locs) <= 1 {
+		return res, nil
+	}
+
+	// refKeys returns the keys of the reference locale for a namespace, i.e., the first locale defining the namespace (as in crossValidateNamespace)
+	refKeys := func(ns string) []string {
+		for _, loc := range locs {
+			if keys := namespaceKeys(db.data[loc].keys, ns); len(keys) > 0 || ns == "" {
+				return keys
+			}
+		}
+		return []string{}
+	}
+
+	files := []string{}
+	for f := range db.files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if !isI18NFile(f) || path.Ext(f) != i18nExtension {
+			continue
+		}
+		locName, ns := i18nFileID(db.Dir, f)
+		if !contains(locs, locName) {
+			continue
+		}
+		changed, err := reorderPropFile(f, ns, db.Syntax, refKeys)
+		if err != nil {
+			return res, err
+		}
+		if changed {
+			log.Printf("Fixed key order in %s", f)
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+// propBlock is the lines of an entry in a property file, including the preceding comment and empty lines
+type propBlock struct {
+	key   string
+	lines []string
+}
+
+// reorderPropFile sorts the entries of a property file (in namespace ns) by their position in the reference keys for each section (see FixKeyOrder). The file is rewritten only if the order has changed.
+func reorderPropFile(fName, ns string, syntax Syntax, refKeys func(ns string) []string) (bool, error) {
+	lines, err := util.ReadLines(fName)
+	if err != nil {
+		return false, err
+	}
+	entries, err := parseProperties(fName, lines, syntax)
+	if err != nil {
+		return false, fmt.Errorf("couldn't fix key order in %s : %v", fName, err)
+	}
+	if len(entries) == 0 {
+		return false, nil
+	}
+
+	isComment := func(l string) bool {
+		l = strings.TrimSpace(l)
+		return strings.HasPrefix(l, "#") || (syntax == SyntaxJava && strings.HasPrefix(l, "!"))
+	}
+	isSection := func(l string) bool {
+		_, ok := parseSection(l)
+		return ok
+	}
+
+	// the header is the lines before the first entry and its comments
+	start := entries[0].line - 1
+	for start > 0 && isComment(lines[start-1]) {
+		start--
+	}
+	header := []string{}
+	for _, l := range lines[:start] {
+		if !isSection(l) {
+			header = append(header, l)
+		}
+	}
+
+	// entries are grouped by section, in order of appearance
+	sections := []string{}
+	blocks := make(map[string][]propBlock)
+	next := start
+	for _, e := range entries {
+		end := e.line - 1
+		for syntax == SyntaxJava && endsWithContinuation(lines[end]) && end+1 < len(lines) {
+			end++
+		}
+		b := propBlock{key: e.key}
+		for _, l := range lines[next : end+1] {
+			if !isSection(l) {
+				b.lines = append(b.lines, l)
+			}
+		}
+		next = end + 1
+		ctx, _ := splitContextKey(e.key)
+		if _, ok := blocks[ctx]; !ok {
+			sections = append(sections, ctx)
+		}
+		blocks[ctx] = append(blocks[ctx], b)
+	}
+	footer := []string{}
+	for _, l := range lines[next:] {
+		if !isSection(l) {
+			footer = append(footer, l)
+		}
+	}
+
+	out := append([]string{}, header...)
+	section := ""
+	for _, ctx := range sections {
+		bs := blocks[ctx]
+		// the namespace of the keys in the section
+		keyNS := keyNamespace(namespaceKey(ns, ContextKey(ctx, "")))
+		order := make(map[string]int)
+		for i, k := range refKeys(keyNS) {
+			order[k] = i
+		}
+		index := func(b propBlock) int {
+			if i, ok := order[namespaceKey(ns, baseKey(b.key))]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(bs, func(i, j int) bool { return index(bs[i]) < index(bs[j]) })
+		if ctx != section {
+			// empty lines are kept before the section line
+			first := bs[0].lines
+			for len(first) > 0 && strings.TrimSpace(first[0]) == "" {
+				out, first = append(out, first[0]), first[1:]
+			}
+			bs[0].lines = first
+			out = append(out, "["+ctx+"]")
+			section = ctx
+		}
+		for _, b := range bs {
+			out = append(out, b.lines...)
+		}
+	}
+	out = append(out, footer...)
+
+	if strings.Join(out, "\n") == strings.Join(lines, "\n") {
+		return false, nil
+	}
+	if err := ioutil.WriteFile(fName, []byte(strings.Join(out, "\n")+"\n"), 0644); err != nil {
+		return false, fmt.Errorf("couldn't write file : %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,529 @@
+package i18n
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatDataExtension is the file extension of format data files, read from the i18n folder (see I18NDB.Load)
+const formatDataExtension = ".format"
+
+// localeLanguage returns the (lower case) language part of a locale name, e.g. "sv" for "sv_SE" or "sv-SE"
+func localeLanguage(locale string) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// numberSymbols are the locale specific symbols used to format numbers
+type numberSymbols struct {
+	decimal string
+	group   string
+	percent string // percent pattern, # is replaced by the number
+}
+
+var defaultNumberSymbols = numberSymbols{decimal: ".", group: ",", percent: "#%"}
+
+var numberSymbolsByLanguage = map[string]numberSymbols{
+	"en": defaultNumberSymbols,
+	"sv": {decimal: ",", group: "\u00a0", percent: "#\u00a0%"},
+	"nb": {decimal: ",", group: "\u00a0", percent: "#\u00a0%"},
+	"da": {decimal: ",", group: ".", percent: "#\u00a0%"},
+	"fi": {decimal: ",", group: "\u00a0", percent: "#\u00a0%"},
+	"de": {decimal: ",", group: ".", percent: "#\u00a0%"},
+	"fr": {decimal: ",", group: "\u202f", percent: "#\u00a0%"},
+}
+
+func numberSymbolsForLocale(locale string) numberSymbols {
+	if sym, ok := numberSymbolsByLanguage[localeLanguage(locale)]; ok {
+		return sym
+	}
+	return defaultNumberSymbols
+}
+
+// toFloat converts a numeric value to float64. The second return value is false if v is not a number.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// maxPlainNumber is the magnitude from which numbers are formatted in scientific notation, since a float64 has no more than 17 significant digits
+const maxPlainNumber = 1e21
+
+// formatScientific formats a number in scientific notation, with the shortest mantissa that represents the number (e.g. 1,5E21 in Swedish). Infinite numbers are formatted as ∞ and -∞.
+func (fd *formatData) formatScientific(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "∞"
+	case math.IsInf(f, -1):
+		return "-∞"
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := s, ""
+	if i := strings.Index(s, "e"); i >= 0 {
+		mantissa, exp = s[:i], strings.TrimPrefix(s[i+1:], "+")
+	}
+	return strings.Replace(mantissa, ".", fd.numberSymbols.decimal, 1) + "E" + exp
+}
+
+// formatNumber formats a number using the format data. The style is one of "" (up to three decimals), "integer", "percent" or "currency" (two decimals, without currency symbol). Numbers of magnitude 1e21 or more are formatted in scientific notation (see formatScientific). Non-numeric values are formatted using fmt.Sprint.
+func (fd *formatData) formatNumber(v interface{}, style string) string {
+	f, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	sym := fd.numberSymbols
+
+	decimals := 3
+	switch style {
+	case "integer":
+		decimals = 0
+	case "percent":
+		f = f * 100
+		decimals = 0
+	case "currency":
+		decimals = 2
+	}
+
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) >= maxPlainNumber {
+		res := fd.formatScientific(f)
+		if style == "percent" {
+			return strings.Replace(sym.percent, "#", res, 1)
+		}
+		return res
+	}
+
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	intPart, fracPart := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+		if style != "currency" {
+			fracPart = strings.TrimRight(fracPart, "0")
+		}
+	}
+
+	var b strings.Builder
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sym.group)
+		}
+		b.WriteRune(d)
+	}
+	if fracPart != "" {
+		b.WriteString(sym.decimal)
+		b.WriteString(fracPart)
+	}
+
+	if style == "percent" {
+		return strings.Replace(sym.percent, "#", b.String(), 1)
+	}
+	return b.String()
+}
+
+// dateSymbols are the locale specific names and patterns used to format dates and times. Patterns use a subset of the CLDR date field symbols: y, M, d, E, H, h, m, s, a and z.
+type dateSymbols struct {
+	months      []string
+	shortMonths []string
+	weekdays    []string // starting with Sunday
+	datePattern map[string]string
+	timePattern map[string]string
+}
+
+var dateSymbolsByLanguage = map[string]dateSymbols{
+	"en": {
+		months:      []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:    []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		datePattern: map[string]string{"short": "M/d/yy", "medium": "MMM d, y", "long": "MMMM d, y", "full": "EEEE, MMMM d, y"},
+		timePattern: map[string]string{"short": "h:mm a", "medium": "h:mm:ss a", "long": "h:mm:ss a z", "full": "h:mm:ss a z"},
+	},
+	"sv": {
+		months:      []string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"},
+		shortMonths: []string{"jan.", "feb.", "mars", "apr.", "maj", "juni", "juli", "aug.", "sep.", "okt.", "nov.", "dec."},
+		weekdays:    []string{"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag", "lördag"},
+		datePattern: map[string]string{"short": "y-MM-dd", "medium": "d MMM y", "long": "d MMMM y", "full": "EEEE d MMMM y"},
+		timePattern: map[string]string{"short": "HH:mm", "medium": "HH:mm:ss", "long": "HH:mm:ss z", "full": "HH:mm:ss z"},
+	},
+}
+
+func dateSymbolsForLocale(locale string) dateSymbols {
+	if sym, ok := dateSymbolsByLanguage[localeLanguage(locale)]; ok {
+		return sym
+	}
+	return dateSymbolsByLanguage["en"]
+}
+
+// formatDate formats the date of t using the format data. The style is one of short, medium (default), long or full.
+func (fd *formatData) formatDate(t time.Time, style string) string {
+	if style == "" {
+		style = "medium"
+	}
+	return fd.dateSymbols.format(t, fd.datePattern[style])
+}
+
+// formatTime formats the time of day of t using the format data. The style is one of short, medium (default), long or full.
+func (fd *formatData) formatTime(t time.Time, style string) string {
+	if style == "" {
+		style = "medium"
+	}
+	return fd.dateSymbols.format(t, fd.timePattern[style])
+}
+
+func (sym dateSymbols) format(t time.Time, pattern string) string {
+	var b strings.Builder
+	p := []rune(pattern)
+	for i := 0; i < len(p); {
+		c := p[i]
+		n := 1
+		for i+n < len(p) && p[i+n] == c {
+			n++
+		}
+		i += n
+		pad := func(v int) string {
+			return fmt.Sprintf("%0*d", n, v)
+		}
+		switch c {
+		case 'y':
+			if n == 2 {
+				b.WriteString(pad(t.Year() % 100))
+			} else {
+				b.WriteString(pad(t.Year()))
+			}
+		case 'M':
+			switch {
+			case n >= 4:
+				b.WriteString(sym.months[t.Month()-1])
+			case n == 3:
+				b.WriteString(sym.shortMonths[t.Month()-1])
+			default:
+				b.WriteString(pad(int(t.Month())))
+			}
+		case 'd':
+			b.WriteString(pad(t.Day()))
+		case 'E':
+			b.WriteString(sym.weekdays[t.Weekday()])
+		case 'H':
+			b.WriteString(pad(t.Hour()))
+		case 'h':
+			h := t.Hour() % 12
+			if h == 0 {
+				h = 12
+			}
+			b.WriteString(pad(h))
+		case 'm':
+			b.WriteString(pad(t.Minute()))
+		case 's':
+			b.WriteString(pad(t.Second()))
+		case 'a':
+			b.WriteString(t.Format("PM"))
+		case 'z':
+			b.WriteString(t.Format("MST"))
+		default:
+			b.WriteString(strings.Repeat(string(c), n))
+		}
+	}
+	return b.String()
+}
+
+// relativeTimeUnits are the units used for relative times, from the largest
+var relativeTimeUnits = []struct {
+	name    string
+	seconds float64
+}{
+	{"year", 365 * 24 * 3600},
+	{"month", 30 * 24 * 3600},
+	{"week", 7 * 24 * 3600},
+	{"day", 24 * 3600},
+	{"hour", 3600},
+	{"minute", 60},
+	{"second", 1},
+}
+
+// relativeTimeNow is the limit (in seconds) below which a relative time is formatted as "now"
+const relativeTimeNow = 10
+
+// formatData is the locale specific data used to format numbers, currencies, dates and relative times
+type formatData struct {
+	numberSymbols
+	dateSymbols
+	currencyPattern string            // # is replaced by the number, and ¤ by the currency symbol
+	currencySymbols map[string]string // ISO 4217 currency code -> symbol
+	relativeNow     string
+	relative        map[string]dict // unit.direction (e.g. minute.past) -> plural category -> pattern
+}
+
+var defaultCurrencySymbols = map[string]string{"EUR": "€", "GBP": "£", "JPY": "¥", "USD": "$"}
+
+var defaultRelativeTime = map[string]dict{}
+
+func init() {
+	for _, u := range relativeTimeUnits {
+		defaultRelativeTime[u.name+".past"] = dict{PluralOne: "%d " + u.name + " ago", PluralOther: "%d " + u.name + "s ago"}
+		defaultRelativeTime[u.name+".future"] = dict{PluralOne: "in %d " + u.name, PluralOther: "in %d " + u.name + "s"}
+	}
+}
+
+// builtinFormatData returns the built-in format data for the language of the locale. Relative times and currency symbols are only built-in for English, other languages need format data files.
+func builtinFormatData(locale string) *formatData {
+	res := &formatData{
+		numberSymbols:   numberSymbolsForLocale(locale),
+		dateSymbols:     dateSymbolsForLocale(locale),
+		currencyPattern: "#\u00a0¤",
+		currencySymbols: make(map[string]string),
+		relativeNow:     "now",
+		relative:        make(map[string]dict),
+	}
+	if localeLanguage(locale) == "en" {
+		res.currencyPattern = "¤#"
+	}
+	// copy, so that format data files don't modify the built-in data
+	res.datePattern, res.timePattern = make(map[string]string), make(map[string]string)
+	for k, v := range dateSymbolsForLocale(locale).datePattern {
+		res.datePattern[k] = v
+	}
+	for k, v := range dateSymbolsForLocale(locale).timePattern {
+		res.timePattern[k] = v
+	}
+	for k, v := range defaultCurrencySymbols {
+		res.currencySymbols[k] = v
+	}
+	for k, forms := range defaultRelativeTime {
+		res.relative[k] = dict{}
+		for cat, v := range forms {
+			res.relative[k][cat] = v
+		}
+	}
+	return res
+}
+
+// isFormatDataFile checks if the file is a format data file (see readFormatData)
+func isFormatDataFile(fName string) bool {
+	return strings.HasSuffix(fName, formatDataExtension)
+}
+
+// readFormatData reads a format data file for the locale. The file uses the Java properties syntax (see SyntaxJava), with the following keys:
+//
+//	number.decimal, number.group    decimal and grouping separators
+//	number.percent                  percent pattern, # is replaced by the number (e.g. "#\u00a0%")
+//	currency.pattern                currency pattern, # is replaced by the number, and ¤ by the currency symbol
+//	currency.<code>                 currency symbol for an ISO 4217 code (e.g. currency.SEK = kr)
+//	date.months, date.shortMonths   comma separated month names, starting with January
+//	date.weekdays                   comma separated day names, starting with Sunday
+//	date.<style>, time.<style>      date and time patterns for the styles short, medium, long and full (CLDR symbols y, M, d, E, H, h, m, s, a and z)
+//	relative.now                    relative time for less than ten seconds
+//	relative.<unit>.<direction>     relative time patterns, with plural category (e.g. relative.minute.past[one] = %d minute ago); unit is one of second, minute, hour, day, week, month and year, and direction is past or future
+//
+// Keys not defined in the file are taken from the built-in data for the language of the locale.
+func readFormatData(fsys iofs.FS, locale, fName string) (*formatData, error) {
+	res := builtinFormatData(locale)
+	lines, err := readLinesFS(fsys, fName)
+	if err != nil {
+		return res, err
+	}
+	entries, err := parseProperties(fName, lines, SyntaxJava)
+	errs, _ := err.(ParseErrors)
+	if err != nil && errs == nil {
+		return res, err
+	}
+	for _, e := range entries {
+		if err := res.set(e.key, e.value); err != nil {
+			errs = append(errs, &ParseError{File: fName, Line: e.line, Msg: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// set sets a value read from a format data file
+func (fd *formatData) set(key, value string) error {
+	list := func(n int) ([]string, error) {
+		res := strings.Split(value, ",")
+		if len(res) != n {
+			return nil, fmt.Errorf("expected %d comma separated names for %s, found %d", n, key, len(res))
+		}
+		for i, s := range res {
+			res[i] = strings.TrimSpace(s)
+		}
+		return res, nil
+	}
+	styles := map[string]bool{"short": true, "medium": true, "long": true, "full": true}
+
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("unknown format data key: %s", displayKey(key))
+	}
+	var err error
+	switch group, name := parts[0], parts[1]; {
+	case key == "number.decimal":
+		fd.decimal = value
+	case key == "number.group":
+		fd.group = value
+	case key == "number.percent":
+		fd.percent = value
+	case key == "currency.pattern":
+		fd.currencyPattern = value
+	case group == "currency" && name == strings.ToUpper(name):
+		fd.currencySymbols[name] = value
+	case key == "date.months":
+		fd.months, err = list(12)
+	case key == "date.shortMonths":
+		fd.shortMonths, err = list(12)
+	case key == "date.weekdays":
+		fd.weekdays, err = list(7)
+	case group == "date" && styles[name]:
+		fd.datePattern[name] = value
+	case group == "time" && styles[name]:
+		fd.timePattern[name] = value
+	case key == "relative.now":
+		fd.relativeNow = value
+	case group == "relative":
+		base, cat := splitPluralKey(name)
+		if _, ok := defaultRelativeTime[base]; !ok || cat == "" {
+			return fmt.Errorf("unknown relative time key: %s", key)
+		}
+		fd.relative[base][cat] = value
+	default:
+		return fmt.Errorf("unknown format data key: %s", displayKey(key))
+	}
+	return err
+}
+
+// formatCurrency formats an amount in the currency (an ISO 4217 code, such as SEK), using the format data. Unknown currency codes are used as symbols.
+func (fd *formatData) formatCurrency(v interface{}, currency string) string {
+	symbol, ok := fd.currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+	res := strings.Replace(fd.currencyPattern, "#", fd.formatNumber(v, "currency"), 1)
+	return strings.Replace(res, "¤", symbol, 1)
+}
+
+// formatRelativeTime formats the time t relative to now (e.g. "3 minutes ago"), using the format data. The value is truncated to the largest unit that fits, e.g. 90 minutes is formatted as 1 hour.
+func (fd *formatData) formatRelativeTime(locale string, t, now time.Time) string {
+	d := t.Sub(now).Seconds()
+	dir := "future"
+	if d < 0 {
+		dir = "past"
+	}
+	d = math.Abs(d)
+	if d < relativeTimeNow {
+		return fd.relativeNow
+	}
+	for _, u := range relativeTimeUnits {
+		if d < u.seconds {
+			continue
+		}
+		n := int(d / u.seconds)
+		forms := fd.relative[u.name+"."+dir]
+		pattern, ok := forms[PluralCategory(locale, n)]
+		if !ok {
+			pattern = forms[PluralOther]
+		}
+		if !strings.Contains(pattern, "%") {
+			// e.g. "yesterday"
+			return pattern
+		}
+		return sprintf(pattern, n)
+	}
+	return fd.relativeNow
+}
+
+// formatData returns the format data of the locale: the data read from a format data file for the locale or its parent locales (see I18NDB.Load), or else the built-in data for the language
+func (i *I18N) formatData() *formatData {
+	for _, loc := range i.fallbackChain() {
+		if loc.format != nil {
+			return loc.format
+		}
+		if loc.defaultFallback {
+			break
+		}
+	}
+	return builtinFormatData(i.locale)
+}
+
+// FormatNumber formats a number using the decimal and grouping separators of the locale, with up to three decimals (e.g. 1 234,5 in Swedish)
+func (i *I18N) FormatNumber(v interface{}) string {
+	return i.formatData().formatNumber(v, "")
+}
+
+// FormatInteger formats a number rounded to an integer, using the grouping separator of the locale
+func (i *I18N) FormatInteger(v interface{}) string {
+	return i.formatData().formatNumber(v, "integer")
+}
+
+// FormatPercent formats a fraction as a percentage for the locale (e.g. 0.25 is formatted as 25 % in Swedish)
+func (i *I18N) FormatPercent(v interface{}) string {
+	return i.formatData().formatNumber(v, "percent")
+}
+
+// FormatCurrency formats an amount in the currency (an ISO 4217 code, such as SEK), with two decimals (e.g. 1 234,50 kr in Swedish)
+func (i *I18N) FormatCurrency(v interface{}, currency string) string {
+	return i.formatData().formatCurrency(v, currency)
+}
+
+// FormatDate formats the date of t for the locale. The style is one of short, medium (default), long or full.
+func (i *I18N) FormatDate(t time.Time, style string) string {
+	return i.formatData().formatDate(t, style)
+}
+
+// FormatTime formats the time of day of t for the locale. The style is one of short, medium (default), long or full.
+func (i *I18N) FormatTime(t time.Time, style string) string {
+	return i.formatData().formatTime(t, style)
+}
+
+// FormatRelativeTime formats t relative to the current time, e.g. "3 minutes ago" or "in 2 days"
+func (i *I18N) FormatRelativeTime(t time.Time) string {
+	return i.formatData().formatRelativeTime(i.pluralLocale(), t, time.Now())
+}
+
+// MonthName returns the name of the month for the locale. If short is true, the abbreviated name is returned.
+func (i *I18N) MonthName(m time.Month, short bool) string {
+	fd := i.formatData()
+	if short {
+		return fd.shortMonths[m-1]
+	}
+	return fd.months[m-1]
+}
+
+// DayName returns the name of the weekday for the locale
+func (i *I18N) DayName(d time.Weekday) string {
+	return i.formatData().weekdays[d]
+}
@@ -0,0 +1,147 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_FormatData(t *testing.T) {
+	db, err := ReadI18NPropDir("../demoserver/i18n", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	d := time.Date(2021, time.March, 4, 15, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		locale string
+		exp    []string
+	}{
+		{"sv", []string{"1\u00a0234,5", "1\u00a0235", "25\u00a0%", "1\u00a0234,50\u00a0kr", "-12,00\u00a0US$", "2021-03-04", "4 mars 2021", "torsdag 4 mars 2021", "15:30", "mars|mars|torsdag"}},
+		{"en", []string{"1,234.5", "1,235", "25%", "SEK\u00a01,234.50", "$-12.00", "3/4/21", "March 4, 2021", "Thursday, March 4, 2021", "3:30 PM", "March|Mar|Thursday"}},
+	} {
+		i := db.GetOrDefault(test.locale)
+		got := []string{
+			i.FormatNumber(1234.5),
+			i.FormatInteger(1234.6),
+			i.FormatPercent(0.25),
+			i.FormatCurrency(1234.5, "SEK"),
+			i.FormatCurrency(-12, "USD"),
+			i.FormatDate(d, "short"),
+			i.FormatDate(d, "long"),
+			i.FormatDate(d, "full"),
+			i.FormatTime(d, "short"),
+			i.MonthName(d.Month(), false) + "|" + i.MonthName(d.Month(), true) + "|" + i.DayName(d.Weekday()),
+		}
+		if w, g := strings.Join(test.exp, "\n"), strings.Join(got, "\n"); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_FormatNumber_Large(t *testing.T) {
+	db, err := ReadI18NPropDir("../demoserver/i18n", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	sv, en := db.GetOrDefault("sv"), db.GetOrDefault("en")
+	for _, test := range []struct {
+		v      interface{}
+		sv, en string
+	}{
+		{1e20, "100\u00a0000\u00a0000\u00a0000\u00a0000\u00a0000\u00a0000", "100,000,000,000,000,000,000"},
+		{1e21, "1E21", "1E21"},
+		{-1.5e21, "-1,5E21", "-1.5E21"},
+		{1.25e300, "1,25E300", "1.25E300"},
+		{math.Inf(1), "∞", "∞"},
+		{math.NaN(), "NaN", "NaN"},
+	} {
+		if w, g := test.sv+"|"+test.en, sv.FormatNumber(test.v)+"|"+en.FormatNumber(test.v); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_FormatRelativeTime(t *testing.T) {
+	db, err := ReadI18NPropDir("../demoserver/i18n", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	now := time.Date(2021, time.March, 4, 15, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		d      time.Duration
+		sv, en string
+	}{
+		{-5 * time.Second, "just nu", "just now"},
+		{-30 * time.Second, "för 30 sekunder sedan", "30 seconds ago"},
+		{-time.Minute, "för 1 minut sedan", "1 minute ago"},
+		{-3 * time.Minute, "för 3 minuter sedan", "3 minutes ago"},
+		{90 * time.Minute, "om 1 timme", "in 1 hour"},
+		{-25 * time.Hour, "i går", "yesterday"},
+		{3 * 24 * time.Hour, "om 3 dagar", "in 3 days"},
+		{-15 * 24 * time.Hour, "för 2 veckor sedan", "2 weeks ago"},
+		{-400 * 24 * time.Hour, "i fjol", "last year"},
+	} {
+		for loc, exp := range map[string]string{"sv": test.sv, "en": test.en} {
+			i := db.GetOrDefault(loc)
+			if w, g := exp, i.formatData().formatRelativeTime(loc, now.Add(test.d), now); w != g {
+				t.Errorf(fs, w, g)
+			}
+		}
+	}
+
+	// built-in data
+	if w, g := "in 2 hours", newI18N("en").formatData().formatRelativeTime("en", now.Add(2*time.Hour), now); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_FormatData_Files(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	for _, loc := range []string{"en", "sv", "sv-FI"} {
+		writePropFile(t, filepath.Join(dir, loc+".properties"), "Users\tUsers\n", now)
+	}
+	// partial format data: other keys are taken from the built-in data
+	writePropFile(t, filepath.Join(dir, "sv.format"), "number.group = .\n", now)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "1.234,5|1.234,5|1,234.5", db.GetOrDefault("sv").FormatNumber(1234.5)+"|"+db.GetOrDefault("sv-FI").FormatNumber(1234.5)+"|"+db.GetOrDefault("en").FormatNumber(1234.5); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// reload
+	writePropFile(t, filepath.Join(dir, "sv.format"), "number.group = \\u00a0\n", now.Add(time.Second))
+	if err := db.Reload(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "1\u00a0234,5", db.GetOrDefault("sv-FI").FormatNumber(1234.5); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// invalid format data
+	writePropFile(t, filepath.Join(dir, "sv.format"), "number.group = .\ndate.months = jan, feb\nrelative.minute.ago[one] = %d minut sedan\nnumbers.decimal = ,\n", now.Add(2*time.Second))
+	f := filepath.Join(dir, "sv.format")
+	exp := []string{
+		f + ":2: expected 12 comma separated names for date.months, found 2",
+		f + ":3: unknown relative time key: relative.minute.ago[one]",
+		f + ":4: unknown format data key: numbers.decimal",
+	}
+	if w, g := strings.Join(exp, "\n"), fmt.Sprintf("%v", db.Reload()); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "1\u00a0234,5", db.GetOrDefault("sv").FormatNumber(1234.5); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	iofs "io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// The functions below read files from fsys, or from the OS file system if fsys is nil (see I18NDB.FS). File names in fsys use forward slashes. NB that io/fs is imported as iofs in this package, since fs is used by the tests.
+
+func readFileFS(fsys iofs.FS, fName string) ([]byte, error) {
+	if fsys == nil {
+		return ioutil.ReadFile(fName)
+	}
+	return iofs.ReadFile(fsys, filepath.ToSlash(fName))
+}
+
+func readLinesFS(fsys iofs.FS, fName string) ([]string, error) {
+	if fsys == nil {
+		return util.ReadLines(fName)
+	}
+	return util.ReadLinesFS(fsys, filepath.ToSlash(fName))
+}
+
+func openFS(fsys iofs.FS, fName string) (iofs.File, error) {
+	if fsys == nil {
+		return os.Open(fName)
+	}
+	return fsys.Open(filepath.ToSlash(fName))
+}
+
+func statFS(fsys iofs.FS, fName string) (iofs.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(fName)
+	}
+	return iofs.Stat(fsys, filepath.ToSlash(fName))
+}
+
+func readDirFS(fsys iofs.FS, dir string) ([]iofs.DirEntry, error) {
+	if fsys == nil {
+		return os.ReadDir(dir)
+	}
+	return iofs.ReadDir(fsys, filepath.ToSlash(dir))
+}
@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stts-se/weblib/util"
+)
+
+var testFS = fstest.MapFS{
+	"i18n/en.properties":      {Data: []byte("Hello\tHello\nLogin\tLogin\n")},
+	"i18n/sv.properties":      {Data: []byte("Hello\tHej\nLogin\tLogga in\n")},
+	"i18n/sv.format":          {Data: []byte("number.decimal = ,\n")},
+	"i18n/en/auth.json":       {Data: []byte(`{"Password": "Password"}`)},
+	"i18n/sv/auth.json":       {Data: []byte(`{"Password": "Lösenord"}`)},
+	"i18n/fi.po":              {Data: []byte("msgid \"\"\nmsgstr \"Language: fi\\n\"\n\nmsgid \"Hello\"\nmsgstr \"Hei\"\n\nmsgid \"Login\"\nmsgstr \"Kirjaudu\"\n")},
+	"i18n/fi/auth.properties": {Data: []byte("Password\tSalasana\n")},
+}
+
+func Test_ReadI18NPropFS(t *testing.T) {
+	db, err := ReadI18NPropFS(testFS, "i18n", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "en fi sv", strings.Join(db.ListLocales(), " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+	sv := db.GetOrDefault("sv")
+	if w, g := "Hej|Lösenord|1,5", strings.Join([]string{sv.S("Hello"), sv.SC("auth", "Password"), sv.FormatNumber(1.5)}, "|"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Kirjaudu", db.GetOrDefault("fi").S("Login"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(msgs); w != g {
+		t.Errorf(fs, w, g)
+		t.Errorf("Unexpected messages: %v", msgs)
+	}
+	if _, err := db.FixKeyOrder(); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+func Test_OverlayFS(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"i18n/sv.properties":      "Hello\tHejsan\nLogin\tLogga in\n",
+		"i18n/nb.properties":      "Hello\tHei\nLogin\tLogg inn\n",
+		"i18n/nb/auth.properties": "Password\tPassord\n",
+	})
+	defer os.RemoveAll(dir)
+
+	db := NewI18NDB("i18n", "en")
+	db.FS = util.NewOverlayFS(os.DirFS(dir), testFS)
+	if err := db.Load(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "en fi nb sv", strings.Join(db.ListLocales(), " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+	// files on disk override the embedded files
+	if w, g := "Hejsan|Lösenord|Hei", strings.Join([]string{db.GetOrDefault("sv").S("Hello"), db.GetOrDefault("sv").SC("auth", "Password"), db.GetOrDefault("nb").S("Hello")}, "|"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// no changes
+	if err := db.Reload(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "Hejsan", db.GetOrDefault("sv").S("Hello"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
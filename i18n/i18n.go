@@ -3,13 +3,14 @@ package i18n
 
 import (
 	"fmt"
-	"io/ioutil"
+	iofs "io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -19,34 +20,108 @@ import (
 )
 
 type dict map[string]string
-type set map[string]bool
 
 // I18N a key-value dictionary container for a certain locale
 type I18N struct {
-	dict   dict
-	locale string
+	dict    dict
+	plurals map[string]dict            // plural variants: key -> plural category -> translation
+	selects map[string]*selectVariants // select variants: key -> selector name and translations by value
+	keys    []string                   // keys in the order of the source file
+	locale  string
+
+	// fallback is used for keys missing in this I18N: the parent locale (e.g. sv for sv-FI), or the default locale
+	fallback *I18N
+	// defaultFallback is true if the fallback is the default locale, rather than a parent locale
+	defaultFallback bool
+
+	// missing collects missing translations (nil for instances not in an I18NDB)
+	missing *missingCollector
+	// request is the request that the instance was retrieved for (see GetI18NFromRequest), if any
+	request *requestInfo
+
+	// translations parsed as ICU MessageFormat messages, by key (including plural category, if any)
+	messages map[string]message
+	// invalid are the translations that are not valid ICU MessageFormat messages, by key: the parse error (they are used as literal text)
+	invalid map[string]string
+
+	// format is the format data read from the format data file of the locale, if any (see formatData)
+	format *formatData
+
+	// meta is the metadata defined in the header of the i18n file of the locale (see LocaleMetadata)
+	meta LocaleMetadata
+
+	// sources are the positions of the keys in the i18n files (used for cross validation findings)
+	sources map[string]keySource
+
+	// attrs are the attributes used to choose between select variants, e.g. gender (see WithAttributes)
+	attrs map[string]string
+
+	// pseudo is true for a pseudo locale synthesized from the default locale (see I18NDB.PseudoLocale)
+	pseudo bool
 }
 
-// S is used to look up the localized version of the input string (s). It will also fill in the arguments (args) using fmt.Sprintf. If LogToTemplate is set to true, any unknown translations will be logged, and can later be saved to a template file.
+// S is used to look up the localized version of the input string (s). It will also fill in the arguments (args) using fmt.Sprintf. If s has select variants, the variant is chosen by the attributes of the instance (see WithAttributes). If the string is not defined for the locale, the fallback chain is searched (e.g. sv-FI, sv, and then the default locale). Strings not translated for the locale are collected (see I18NDB.MissingTranslations).
 func (i *I18N) S(s string, args ...interface{}) string {
-	if LogToTemplate {
-		templateLog.mutex.RLock()
-		defer templateLog.mutex.RUnlock()
-		if _, ok := templateLog.data[i.locale]; !ok {
-			templateLog.data[i.locale] = make(map[string]bool)
-		}
-		templateLog.data[i.locale][s] = true
-	}
+	i.checkMissing(s)
 
-	res := s
-	if r, ok := (*i).dict[s]; ok {
+	res := keyText(s)
+	if r, ok := i.lookup(s); ok {
 		res = r
 	} else {
-		log.Printf("Missing %s localization for input string %s", i.locale, s)
+		log.Printf("Missing %s localization for input string %s", i.locale, displayKey(s))
+	}
+
+	return sprintf(res, args...)
+}
+
+// lookup returns the translation of s (or its "other" plural form, or the select variant chosen by the attributes of the instance), searching the fallback chain
+func (i *I18N) lookup(s string) (string, bool) {
+	for _, loc := range i.fallbackChain() {
+		if _, r, ok := loc.selectVariant(s, i.attrs); ok {
+			return r, true
+		}
+		if r, ok := loc.dict[s]; ok {
+			return r, true
+		}
+		if r, ok := loc.plurals[s][PluralOther]; ok {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// N is used to look up the localized plural form of the input string (s) for the count n, using the CLDR plural rules of the locale. Plural forms are defined in the property files using the plural category in brackets after the key, e.g. "%d users[one]". If no plural form is defined for the category, the "other" form is used, or else the regular translation of s. Plural forms in a message context are looked up using a context key (see ContextKey). The arguments (args) are filled in using fmt.Sprintf; if no args are provided, n is used as the single argument.
+func (i *I18N) N(s string, n int, args ...interface{}) string {
+	i.checkMissing(s)
+
+	res, found := keyText(s), false
+	for _, loc := range i.fallbackChain() {
+		if forms, ok := loc.plurals[s]; ok {
+			if r, ok := forms[PluralCategory(i.pluralLocale(), n)]; ok {
+				res, found = r, true
+			} else if r, ok := forms[PluralOther]; ok {
+				res, found = r, true
+			}
+		} else if r, ok := loc.dict[s]; ok {
+			res, found = r, true
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		log.Printf("Missing %s localization for input string %s", i.locale, displayKey(s))
 	}
 
 	if len(args) == 0 {
-		return res
+		args = []interface{}{n}
+	}
+	return sprintf(res, args...)
+}
+
+func sprintf(s string, args ...interface{}) string {
+	if len(args) == 0 {
+		return s
 	}
 
 	// Flatten incorrectly organized variadic args -- an []interface{} slice with a
@@ -59,36 +134,158 @@ func (i *I18N) S(s string, args ...interface{}) string {
 		args = argsI
 	}
 
-	return fmt.Sprintf(res, args...)
+	return fmt.Sprintf(s, args...)
 }
 
-// newI18N returns a new (empty) I18N dictionary for the specified locale
-func newI18N(locale string) *I18N {
-	return &I18N{dict: make(map[string]string), locale: locale}
+// add a translation to the dictionary. Plural variants (e.g. "%d users[one]") and select variants (e.g. "Welcome, %s![gender=female]") are saved separately. A translation that is not a valid ICU MessageFormat message is used as literal text by M. It is reported by cross validation (see FindingInvalidMessage) only if it contains ICU arguments, so that plain fmt.Sprintf translations with literal braces (e.g. "Brace { test") are still valid. Returns an error if select variants are mixed with other translations of the key.
+func (i *I18N) add(key, value string) error {
+	msg, err := parseMessage(value)
+	delete(i.invalid, key)
+	if err != nil {
+		msg = message{{text: value}}
+		if hasMessageArgs(value) {
+			log.Printf("Invalid message for %s key %s, using it as literal text : %v", i.locale, displayKey(key), err)
+			i.invalid[key] = err.Error()
+		}
+	}
+
+	baseKey, cat := splitPluralKey(key)
+	if base, name, selValue := splitSelectKey(key); name != "" {
+		if err := i.addSelect(base, name, selValue, value); err != nil {
+			return err
+		}
+		baseKey = base
+	} else if _, ok := i.selects[baseKey]; ok {
+		return fmt.Errorf("key %s has both select variants and other translations", baseKey)
+	} else if cat != "" {
+		if _, ok := i.plurals[baseKey]; !ok {
+			i.plurals[baseKey] = make(dict)
+		}
+		i.plurals[baseKey][cat] = value
+	} else {
+		i.dict[key] = value
+	}
+	i.messages[key] = msg
+	if len(i.keys) == 0 || i.keys[len(i.keys)-1] != baseKey {
+		i.keys = append(i.keys, baseKey)
+	}
+	return nil
+}
+
+// pluralCategories returns the plural categories defined for key (a regular translation counts as "other")
+func (i *I18N) pluralCategories(key string) []string {
+	res := []string{}
+	for _, cat := range pluralCategories {
+		if _, ok := i.plurals[key][cat]; ok {
+			res = append(res, cat)
+		} else if _, ok := i.dict[key]; ok && cat == PluralOther {
+			res = append(res, cat)
+		}
+	}
+	return res
+}
+
+// hasKey checks if key is defined, as a regular translation or with plural or select variants
+func (i *I18N) hasKey(key string) bool {
+	if _, ok := i.dict[key]; ok {
+		return true
+	}
+	if _, ok := i.selects[key]; ok {
+		return true
+	}
+	_, ok := i.plurals[key]
+	return ok
+}
+
+var printfVerbRE = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z]`)
+
+// placeholders lists the placeholders of the translation for key: named ICU MessageFormat arguments, and fmt.Sprintf verbs. For plural keys, the "other" form is used (or, if the locale has no such category for integers, its last plural category, e.g. "many"). For select keys, the "other" variant is used (or the first variant in alphabetical order).
+func (i *I18N) placeholders(key string) []string {
+	msgKey, value := key, i.dict[key]
+	if k, v, ok := i.selectVariant(key, nil); ok {
+		msgKey, value = k, v
+	} else if _, ok := i.dict[key]; !ok {
+		cats := append(PluralCategories(i.pluralLocale()), PluralOther)
+		for _, cat := range cats {
+			if v, ok := i.plurals[key][cat]; ok {
+				msgKey, value = key+"["+cat+"]", v
+			}
+		}
+	}
+	res := []string{}
+	for _, name := range i.messages[msgKey].argNames() {
+		res = append(res, "{"+name+"}")
+	}
+	verbs := printfVerbRE.FindAllString(strings.Replace(value, "%%", "", -1), -1)
+	sort.Strings(verbs)
+	return append(res, verbs...)
 }
 
-type templateLogger struct {
-	mutex *sync.RWMutex
-	data  map[string]set
+// allKeys lists all keys defined, including plural and select keys (without categories and select values)
+func (i *I18N) allKeys() []string {
+	res := []string{}
+	for k := range i.dict {
+		res = append(res, k)
+	}
+	for k := range i.selects {
+		res = append(res, k)
+	}
+	for k := range i.plurals {
+		if _, ok := i.dict[k]; !ok {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res
 }
 
-var templateLog = templateLogger{
-	mutex: &sync.RWMutex{},
-	data:  make(map[string]set),
+// newI18N returns a new (empty) I18N dictionary for the specified locale
+func newI18N(locale string) *I18N {
+	return &I18N{dict: make(dict), plurals: make(map[string]dict), selects: make(map[string]*selectVariants), messages: make(map[string]message), invalid: make(map[string]string), sources: make(map[string]keySource), locale: locale}
 }
 
 // I18NDB a mutexed database of I18N instances
 type I18NDB struct {
 	mutex         *sync.RWMutex
+	reloadMutex   *sync.Mutex
 	data          map[string]*I18N
+	tags          map[string]string   // normalised language tag -> locale name
+	files         map[string]fileStat // loaded property files (used by Watch)
+	missing       *missingCollector
 	DefaultLocale string
 	Dir           string
+
+	// Syntax of the property files (default SyntaxTab)
+	Syntax Syntax
+
+	// Sanitizer is used for trusted HTML translations in templates (see FuncMap). HTML translations can't be used unless a Sanitizer is set.
+	Sanitizer Sanitizer
+
+	// Severities of cross validation findings, by kind (see CrossValidateFindings). Kinds not listed use DefaultSeverities.
+	Severities map[FindingKind]Severity
+	// ReferenceLocale is the locale that the other locales are compared with in cross validation (by default, the first locale in alphabetical order)
+	ReferenceLocale string
+
+	// FS is the file system that the i18n files are read from, with Dir as a folder in FS (e.g. an embed.FS, or a util.OverlayFS letting files on disk override embedded files). If nil, the files are read from the OS file system. NB that FixKeyOrder can't rewrite files in FS.
+	FS iofs.FS
+
+	// PseudoLocale is the name of a pseudo locale (e.g. en-XA) synthesized from the default locale when the files are loaded, for testing the user interface (see Pseudolocalize). Empty by default, i.e., no pseudo locale is created. The pseudo locale is not cross validated.
+	PseudoLocale string
+}
+
+// NewI18NDB creates an empty I18NDB for the property files in dir. Set Syntax and FS (if needed), and call Load to read the files.
+func NewI18NDB(dir, defaultLocale string) *I18NDB {
+	return newI18NDB(dir, defaultLocale)
 }
 
 func newI18NDB(dir, defaultLocale string) *I18NDB {
 	return &I18NDB{
 		mutex:         &sync.RWMutex{},
+		reloadMutex:   &sync.Mutex{},
 		data:          make(map[string]*I18N),
+		tags:          make(map[string]string),
+		files:         make(map[string]fileStat),
+		missing:       newMissingCollector(),
 		DefaultLocale: defaultLocale,
 		Dir:           dir,
 	}
@@ -111,20 +308,39 @@ func sortedKeysString2I18N(m map[string]*I18N) []string {
 	return res
 }
 
+func sortedKeysString2String(m map[string]string) []string {
+	res := []string{}
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // ListLocales list all locale (names) in the db
 func (db *I18NDB) ListLocales() []string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
 	return sortedKeysString2I18N(db.data)
 }
 
-// GetOrDefault returns the I18N instance for the locale. If it doesn't exist, the default I18N will be returned.
+// GetOrDefault returns the I18N instance for the locale. If it doesn't exist, the best match is used (see Match). If there is no match, the default I18N will be returned.
 func (db *I18NDB) GetOrDefault(locale string) *I18N {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
 	if loc, ok := db.data[locale]; ok {
 		return loc
 	}
+	if t, err := ParseTag(locale); err == nil {
+		if name, ok := db.match(t); ok {
+			return db.data[name]
+		}
+	}
 	log.Printf("No i18n defined for locale %s, using default locale %s", locale, db.DefaultLocale)
-	return db.Default()
+	if loc, ok := db.data[db.DefaultLocale]; ok {
+		return loc
+	}
+	return newI18N(db.DefaultLocale)
 }
 
 // GetOrCreate returns the I18N instance for the locale. If it doesn't exist, a new, empty locale dictionary will be created (but not saved to cache)
@@ -135,100 +351,311 @@ func (db *I18NDB) GetOrCreate(locale string) *I18N {
 		return loc
 	}
 	log.Printf("No i18n defined for locale %s, creating a new instance on the fly", locale)
-	return newI18N(locale)
+	res := newI18N(locale)
+	res.missing = db.missing
+	return res
 }
 
-func readI18NPropDir(dir string) (map[string]*I18N, error) {
-	res := make(map[string]*I18N)
-	fNames := []string{}
-	files, err := ioutil.ReadDir(dir)
+// isI18NFile checks if the file is an i18n file, i.e., a catalog in a registered format (see CatalogFormats), but not a template
+func isI18NFile(fName string) bool {
+	f, ok := catalogFormatForFile(fName)
+	return ok && !f.Template
+}
+
+// listI18NFiles lists the i18n files in dir, and in its locale subfolders (namespace files, see I18NDB.Load). Files are read from fsys, or from the OS file system if fsys is nil.
+func listI18NFiles(fsys iofs.FS, dir string) ([]string, error) {
+	res := []string{}
+	files, err := readDirFS(fsys, dir)
+	if err != nil {
+		return res, fmt.Errorf("couldn't list files in folder %s : %v", dir, err)
+	}
 	for _, f := range files {
-		fn := f.Name()
 		fullPath := filepath.Join(dir, f.Name())
-		ext := path.Ext(path.Base(fn))
-		if ext != i18nExtension {
+		if !f.IsDir() {
+			if isI18NFile(fullPath) || isFormatDataFile(fullPath) {
+				res = append(res, fullPath)
+			}
+			continue
+		}
+		if strings.HasPrefix(f.Name(), ".") {
 			continue
 		}
-		fNames = append(fNames, fullPath)
+		subFiles, err := readDirFS(fsys, fullPath)
+		if err != nil {
+			return res, fmt.Errorf("couldn't list files in folder %s : %v", fullPath, err)
+		}
+		for _, sf := range subFiles {
+			if subPath := filepath.Join(fullPath, sf.Name()); !sf.IsDir() && isI18NFile(subPath) {
+				res = append(res, subPath)
+			}
+		}
 	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// i18nFileID returns the locale and namespace of an i18n file in dir: <dir>/<locale>.<ext> has no namespace, and <dir>/<locale>/<namespace>.<ext> is a namespace file
+func i18nFileID(dir, fName string) (string, string) {
+	if dir != "" {
+		if rel, err := filepath.Rel(dir, fName); err == nil {
+			if parts := strings.Split(filepath.ToSlash(rel), "/"); len(parts) == 2 {
+				return parts[0], locNameFromFile(parts[1])
+			}
+		}
+	}
+	return locNameFromFile(fName), ""
+}
+
+func readI18NPropDir(fsys iofs.FS, dir string, syntax Syntax) (map[string]*I18N, error) {
+	files, err := listI18NFiles(fsys, dir)
 	if err != nil {
-		return res, fmt.Errorf("couldn't list files in folder %s : %v", dir, err)
+		return make(map[string]*I18N), err
+	}
+	return readI18NPropFiles(fsys, dir, files, syntax)
+}
+
+// checkDuplicateLocales returns an error if several files define the same locale (e.g., sv.properties and sv.json), or the same namespace of a locale
+func checkDuplicateLocales(dir string, files []string) error {
+	seen := make(map[string]string)
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+	for _, f := range sorted {
+		if !isI18NFile(f) {
+			continue
+		}
+		locName, ns := i18nFileID(dir, f)
+		id := locName + "/" + ns
+		if prev, ok := seen[id]; ok {
+			if ns != "" {
+				return fmt.Errorf("namespace %s of locale %s is defined in more than one file: %s and %s", ns, locName, prev, f)
+			}
+			return fmt.Errorf("locale %s is defined in more than one file: %s and %s", locName, prev, f)
+		}
+		seen[id] = f
 	}
-	return readI18NPropFiles(fNames)
+	return nil
 }
-func readI18NPropFiles(files []string) (map[string]*I18N, error) {
+
+// readI18NPropFiles reads i18n files into I18N instances, one per locale. Files in locale subfolders of dir are read as namespaces of the locale. Format data files are read for the locales defined by the i18n files.
+func readI18NPropFiles(fsys iofs.FS, dir string, files []string, syntax Syntax) (map[string]*I18N, error) {
 	res := make(map[string]*I18N)
+	errs := ParseErrors{}
 
-	for _, f := range files {
-		ext := path.Ext(path.Base(f))
-		if ext != i18nExtension {
+	if err := checkDuplicateLocales(dir, files); err != nil {
+		return res, err
+	}
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+	for _, f := range sorted {
+		if !isI18NFile(f) {
+			continue
+		}
+		locName, ns := i18nFileID(dir, f)
+		loc, ok := res[locName]
+		if !ok {
+			loc = newI18N(locName)
+		}
+		err := readI18NFile(fsys, loc, ns, f, syntax)
+		if fileErrs, ok := err.(ParseErrors); ok {
+			errs = append(errs, fileErrs...)
 			continue
 		}
-		locName := strings.TrimSuffix(filepath.Base(f), ext)
-		loc, err := readI18NPropFile(locName, f)
 		if err != nil {
 			return res, err
 		}
 		res[locName] = loc
 	}
+	for _, f := range sorted {
+		if !isFormatDataFile(f) {
+			continue
+		}
+		err := readFormatDataFile(fsys, res, f)
+		if fileErrs, ok := err.(ParseErrors); ok {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
 	return res, nil
 }
 
-func readI18NPropFile(locName, fName string) (*I18N, error) {
-	res := newI18N(locName)
-	lines, err := util.ReadLines(fName)
+// readFormatDataFile reads a format data file (see readFormatData) for its locale in i18ns. Files for undefined locales are ignored.
+func readFormatDataFile(fsys iofs.FS, i18ns map[string]*I18N, fName string) error {
+	locName := strings.TrimSuffix(filepath.Base(fName), formatDataExtension)
+	loc, ok := i18ns[locName]
+	if !ok {
+		log.Printf("No i18n defined for locale %s, ignoring format data file %s", locName, fName)
+		return nil
+	}
+	fd, err := readFormatData(fsys, locName, fName)
 	if err != nil {
-		return res, err
+		return err
+	}
+	loc.format = fd
+	log.Printf("Read format data for locale %s from %s", locName, fName)
+	return nil
+}
+
+// locNameFromFile returns the locale name for an i18n file, i.e., the file name without the extension of the catalog format (or any extension, for unknown formats)
+func locNameFromFile(fName string) string {
+	base := filepath.Base(fName)
+	if f, ok := catalogFormatForFile(fName); ok {
+		return strings.TrimSuffix(base, f.Extension)
 	}
-	for _, l := range lines {
-		if strings.HasPrefix(strings.TrimSpace(l), "#") {
+	return strings.TrimSuffix(base, path.Ext(fName))
+}
+
+// namespaceKey adds the namespace to the message context of a key (e.g., the key Login in section [button] of namespace auth gets the context auth.button)
+func namespaceKey(ns, key string) string {
+	if ns == "" {
+		return key
+	}
+	ctx, text := splitContextKey(key)
+	if ctx == "" {
+		return ContextKey(ns, text)
+	}
+	return ContextKey(ns+"."+ctx, text)
+}
+
+// setMetadata sets the metadata read from an i18n file of the locale. Metadata can only be defined in the main file of the locale, not in namespace files.
+func (i *I18N) setMetadata(ns string, meta LocaleMetadata) error {
+	if meta.IsEmpty() {
+		return nil
+	}
+	if ns != "" {
+		return fmt.Errorf("locale metadata is not allowed in namespace files (namespace %s)", ns)
+	}
+	i.meta = meta
+	return nil
+}
+
+// readI18NFile reads an i18n file in any registered catalog format, and adds the translations (and locale metadata) to loc. The keys of namespace files get the namespace as message context.
+func readI18NFile(fsys iofs.FS, loc *I18N, ns, fName string, syntax Syntax) error {
+	if path.Ext(fName) == i18nExtension {
+		return readI18NPropFile(fsys, loc, ns, fName, syntax)
+	}
+	cat, err := ReadCatalogFS(fsys, fName, syntax)
+	if err != nil {
+		return err
+	}
+	errs := ParseErrors{}
+	if err := loc.setMetadata(ns, cat.Metadata); err != nil {
+		errs = append(errs, &ParseError{File: fName, Msg: err.Error()})
+	}
+	for _, u := range cat.units(false) {
+		key := namespaceKey(ns, u.key)
+		if err := loc.add(key, u.value); err != nil {
+			errs = append(errs, &ParseError{File: fName, Msg: err.Error()})
 			continue
 		}
-		fs := strings.Split(l, "\t")
-		if len(fs) == 2 {
-			res.dict[fs[0]] = fs[1]
+		loc.setSource(key, fName, 0)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	log.Printf("Read locale %s from %s", loc.locale, fName)
+	return nil
+}
+
+func readI18NPropFile(fsys iofs.FS, loc *I18N, ns, fName string, syntax Syntax) error {
+	lines, err := readLinesFS(fsys, fName)
+	if err != nil {
+		return err
+	}
+	meta, lines, err := splitMetadataHeader(fName, lines)
+	errs, _ := err.(ParseErrors)
+	if err := loc.setMetadata(ns, meta); err != nil {
+		errs = append(errs, &ParseError{File: fName, Line: 1, Msg: err.Error()})
+	}
+	entries, err := parseProperties(fName, lines, syntax)
+	entryErrs, _ := err.(ParseErrors)
+	if err != nil && entryErrs == nil {
+		return err
+	}
+	errs = append(errs, entryErrs...)
+	for _, e := range entries {
+		key := namespaceKey(ns, e.key)
+		if err := loc.add(key, e.value); err != nil {
+			errs = append(errs, &ParseError{File: fName, Line: e.line, Msg: err.Error()})
+			continue
 		}
+		loc.setSource(key, fName, e.line)
 	}
-	log.Printf("Read locale %s from %s", locName, fName)
-	return res, nil
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return errs
+	}
+	log.Printf("Read locale %s from %s", loc.locale, fName)
+	return nil
 }
 
-// ReadI18NPropFiles reads i18n property files
-func ReadI18NPropFiles(dir string, files []string, defaultLocale string) (*I18NDB, error) {
-	res := newI18NDB(dir, defaultLocale)
+// Load reads all i18n files in Dir (property files, or any other registered catalog format, see CatalogFormats). Files in locale subfolders of Dir are namespace files: the keys of <Dir>/<locale>/<namespace>.properties get the namespace as message context (see SC). Format data files (<Dir>/<locale>.format) define how numbers, dates and relative times are formatted for the locale (see FormatNumber). Any previously loaded data is replaced. Unlike Reload, the files are not cross validated.
+func (db *I18NDB) Load() error {
+	stats, err := statPropDir(db.FS, db.Dir)
+	if err != nil {
+		return err
+	}
+	i18ns, err := readI18NPropDir(db.FS, db.Dir, db.Syntax)
+	if err != nil {
+		return err
+	}
+	db.setData(i18ns, stats)
+	return nil
+}
 
-	i18ns, err := readI18NPropFiles(files)
+// LoadFiles reads the specified i18n property files, replacing any previously loaded data. The files are not cross validated.
+func (db *I18NDB) LoadFiles(files []string) error {
+	stats, err := statFiles(db.FS, files)
 	if err != nil {
-		return res, err
+		return err
 	}
+	i18ns, err := readI18NPropFiles(db.FS, db.Dir, files, db.Syntax)
+	if err != nil {
+		return err
+	}
+	db.setData(i18ns, stats)
+	return nil
+}
 
-	res.mutex.RLock()
-	defer res.mutex.RUnlock()
-	res.data = i18ns
+func (db *I18NDB) setData(i18ns map[string]*I18N, stats map[string]fileStat) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.addPseudoLocale(i18ns)
+	db.data = i18ns
+	db.files = stats
+	db.linkFallbacks()
+}
 
-	return res, nil
+// ReadI18NPropFiles reads i18n property files (using SyntaxTab)
+func ReadI18NPropFiles(dir string, files []string, defaultLocale string) (*I18NDB, error) {
+	res := NewI18NDB(dir, defaultLocale)
+	return res, res.LoadFiles(files)
 }
 
-// ReadI18NPropDir read all i18n property files in the specified folder
+// ReadI18NPropDir read all i18n files in the specified folder (using SyntaxTab for property files). The format of each file is selected by the file extension (see CatalogFormats). It is an error if two files define the same locale.
 func ReadI18NPropDir(dir, defaultLocale string) (*I18NDB, error) {
-	res := newI18NDB(dir, defaultLocale)
-
-	i18ns, err := readI18NPropDir(dir)
-	if err != nil {
-		return res, err
-	}
-
-	res.mutex.RLock()
-	defer res.mutex.RUnlock()
-	res.data = i18ns
+	res := NewI18NDB(dir, defaultLocale)
+	return res, res.Load()
+}
 
-	return res, nil
+// ReadI18NPropFS reads the i18n files in the folder dir of fsys (e.g. an embed.FS, see I18NDB.FS)
+func ReadI18NPropFS(fsys iofs.FS, dir, defaultLocale string) (*I18NDB, error) {
+	res := NewI18NDB(dir, defaultLocale)
+	res.FS = fsys
+	return res, res.Load()
 }
 
 // StripLocaleRegion set to true will ignore everything after the first dash (-) of a locale string
+//
+// Deprecated: StripLocaleRegion is ignored. Requested locales are matched using BCP 47 fallback chains instead (see I18NDB.Match), so that sv-FI will use sv if there is no sv-FI locale.
 var StripLocaleRegion = true
 
-// LogToTemplate  if set to true, all undefined strings processed by I18N.S will be cached and logged to a template file when the Close function is called
+// LogToTemplate  if set to true, the missing translations (see I18NDB.MissingTranslations) will be logged to template files when the Close function is called. Requests for undefined locales will also get an empty I18N instance for the locale (see GetOrCreate), so that all strings are logged as missing.
 var LogToTemplate = false
 
 // GetLocaleFromRequest retrieves the requested locale from the http.Request. The first return value is the locale name, the second value is the source from which the locale was retrieved (param, cookie or header).
@@ -247,62 +674,70 @@ func GetLocaleFromRequest(r *http.Request) (string, string) {
 	}
 
 	// check header Accept-Language
-	acceptLangs := r.Header["Accept-Language"]
-	if len(acceptLangs) > 0 {
-		return strings.Split(acceptLangs[0], ",")[0], "header"
+	if prefs := ParseAcceptLanguage(strings.Join(r.Header["Accept-Language"], ",")); len(prefs) > 0 {
+		return prefs[0].Tag.String(), "header"
 	}
 	return "", ""
 }
 
-// GetI18NFromRequest will lookup the requested locale, and return the corresponding I18N instance. If the requested locale doesn't exist, the default locale will be returned instead.
+// GetI18NFromRequest will lookup the requested locale, and return the corresponding I18N instance. Locales requested in the Accept-Language header are negotiated by quality value (see ParseAcceptLanguage and Match). If none of the requested locales exist, the default locale will be returned instead. If the locale was already resolved for the request by a LocaleMiddleware, the resolved I18N instance is returned.
 func (db *I18NDB) GetI18NFromRequest(r *http.Request) *I18N {
-	locName, _ := GetLocaleFromRequest(r)
-	if locName != "" {
-		if StripLocaleRegion {
-			locName = strings.Split(locName, "-")[0]
-		}
-		if LogToTemplate {
-			return db.GetOrCreate(locName)
+	if res, ok := I18NFromContext(r.Context()); ok {
+		return res
+	}
+	locName, source := GetLocaleFromRequest(r)
+	return db.requestI18N(r, locName, source)
+}
+
+// requestI18N returns the I18N instance for a locale requested from the source (param, cookie, user or header)
+func (db *I18NDB) requestI18N(r *http.Request, locName, source string) *I18N {
+	if locName == "" {
+		return db.Default()
+	}
+
+	prefs := []Tag{}
+	if source == "header" {
+		for _, wt := range ParseAcceptLanguage(strings.Join(r.Header["Accept-Language"], ",")) {
+			prefs = append(prefs, wt.Tag)
 		}
-		return db.GetOrDefault(locName)
+	} else if t, err := ParseTag(locName); err == nil {
+		prefs = append(prefs, t)
+	}
+	var res *I18N
+	if name, ok := db.Match(prefs...); ok {
+		res = db.GetOrDefault(name)
+	} else if LogToTemplate {
+		res = db.GetOrCreate(locName)
+	} else {
+		res = db.GetOrDefault(locName)
 	}
-	return db.Default()
+	// a copy with the request info, used for collecting missing translations
+	cp := *res
+	cp.request = &requestInfo{path: r.URL.Path, locale: locName}
+	return &cp
 }
 
-// Close i18n nicely. If LogToTemplate is enabled, and the saveDir is non-empty, a template file (template.properties) will be created.
+// Close i18n nicely. If LogToTemplate is enabled, and the saveDir is non-empty, template files (<locale>_template.log) listing the missing translations of each locale will be created (see MissingTranslations).
 func (db *I18NDB) Close() error {
 	if LogToTemplate {
-		templateLog.mutex.Lock()
-		defer templateLog.mutex.Unlock()
-
-		if len(templateLog.data) == 0 {
+		missing := db.MissingTranslations()
+		if len(missing) == 0 {
 			return nil
 		}
 
 		if db.Dir == "" {
 			return fmt.Errorf("empty output dir")
 		}
-		var sortedKeysString2Set = func(m map[string]set) []string {
-			res := []string{}
-			for k := range m {
-				res = append(res, k)
+		byLocale := make(map[string][]MissingTranslation)
+		locales := []string{}
+		for _, m := range missing {
+			if _, ok := byLocale[m.Locale]; !ok {
+				locales = append(locales, m.Locale)
 			}
-
-			sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
-			return res
+			byLocale[m.Locale] = append(byLocale[m.Locale], m)
 		}
-		var sortedKeysSet = func(m set) []string {
-			res := []string{}
-			for k := range m {
-				res = append(res, k)
-			}
 
-			sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
-			return res
-		}
-
-		for _, locale := range sortedKeysString2Set(templateLog.data) {
-			utts := templateLog.data[locale]
+		for _, locale := range locales {
 			templateFileName := path.Join(db.Dir, fmt.Sprintf("%s_template.log", locale))
 			fh, err := os.Create(templateFileName)
 			if err != nil {
@@ -311,8 +746,8 @@ func (db *I18NDB) Close() error {
 			defer fh.Close()
 
 			fmt.Fprintf(fh, "# i18n template for %s generated on %v\n", locale, time.Now().Format("2006-01-02 15:04:05 MST"))
-			for _, from := range sortedKeysSet(utts) {
-				fmt.Fprintf(fh, "%s\n", from)
+			for _, m := range byLocale[locale] {
+				fmt.Fprintf(fh, "%s\n", m.Key)
 			}
 			log.Printf("Saved i18n template to file %s", templateFileName)
 		}
@@ -320,130 +755,269 @@ func (db *I18NDB) Close() error {
 	return nil
 }
 
-// CrossValidate will return true if the files are validated without errors. The second return value is a slice of error messages, if any.
+// CrossValidate will return true if the files are validated without errors. The second return value is a slice of error messages, if any. The locale metadata (fallback locales, text direction and native names) is checked as well. Keys with a message context are compared namespace by namespace (see SC), and keys are printed as "[context] key". Findings with severity ignore (see Severities) are left out. For structured findings, use CrossValidateFindings.
 func (db *I18NDB) CrossValidate() ([]string, error) {
-
+	findings, err := db.CrossValidateFindings()
 	res := []string{}
+	for _, f := range findings {
+		res = append(res, f.Message)
+	}
+	return res, err
+}
+
+// CrossValidateFindings compares the locales (see CrossValidate), and returns the findings, with the severities of the db (see Severities). Findings with severity ignore are left out. The other locales are compared with the ReferenceLocale (by default, the first locale in alphabetical order).
+func (db *I18NDB) CrossValidateFindings() ([]Finding, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	res := []Finding{}
 
-	// 1. Compare loaded I18Ns with pre-cached translation maps (order not preserved)
 	if len(db.data) == 0 {
 		return res, fmt.Errorf("I18N data cache is empty. You need to run ReadI18NPropFile before validating.")
 	}
-	if len(db.data) == 1 {
-		return res, nil
+
+	locs := []string{}
+	for _, loc := range sortedKeysString2I18N(db.data) {
+		if !db.data[loc].pseudo {
+			locs = append(locs, loc)
+		}
 	}
 
-	locs := sortedKeysString2I18N(db.data)
-	ref := db.data[locs[0]]
-	refLoc := ref.locale
-	for _, loc := range locs[1:] {
-		this := db.data[loc]
-		thisLoc := this.locale
+	// 0. Check the metadata of each locale (see LocaleMetadata)
+	res = append(res, db.crossValidateMetadata(locs)...)
 
-		if rL, tL := len(ref.dict), len(this.dict); rL != tL {
-			res = append(res, fmt.Sprintf("mismatching number of items; %s:%d vs. %s:%d", refLoc, rL, thisLoc, tL))
+	// 1. Check that translations are valid ICU MessageFormat messages, and that plural keys define the plural categories required by each locale
+	for _, loc := range locs {
+		this := db.data[loc]
+		for _, key := range sortedKeysString2String(this.invalid) {
+			res = append(res, this.finding(FindingInvalidMessage, "", baseKey(key), fmt.Sprintf("invalid message in %s (%s)\t%s", loc, this.invalid[key], displayKey(key))))
+		}
+		required := PluralCategories(this.pluralLocale())
+		for _, key := range this.allKeys() {
+			if _, isPlural := this.plurals[key]; !isPlural && !db.isPluralKey(key) {
+				continue
+			}
+			defined := this.pluralCategories(key)
+			for _, cat := range required {
+				if !contains(defined, cat) {
+					res = append(res, this.finding(FindingPluralCategory, "", key, fmt.Sprintf("plural key in %s is missing category %s\t%s", loc, cat, displayKey(key))))
+				}
+			}
+			for _, cat := range defined {
+				if !contains(required, cat) {
+					res = append(res, this.finding(FindingUnusedPluralCategory, "", key, fmt.Sprintf("plural key in %s has category %s, not used by the locale\t%s", loc, cat, displayKey(key))))
+				}
+			}
 		}
+	}
 
-		for refKey := range ref.dict {
-			if _, ok := this.dict[refKey]; !ok {
-				res = append(res, fmt.Sprintf("key in %s is not present in %s\t%s", refLoc, thisLoc, refKey))
+	// 2. Regional locales (e.g. sv-FI, when there is an sv locale), and locales with a fallback locale in the metadata, only need to define the keys that differ from their parent (or fallback) locale, so they are checked against the parent only
+	baseLocs := []string{}
+	for _, loc := range locs {
+		this := db.data[loc]
+		parent, ok := db.fallbackLocale(loc)
+		if !ok {
+			baseLocs = append(baseLocs, loc)
+			continue
+		}
+		for _, key := range this.allKeys() {
+			owner := parent
+			seen := map[*I18N]bool{this: true}
+			for owner != nil && !owner.hasKey(key) && !seen[owner] {
+				seen[owner] = true
+				owner, _ = db.fallbackLocale(owner.locale)
+			}
+			if owner != nil && seen[owner] {
+				owner = nil
+			}
+			if owner == nil {
+				res = append(res, this.finding(FindingMissingKey, parent.locale, key, fmt.Sprintf("key in %s is not present in %s\t%s", loc, parent.locale, displayKey(key))))
+				continue
+			}
+			thisPH, ownerPH := this.placeholders(key), owner.placeholders(key)
+			if strings.Join(thisPH, " ") != strings.Join(ownerPH, " ") {
+				res = append(res, this.finding(FindingPlaceholders, owner.locale, key, fmt.Sprintf("mismatching placeholders; %s:%v vs. %s:%v\t%s", owner.locale, ownerPH, loc, thisPH, displayKey(key))))
+			}
+			if thisSel, ownerSel := this.selectInfo(key), owner.selectInfo(key); thisSel != ownerSel {
+				res = append(res, this.finding(FindingSelectValues, owner.locale, key, fmt.Sprintf("mismatching select values; %s:%s vs. %s:%s\t%s", owner.locale, ownerSel, loc, thisSel, displayKey(key))))
 			}
 		}
-		for thisKey := range this.dict {
-			if _, ok := ref.dict[thisKey]; !ok {
-				res = append(res, fmt.Sprintf("key in %s is not present in %s\t%s", thisLoc, refLoc, thisKey))
+	}
+	locs = db.withReferenceFirst(baseLocs)
+
+	if len(locs) > 1 {
+		// Phases 3 and 4 are run for each namespace (message context) separately, so that a namespace missing in a locale is reported only once
+		nss := []string{}
+		for _, loc := range locs {
+			for _, key := range db.data[loc].allKeys() {
+				if ns := keyNamespace(key); !contains(nss, ns) {
+					nss = append(nss, ns)
+				}
 			}
 		}
+		sort.Strings(nss)
+		for _, ns := range nss {
+			res = append(res, db.crossValidateNamespace(locs, ns)...)
+		}
+		log.Printf("Cross validation completed for locales: %v", locs)
+	}
 
+	// Finally: set the severities, and clean out duplicates
+	resUniq := []Finding{}
+	seen := make(map[string]bool)
+	for _, f := range res {
+		f.Severity = db.severity(f.Kind)
+		if f.Severity == SeverityIgnore || seen[f.Message] {
+			continue
+		}
+		seen[f.Message] = true
+		resUniq = append(resUniq, f)
 	}
+	return resUniq, nil
+}
 
-	// 2. Load all keys and compare as list (to keep original order in the file)
+// withReferenceFirst returns the locales with the ReferenceLocale first, if it is one of them
+func (db *I18NDB) withReferenceFirst(locs []string) []string {
+	if db.ReferenceLocale == "" || !contains(locs, db.ReferenceLocale) {
+		return locs
+	}
+	res := []string{db.ReferenceLocale}
+	for _, loc := range locs {
+		if loc != db.ReferenceLocale {
+			res = append(res, loc)
+		}
+	}
+	return res
+}
 
-	// list all i18n property files
-	files, err := ioutil.ReadDir(db.Dir)
-	if err != nil {
-		return res, fmt.Errorf("couldn't list files in folder %s : %v", db.Dir, err)
+// namespaceKeys returns the keys in the namespace ns (i.e., with message context ns), in input order
+func namespaceKeys(keys []string, ns string) []string {
+	res := []string{}
+	for _, k := range keys {
+		if keyNamespace(k) == ns {
+			res = append(res, k)
+		}
 	}
+	return res
+}
 
-	// read all translation keys
-	allKeys := make(map[string][]string)
-	allLocs := []string{}
-	for _, f := range files {
-		fn := f.Name()
-		fPath := filepath.Join(db.Dir, f.Name())
-		ext := path.Ext(path.Base(fn))
-		if ext != i18nExtension {
-			continue
+// crossValidateNamespace runs phases 3 and 4 of CrossValidate for the keys in a namespace, for the (non-regional) locales locs. The first locale defining the namespace is used as reference.
+func (db *I18NDB) crossValidateNamespace(locs []string, ns string) []Finding {
+	res := []Finding{}
+	// nsInfo is added to the messages for namespaced keys
+	nsInfo := ""
+	if ns != "" {
+		nsInfo = fmt.Sprintf(" (namespace %s)", ns)
+	}
+
+	// locales defining the namespace (keys without namespace are compared for all locales)
+	nsLocs := locs
+	if ns != "" {
+		nsLocs = []string{}
+		for _, loc := range locs {
+			if len(namespaceKeys(db.data[loc].allKeys(), ns)) > 0 {
+				nsLocs = append(nsLocs, loc)
+			}
 		}
-		locName := strings.TrimSuffix(fn, ext)
-		lines, err := util.ReadLines(fPath)
-		if err != nil {
-			return res, err
+		for _, loc := range locs {
+			if !contains(nsLocs, loc) {
+				res = append(res, Finding{Kind: FindingMissingNamespace, Locale: loc, Ref: nsLocs[0], Message: fmt.Sprintf("namespace in %s is not present in %s\t%s", nsLocs[0], loc, ns)})
+			}
 		}
+	}
+	if len(nsLocs) <= 1 {
+		return res
+	}
 
-		keys := []string{}
-		for _, l := range lines {
-			if strings.HasPrefix(strings.TrimSpace(l), "#") {
+	// 3. Compare loaded I18Ns (order not preserved)
+	ref := db.data[nsLocs[0]]
+	refLoc := ref.locale
+	for _, loc := range nsLocs[1:] {
+		this := db.data[loc]
+		thisLoc := this.locale
+
+		refKeys, thisKeys := namespaceKeys(ref.allKeys(), ns), namespaceKeys(this.allKeys(), ns)
+		if rL, tL := len(refKeys), len(thisKeys); rL != tL {
+			res = append(res, Finding{Kind: FindingItemCount, Locale: thisLoc, Ref: refLoc, Message: fmt.Sprintf("mismatching number of items%s; %s:%d vs. %s:%d", nsInfo, refLoc, rL, thisLoc, tL)})
+		}
+
+		for _, refKey := range refKeys {
+			if !this.hasKey(refKey) {
+				f := ref.finding(FindingMissingKey, refLoc, refKey, fmt.Sprintf("key in %s is not present in %s\t%s", refLoc, thisLoc, displayKey(refKey)))
+				f.Locale = thisLoc
+				res = append(res, f)
+			}
+		}
+		for _, thisKey := range thisKeys {
+			if !ref.hasKey(thisKey) {
+				f := this.finding(FindingMissingKey, thisLoc, thisKey, fmt.Sprintf("key in %s is not present in %s\t%s", thisLoc, refLoc, displayKey(thisKey)))
+				f.Locale = refLoc
+				res = append(res, f)
+			}
+		}
+
+		for _, key := range refKeys {
+			if !this.hasKey(key) {
 				continue
 			}
-			fs := strings.Split(l, "\t")
-			if len(fs) == 2 {
-				keys = append(keys, fs[0])
+			refPH, thisPH := ref.placeholders(key), this.placeholders(key)
+			if strings.Join(refPH, " ") != strings.Join(thisPH, " ") {
+				res = append(res, this.finding(FindingPlaceholders, refLoc, key, fmt.Sprintf("mismatching placeholders; %s:%v vs. %s:%v\t%s", refLoc, refPH, thisLoc, thisPH, displayKey(key))))
+			}
+			if refSel, thisSel := ref.selectInfo(key), this.selectInfo(key); refSel != thisSel {
+				res = append(res, this.finding(FindingSelectValues, refLoc, key, fmt.Sprintf("mismatching select values; %s:%s vs. %s:%s\t%s", refLoc, refSel, thisLoc, thisSel, displayKey(key))))
 			}
 		}
-		allKeys[locName] = keys
-		allLocs = append(allLocs, locName)
-	}
 
-	// compare all translation keys
-	if len(allLocs) == 0 {
-		return res, fmt.Errorf("no i18n prop files in folder %s", db.Dir)
 	}
 
-	refLoc = allLocs[0]
-	refKeys := allKeys[refLoc]
-	for _, thisLoc := range allLocs[1:] {
-		thisKeys := allKeys[thisLoc]
+	// 4. Compare keys as lists (to check the original order in the files)
+	refKeys := namespaceKeys(ref.keys, ns)
+	for _, thisLoc := range nsLocs[1:] {
+		this := db.data[thisLoc]
+		thisKeys := namespaceKeys(this.keys, ns)
 		if rL, tL := len(refKeys), len(thisKeys); rL != tL {
-			res = append(res, fmt.Sprintf("mismatching number of items; %s:%d vs. %s:%d", refLoc, rL, thisLoc, tL))
+			res = append(res, Finding{Kind: FindingItemCount, Locale: thisLoc, Ref: refLoc, Message: fmt.Sprintf("mismatching number of items%s; %s:%d vs. %s:%d", nsInfo, refLoc, rL, thisLoc, tL)})
 		}
 
 		for i, refKey := range refKeys {
 			if i >= len(thisKeys) {
-				res = append(res, fmt.Sprintf("key no. %d in %s is not present in %s\t%s", (i+1), refLoc, thisLoc, refKey))
+				f := ref.finding(FindingKeyOrder, refLoc, refKey, fmt.Sprintf("key no. %d%s in %s is not present in %s\t%s", (i+1), nsInfo, refLoc, thisLoc, displayKey(refKey)))
+				f.Locale = thisLoc
+				res = append(res, f)
 				continue
 			}
 			thisKey := thisKeys[i]
 			if thisKey != refKey {
-				res = append(res, fmt.Sprintf("mismatching key for line %d (%s vs. %s)\t%s\t%s", (i+1), refLoc, thisLoc, refKey, thisKey))
+				res = append(res, this.finding(FindingKeyOrder, refLoc, thisKey, fmt.Sprintf("mismatching key for line %d%s (%s vs. %s)\t%s\t%s", (i+1), nsInfo, refLoc, thisLoc, displayKey(refKey), displayKey(thisKey))))
 			}
 		}
 		if len(thisKeys) > len(refKeys) {
 			for i, thisKey := range thisKeys {
 				if i >= len(refKeys) {
-					res = append(res, fmt.Sprintf("key no. %d in %s is not present in %s\t%s", (i+1), thisLoc, refLoc, thisKey))
+					res = append(res, this.finding(FindingKeyOrder, refLoc, thisKey, fmt.Sprintf("key no. %d%s in %s is not present in %s\t%s", (i+1), nsInfo, thisLoc, refLoc, displayKey(thisKey))))
 				}
 			}
 		}
 	}
+	return res
+}
 
-	// Finally: clean out duplicates
-	var contains = func(slice []string, s string) bool {
-		for _, s0 := range slice {
-			if s0 == s {
-				return true
-			}
+// isPluralKey checks if key has plural variants in any locale
+func (db *I18NDB) isPluralKey(key string) bool {
+	for _, loc := range db.data {
+		if _, ok := loc.plurals[key]; ok {
+			return true
 		}
-		return false
 	}
+	return false
+}
 
-	resUniq := []string{}
-	for _, msg := range res {
-		if !contains(resUniq, msg) {
-			resUniq = append(resUniq, msg)
+func contains(slice []string, s string) bool {
+	for _, s0 := range slice {
+		if s0 == s {
+			return true
 		}
 	}
-
-	log.Printf("Cross validation completed for locales: %v", allLocs)
-	return resUniq, nil
+	return false
 }
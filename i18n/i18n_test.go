@@ -85,3 +85,102 @@ func Test_ValidateI18NPropFiles_Invalid(t *testing.T) {
 		}
 	}
 }
+
+func Test_PluralCategory(t *testing.T) {
+	for _, test := range []struct {
+		locale string
+		n      int
+		exp    string
+	}{
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 2, PluralOther},
+		{"sv_SE", 1, PluralOne},
+		{"fr", 0, PluralOne},
+		{"fr", 2, PluralOther},
+		{"fr", 1000000, PluralMany},
+		{"fr", 1000001, PluralOther},
+		{"pt", 2000000, PluralMany},
+		{"es", 0, PluralOther},
+		{"es", 1, PluralOne},
+		{"es", 1000000, PluralMany},
+		{"it", 101, PluralOther},
+		{"it", 1000000, PluralMany},
+		{"hi", 1000000, PluralOther},
+		{"ro", 1, PluralOne},
+		{"ro", 0, PluralFew},
+		{"ro", 19, PluralFew},
+		{"ro", 20, PluralOther},
+		{"ro", 101, PluralFew},
+		{"ro", 1000000, PluralOther},
+		{"mo", 2, PluralOther},
+		{"ru", 1, PluralOne},
+		{"ru", 3, PluralFew},
+		{"ru", 5, PluralMany},
+		{"ru", 11, PluralMany},
+		{"ru", 21, PluralOne},
+		{"ru", 22, PluralFew},
+		{"ar", 0, PluralZero},
+		{"ar", 2, PluralTwo},
+		{"ar", 103, PluralFew},
+		{"ar", 111, PluralMany},
+		{"ar", 100, PluralOther},
+		{"ja", 1, PluralOther},
+		{"", 1, PluralOther},
+	} {
+		if exp, got := test.exp, PluralCategory(test.locale, test.n); exp != got {
+			t.Errorf("%s %d: "+fs, test.locale, test.n, exp, got)
+		}
+	}
+}
+
+func Test_I18N_N(t *testing.T) {
+	i18n := newI18N("sv")
+	i18n.add("%d users[one]", "%d användare")
+	i18n.add("%d users[other]", "%d användare totalt")
+	i18n.add("%d files by %s[one]", "%d fil av %[2]s")
+	i18n.add("%d files by %s[other]", "%d filer av %[2]s")
+
+	if exp, got := "1 användare", i18n.N("%d users", 1); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "3 användare totalt", i18n.N("%d users", 3); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "3 användare totalt", i18n.S("%d users", 3); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "2 filer av hanna", i18n.N("%d files by %s", 2, 2, "hanna"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "1 fil av hanna", i18n.N("%d files by %s", 1, 1, "hanna"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := []string{"%d users", "%d files by %s"}, i18n.keys; fmt.Sprintf("%v", exp) != fmt.Sprintf("%v", got) {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_CrossValidate_Plurals(t *testing.T) {
+	en := newI18N("en")
+	en.add("%d users[one]", "%d user")
+	en.add("%d users[other]", "%d users")
+	ru := newI18N("ru")
+	ru.add("%d users[one]", "%d пользователь")
+	ru.add("%d users[few]", "%d пользователя")
+	ja := newI18N("ja")
+	ja.add("%d users", "%d人のユーザー")
+	db := newI18NDB("", "")
+	db.data = map[string]*I18N{"en": en, "ru": ru, "ja": ja}
+
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	exp := []string{
+		"plural key in ru is missing category many\t%d users",
+	}
+	if fmt.Sprintf("%v", exp) != fmt.Sprintf("%v", msgs) {
+		t.Errorf(fs, exp, msgs)
+	}
+}
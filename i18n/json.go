@@ -0,0 +1,229 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"strings"
+)
+
+// jsonMetadataKey is the top level key of the locale metadata object in JSON files, e.g. "@metadata": {"dir": "rtl"} (see LocaleMetadata)
+const jsonMetadataKey = "@metadata"
+
+// ReadJSON reads a JSON catalog: an object mapping keys to translations. Plural forms use the same keys as property files (e.g. "%d users[one]"). In nested JSON, objects can be nested, and the key of a translation is the path of object keys joined by dots (as in i18next and vue-i18n). The locale metadata is read from the top level "@metadata" object, if any. The locale is taken from the file name. The order of the keys is preserved.
+func ReadJSON(fName string, nested bool) (*Catalog, error) {
+	return readJSON(nil, fName, nested)
+}
+
+func readJSON(fsys iofs.FS, fName string, nested bool) (*Catalog, error) {
+	locale := locNameFromFile(fName)
+	data, err := readFileFS(fsys, fName)
+	if err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// lineAt returns the line number of the current position of the decoder
+	lineAt := func() int {
+		return bytes.Count(data[:dec.InputOffset()], []byte("\n")) + 1
+	}
+	parseErr := func(msg string) error {
+		return ParseErrors{&ParseError{File: fName, Line: lineAt(), Msg: msg}}
+	}
+
+	units := []catalogUnit{}
+	meta := LocaleMetadata{}
+	var readObject func(prefix string) error
+	readObject = func(prefix string) error {
+		t, err := dec.Token()
+		if err != nil {
+			return parseErr(err.Error())
+		}
+		if t != json.Delim('{') {
+			return parseErr(fmt.Sprintf("expected object, found %v", t))
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return parseErr(err.Error())
+			}
+			key := prefix + t.(string)
+			if !dec.More() {
+				return parseErr(fmt.Sprintf("missing value for key %s", key))
+			}
+			if key == jsonMetadataKey {
+				line := lineAt()
+				fields := make(map[string]string)
+				if err := dec.Decode(&fields); err != nil {
+					return ParseErrors{&ParseError{File: fName, Line: line, Msg: fmt.Sprintf("invalid metadata : %v", err)}}
+				}
+				names := []string{}
+				for name := range fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					if err := meta.set(name, fields[name]); err != nil {
+						return ParseErrors{&ParseError{File: fName, Line: line, Msg: err.Error()}}
+					}
+				}
+				continue
+			}
+			// peek at the value, without consuming it
+			rest := bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n:")
+			if len(rest) > 0 && rest[0] == '{' {
+				if !nested {
+					return parseErr(fmt.Sprintf("nested objects are not supported in flat JSON (use the .nested.json extension): %s", key))
+				}
+				if err := readObject(key + "."); err != nil {
+					return err
+				}
+				continue
+			}
+			t, err = dec.Token()
+			if err != nil {
+				return parseErr(err.Error())
+			}
+			value, ok := t.(string)
+			if !ok {
+				return parseErr(fmt.Sprintf("expected string value for key %s, found %v", key, t))
+			}
+			units = append(units, catalogUnit{key, value, &CatalogEntry{}})
+		}
+		// closing brace
+		if _, err := dec.Token(); err != nil {
+			return parseErr(err.Error())
+		}
+		return nil
+	}
+	if err := readObject(""); err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &Catalog{Locale: locale}, parseErr("unexpected data after JSON object")
+	}
+	res := newCatalog(locale, units)
+	res.Metadata = meta
+	return res, nil
+}
+
+// jsonString quotes a string as JSON, without escaping HTML characters
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// jsonObject is an ordered JSON object, used for writing nested JSON
+type jsonObject struct {
+	keys     []string
+	values   map[string]string
+	children map[string]*jsonObject
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]string), children: make(map[string]*jsonObject)}
+}
+
+// splitJSONPath splits a key at dots, for nested JSON. Keys with empty parts (such as keys ending with a dot) are not split.
+func splitJSONPath(key string) []string {
+	res := strings.Split(key, ".")
+	for _, p := range res {
+		if p == "" {
+			return []string{key}
+		}
+	}
+	return res
+}
+
+func (o *jsonObject) add(key, value string, nested bool) error {
+	path := []string{key}
+	if nested {
+		path = splitJSONPath(key)
+	}
+	for i, p := range path {
+		_, isValue := o.values[p]
+		child, isChild := o.children[p]
+		if i == len(path)-1 {
+			if isValue || isChild {
+				return fmt.Errorf("duplicate or conflicting key in JSON: %s", key)
+			}
+			o.keys = append(o.keys, p)
+			o.values[p] = value
+			return nil
+		}
+		if isValue {
+			return fmt.Errorf("conflicting key in nested JSON: %s", key)
+		}
+		if !isChild {
+			child = newJSONObject()
+			o.keys = append(o.keys, p)
+			o.children[p] = child
+		}
+		o = child
+	}
+	return nil
+}
+
+func (o *jsonObject) write(w io.Writer, indent string) error {
+	if _, err := fmt.Fprint(w, "{"); err != nil {
+		return err
+	}
+	for i, k := range o.keys {
+		sep := ","
+		if i == len(o.keys)-1 {
+			sep = ""
+		}
+		if _, err := fmt.Fprintf(w, "\n%s  %s: ", indent, jsonString(k)); err != nil {
+			return err
+		}
+		if child, ok := o.children[k]; ok {
+			if err := child.write(w, indent+"  "); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(w, sep); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s%s", jsonString(o.values[k]), sep); err != nil {
+			return err
+		}
+	}
+	if len(o.keys) > 0 {
+		indent = "\n" + indent
+	}
+	_, err := fmt.Fprintf(w, "%s}", indent)
+	return err
+}
+
+// WriteJSON writes the catalog as a (flat or nested) JSON object (see ReadJSON), with the locale metadata (if any) in a top level "@metadata" object. Untranslated and fuzzy entries are left out. Comments and message contexts are not supported in JSON.
+func (c *Catalog) WriteJSON(w io.Writer, nested bool) error {
+	root := newJSONObject()
+	if fields := c.Metadata.fields(); len(fields) > 0 {
+		meta := newJSONObject()
+		for _, f := range fields {
+			meta.add(f.key, f.value, false)
+		}
+		root.keys = append(root.keys, jsonMetadataKey)
+		root.children[jsonMetadataKey] = meta
+	}
+	for _, u := range c.units(false) {
+		if u.entry.Context != "" {
+			return fmt.Errorf("message contexts are not supported in JSON files: %s", u.entry.Key)
+		}
+		if err := root.add(u.key, u.value, nested); err != nil {
+			return err
+		}
+	}
+	if err := root.write(w, ""); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,263 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tag is a BCP 47 language tag, such as sv, sv-FI or sr-Latn-RS. Tags are case insensitive, and are normalised when parsed: the language is lower case, the script title case, and the region upper case. Underscores are accepted as separators (as in sv_FI).
+type Tag struct {
+	Language   string
+	Script     string
+	Region     string
+	Variants   []string
+	Extensions []string // extension and private use subtags, including the singleton (e.g. u-co-phonebk or x-pseudo)
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTag parses a BCP 47 language tag
+func ParseTag(s string) (Tag, error) {
+	res := Tag{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return res, fmt.Errorf("empty language tag")
+	}
+	subtags := strings.Split(strings.Replace(s, "_", "-", -1), "-")
+	for _, st := range subtags {
+		if st == "" || len(st) > 8 || !isAlnum(st) {
+			return res, fmt.Errorf("invalid subtag %s in language tag %s", st, s)
+		}
+	}
+
+	lang := subtags[0]
+	if len(lang) < 2 || !isAlpha(lang) {
+		return res, fmt.Errorf("invalid language %s in language tag %s", lang, s)
+	}
+	res.Language = strings.ToLower(lang)
+
+	i := 1
+	// extended language subtags (e.g. zh-yue) are not used for matching
+	for ; i < len(subtags) && i < 4 && len(subtags[i]) == 3 && isAlpha(subtags[i]); i++ {
+	}
+	if i < len(subtags) && len(subtags[i]) == 4 && isAlpha(subtags[i]) {
+		res.Script = strings.ToUpper(subtags[i][:1]) + strings.ToLower(subtags[i][1:])
+		i++
+	}
+	if i < len(subtags) && (len(subtags[i]) == 2 && isAlpha(subtags[i]) || len(subtags[i]) == 3 && isDigit(subtags[i])) {
+		res.Region = strings.ToUpper(subtags[i])
+		i++
+	}
+	for ; i < len(subtags) && len(subtags[i]) > 1; i++ {
+		st := strings.ToLower(subtags[i])
+		if len(st) < 5 && !(len(st) == 4 && isDigit(st[:1])) {
+			return res, fmt.Errorf("invalid subtag %s in language tag %s", subtags[i], s)
+		}
+		res.Variants = append(res.Variants, st)
+	}
+	for i < len(subtags) {
+		// singleton, followed by one or more subtags
+		ext := []string{strings.ToLower(subtags[i])}
+		private := ext[0] == "x"
+		i++
+		for ; i < len(subtags) && (private || len(subtags[i]) > 1); i++ {
+			ext = append(ext, strings.ToLower(subtags[i]))
+		}
+		if len(ext) == 1 {
+			return res, fmt.Errorf("empty extension %s in language tag %s", ext[0], s)
+		}
+		res.Extensions = append(res.Extensions, strings.Join(ext, "-"))
+	}
+	return res, nil
+}
+
+// String returns the normalised tag, using dashes as separators
+func (t Tag) String() string {
+	fs := []string{t.Language}
+	for _, st := range []string{t.Script, t.Region} {
+		if st != "" {
+			fs = append(fs, st)
+		}
+	}
+	fs = append(fs, t.Variants...)
+	fs = append(fs, t.Extensions...)
+	return strings.Join(fs, "-")
+}
+
+// Parent returns the tag with the last subtag removed, in the order extensions, variants, region, script: sv-Latn-FI has the parent sv-Latn, which has the parent sv. The second return value is false if the tag has no parent (i.e., it's only a language).
+func (t Tag) Parent() (Tag, bool) {
+	res := Tag{Language: t.Language, Script: t.Script, Region: t.Region}
+	switch {
+	case len(t.Extensions) > 0:
+		res.Variants = t.Variants
+		res.Extensions = t.Extensions[:len(t.Extensions)-1]
+	case len(t.Variants) > 0:
+		res.Variants = t.Variants[:len(t.Variants)-1]
+	case t.Region != "":
+		res.Region = ""
+	case t.Script != "":
+		res.Script = ""
+	default:
+		return t, false
+	}
+	return res, true
+}
+
+// Chain returns the fallback chain of the tag, starting with the tag itself, and ending with the language (e.g. sv-Latn-FI, sv-Latn, sv)
+func (t Tag) Chain() []Tag {
+	res := []Tag{t}
+	for p, ok := t.Parent(); ok; p, ok = p.Parent() {
+		res = append(res, p)
+	}
+	return res
+}
+
+// WeightedTag is a language tag with a quality value, as used in the Accept-Language header
+type WeightedTag struct {
+	Tag Tag
+	Q   float64
+}
+
+// ParseAcceptLanguage parses the value of an Accept-Language header, such as "sv-FI, sv;q=0.9, en;q=0.5". The tags are returned in order of preference (highest quality value first; for equal values, in the order of the header). Invalid entries, the wildcard (*), and tags with the quality value 0 are left out.
+func ParseAcceptLanguage(header string) []WeightedTag {
+	res := []WeightedTag{}
+	for _, entry := range strings.Split(header, ",") {
+		fs := strings.Split(entry, ";")
+		q := 1.0
+		for _, param := range fs[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+			}
+		}
+		name := strings.TrimSpace(fs[0])
+		if q == 0 || name == "*" {
+			continue
+		}
+		tag, err := ParseTag(name)
+		if err != nil {
+			continue
+		}
+		res = append(res, WeightedTag{Tag: tag, Q: q})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Q > res[j].Q })
+	return res
+}
+
+// Match returns the name of the best available locale for the preferred tags (in order of preference). For each preferred tag, its fallback chain is tried first (so sv-FI matches sv-FI, or else sv), and then a locale with the same language (so sv matches sv-SE), before the next preferred tag is tried. The second return value is false if no locale matched.
+func (db *I18NDB) Match(prefs ...Tag) (string, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return db.match(prefs...)
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) match(prefs ...Tag) (string, bool) {
+	for _, pref := range prefs {
+		for _, t := range pref.Chain() {
+			if loc, ok := db.tags[t.String()]; ok {
+				return loc, true
+			}
+		}
+		for _, loc := range sortedKeysString2I18N(db.data) {
+			if t, err := ParseTag(loc); err == nil && t.Language == pref.Language {
+				return loc, true
+			}
+		}
+	}
+	return "", false
+}
+
+// linkFallbacks sets the fallback of each I18N in the data cache: the fallback locale defined in the metadata (see LocaleMetadata), the closest parent locale in the fallback chain (see Tag.Chain), or else the default locale. The default locale is not used as the fallback of its own parent locales (e.g. sv, if the default locale is sv-FI), since that would be a cycle. NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) linkFallbacks() {
+	db.tags = make(map[string]string)
+	for name := range db.data {
+		if t, err := ParseTag(name); err == nil {
+			db.tags[t.String()] = name
+		}
+	}
+	for name, loc := range db.data {
+		loc.fallback, loc.defaultFallback = nil, false
+		loc.missing = db.missing
+		if parent, ok := db.fallbackLocale(name); ok {
+			loc.fallback = parent
+		} else if def, ok := db.defaultFallback(name); ok {
+			loc.fallback, loc.defaultFallback = def, true
+		}
+	}
+}
+
+// defaultFallback returns the default locale, if it can be used as the fallback of the locale: not for the default locale itself, or for a locale in the fallback chain of the default locale (see Tag.Chain). NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) defaultFallback(name string) (*I18N, bool) {
+	def, ok := db.data[db.DefaultLocale]
+	if !ok || name == db.DefaultLocale {
+		return nil, false
+	}
+	t, err := ParseTag(name)
+	if err != nil {
+		return def, true
+	}
+	if defTag, err := ParseTag(db.DefaultLocale); err == nil {
+		for _, p := range defTag.Chain() {
+			if p.String() == t.String() {
+				return nil, false
+			}
+		}
+	}
+	return def, true
+}
+
+// fallbackChain lists the instance followed by its fallbacks, in lookup order. The chain ends before a repeated locale, so that a fallback cycle can't cause an endless loop.
+func (i *I18N) fallbackChain() []*I18N {
+	res := []*I18N{}
+	seen := make(map[*I18N]bool)
+	for loc := i; loc != nil && !seen[loc]; loc = loc.fallback {
+		seen[loc] = true
+		res = append(res, loc)
+	}
+	return res
+}
+
+// parentLocale returns the closest parent of the locale in the data cache (not including the default locale). NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) parentLocale(name string) (*I18N, bool) {
+	t, err := ParseTag(name)
+	if err != nil {
+		return nil, false
+	}
+	for _, p := range t.Chain()[1:] {
+		if parent, ok := db.tags[p.String()]; ok {
+			return db.data[parent], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,228 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ParseTag(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		exp   string
+	}{
+		{"sv", "sv"},
+		{"SV_fi", "sv-FI"},
+		{"sr-latn-rs", "sr-Latn-RS"},
+		{"zh-yue-HK", "zh-HK"},
+		{"es-419", "es-419"},
+		{"de-CH-1901", "de-CH-1901"},
+		{"en-US-u-ca-gregory-x-pseudo", "en-US-u-ca-gregory-x-pseudo"},
+	} {
+		tag, err := ParseTag(test.input)
+		if err != nil {
+			t.Errorf("Unexpected error : %v", err)
+		}
+		if exp, got := test.exp, tag.String(); exp != got {
+			t.Errorf(fs, exp, got)
+		}
+	}
+
+	for _, input := range []string{"", "s", "sv-", "sv-FI-ab", "sv--FI", "sv-u", "sv-abcdefghi", "sv/FI"} {
+		if tag, err := ParseTag(input); err == nil {
+			t.Errorf("Expected error for %s, got %v", input, tag)
+		}
+	}
+}
+
+func Test_Tag_Chain(t *testing.T) {
+	tag, _ := ParseTag("sr-Latn-RS")
+	if exp, got := "[sr-Latn-RS sr-Latn sr]", fmt.Sprintf("%v", tag.Chain()); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_ParseAcceptLanguage(t *testing.T) {
+	prefs := ParseAcceptLanguage("en;q=0.5, sv-FI, *;q=0.1, fr;q=0, sv;q=0.9, xx-;q=0.8, de;q=0.5")
+	got := []string{}
+	for _, wt := range prefs {
+		got = append(got, fmt.Sprintf("%s:%v", wt.Tag, wt.Q))
+	}
+	if exp := "[sv-FI:1 sv:0.9 en:0.5 de:0.5]"; exp != fmt.Sprintf("%v", got) {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func testDB() *I18NDB {
+	db := newI18NDB("", "en")
+	for _, loc := range []*I18N{newI18N("en"), newI18N("sv"), newI18N("sv_FI"), newI18N("pt-BR")} {
+		db.data[loc.locale] = loc
+	}
+	db.data["en"].add("Email", "Email")
+	db.data["en"].add("Password", "Password")
+	db.data["en"].add("Users", "Users")
+	db.data["sv"].add("Email", "E-post")
+	db.data["sv"].add("Password", "Lösenord")
+	db.data["sv_FI"].add("Email", "E-postadress")
+	db.data["pt-BR"].add("Email", "E-mail")
+	db.linkFallbacks()
+	return db
+}
+
+func Test_I18NDB_Match(t *testing.T) {
+	db := testDB()
+	for _, test := range []struct {
+		prefs string
+		exp   string
+	}{
+		{"sv-FI", "sv_FI"},
+		{"sv-SE", "sv"},
+		{"sv-Latn-FI", "sv"},
+		{"fr, sv;q=0.5", "sv"},
+		{"pt", "pt-BR"},
+		{"pt-PT, en;q=0.9", "pt-BR"},
+		{"fr", ""},
+	} {
+		tags := []Tag{}
+		for _, wt := range ParseAcceptLanguage(test.prefs) {
+			tags = append(tags, wt.Tag)
+		}
+		got, _ := db.Match(tags...)
+		if exp := test.exp; exp != got {
+			t.Errorf("%s: "+fs, test.prefs, exp, got)
+		}
+	}
+}
+
+func Test_I18NDB_Match_LanguageBeforeNextPreference(t *testing.T) {
+	db := newI18NDB("", "sv")
+	for _, loc := range []*I18N{newI18N("en-US"), newI18N("sv")} {
+		db.data[loc.locale] = loc
+	}
+	db.linkFallbacks()
+	tags := []Tag{}
+	for _, wt := range ParseAcceptLanguage("en-GB;q=1, sv;q=0.9") {
+		tags = append(tags, wt.Tag)
+	}
+	got, _ := db.Match(tags...)
+	if exp := "en-US"; exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_I18N_Fallback(t *testing.T) {
+	db := testDB()
+	fi := db.GetOrDefault("sv_FI")
+	if exp, got := "E-postadress", fi.S("Email"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Lösenord", fi.S("Password"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Users", fi.S("Users"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "sv", db.GetOrDefault("sv-SE").locale; exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "en", db.GetOrDefault("fr").locale; exp != got {
+		t.Errorf(fs, exp, got)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr-FR, sv-FI;q=0.8, en;q=0.5")
+	if exp, got := "sv_FI", db.GetI18NFromRequest(r).locale; exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	r = httptest.NewRequest("GET", "/?locale=sv-SE", nil)
+	r.Header.Set("Accept-Language", "en")
+	if exp, got := "sv", db.GetI18NFromRequest(r).locale; exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_I18N_Fallback_RegionalDefault(t *testing.T) {
+	// the default locale is not the fallback of its own parent locale, which would be a cycle (sv -> sv-FI -> sv)
+	dir := writeTestFiles(t, map[string]string{
+		"sv-FI.properties": "Email\tE-post\n%d users[one]\t%d användare\n%d users[other]\t%d användare\n",
+		"sv.properties":    "Email\tE-postadress\nPassword\tLösenord\n",
+	})
+	defer os.RemoveAll(dir)
+
+	db, err := ReadI18NPropDir(dir, "sv-FI")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	sv, fi := db.GetOrDefault("sv"), db.GetOrDefault("sv-FI")
+	if sv.fallback != nil {
+		t.Errorf("Expected no fallback for sv, got %s", sv.fallback.locale)
+	}
+	if exp, got := "Lösenord", fi.S("Password"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Users", sv.S("Users"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "2 users", sv.N("%d users", 2); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "{n} files", sv.M("{n} files", nil); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if _, err := db.CrossValidateFindings(); err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	if exp, got := 2, len(db.Stats()); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+
+	// a fallback cycle ends the fallback chain
+	sv.fallback, fi.fallback = fi, sv
+	if exp, got := 2, len(sv.fallbackChain()); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Users", sv.S("Users"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "2 apples", fi.N("%d apples", 2); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_CrossValidate_Regional(t *testing.T) {
+	db := testDB()
+	delete(db.data, "pt-BR")
+	db.data["en"].add("Users", "Users")
+	db.data["sv"].add("Users", "Användare")
+	db.data["sv_FI"].add("Logged in as %s", "Inloggad som %s")
+	db.linkFallbacks()
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	exp := []string{"key in sv_FI is not present in sv\tLogged in as %s"}
+	if fmt.Sprintf("%v", exp) != fmt.Sprintf("%v", msgs) {
+		t.Errorf(fs, exp, msgs)
+	}
+}
+
+// writeTestFiles writes the files (name -> content) to a new temporary directory, and returns the directory
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "i18n-test")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for name, content := range files {
+		fName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fName), 0755); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+		if err := ioutil.WriteFile(fName, []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,427 @@
+package i18n
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// message is a parsed ICU MessageFormat message, i.e., a sequence of literal text and argument placeholders.
+//
+// Supported argument types:
+//
+//	{name}
+//	{name, number}, {name, number, integer}, {name, number, percent}
+//	{name, date}, {name, date, short|medium|long|full}
+//	{name, time}, {name, time, short|medium|long|full}
+//	{name, select, male {...} female {...} other {...}}
+//	{name, plural, offset:1 =0 {...} one {...} other {...}}
+//
+// Inside a plural argument, # is replaced by the (formatted) count, minus the offset. Apostrophes are used for quoting, as in ICU: a doubled apostrophe is a literal apostrophe, and an apostrophe followed by a special character ({, }, # or |) starts a quoted literal, ending at the next single apostrophe. Other apostrophes are literal.
+type message []msgNode
+
+type msgNode struct {
+	text string // literal text (if arg is empty and hash is false)
+	hash bool   // # inside a plural argument
+
+	arg     string // argument name
+	argType string // "", number, date, time, select or plural
+	style   string // number, date and time style
+	offset  int    // plural offset
+	options map[string]message
+}
+
+var numberStyles = map[string]bool{"": true, "integer": true, "percent": true}
+var dateStyles = map[string]bool{"": true, "short": true, "medium": true, "long": true, "full": true}
+
+type msgParser struct {
+	src []rune
+	pos int
+}
+
+// messageArgRE matches the start of an ICU MessageFormat argument, e.g. "{name}" or "{count, plural,"
+var messageArgRE = regexp.MustCompile(`\{\s*[\p{L}_][\p{L}\p{N}_]*\s*[,}]`)
+
+// hasMessageArgs checks if s contains ICU MessageFormat arguments, i.e., if it is meant to be used with M rather than as a plain (fmt.Sprintf) translation
+func hasMessageArgs(s string) bool {
+	return messageArgRE.MatchString(s)
+}
+
+// parseMessage parses a string in the ICU MessageFormat syntax
+func parseMessage(s string) (message, error) {
+	p := &msgParser{src: []rune(s)}
+	res, err := p.parse(0, false)
+	if err != nil {
+		return res, err
+	}
+	if p.pos < len(p.src) {
+		return res, fmt.Errorf("unmatched } at position %d", p.pos)
+	}
+	return res, nil
+}
+
+func (p *msgParser) peek() rune {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *msgParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// token reads a name, type, style or selector, ending at white space or a special character
+func (p *msgParser) token() string {
+	start := p.pos
+	for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) && !strings.ContainsRune("{},", p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *msgParser) expect(r rune) error {
+	p.skipSpace()
+	if p.peek() != r {
+		if p.pos >= len(p.src) {
+			return fmt.Errorf("expected %c at end of message", r)
+		}
+		return fmt.Errorf("expected %c at position %d, found %c", r, p.pos, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *msgParser) parse(depth int, inPlural bool) (message, error) {
+	res := message{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			res = append(res, msgNode{text: text.String()})
+			text.Reset()
+		}
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.pos++
+			next := p.peek()
+			switch {
+			case next == '\'':
+				text.WriteRune('\'')
+				p.pos++
+			case strings.ContainsRune("{}#|", next) && next != 0:
+				// quoted literal, up to the next single apostrophe
+				for p.pos < len(p.src) {
+					if p.src[p.pos] == '\'' {
+						if p.pos+1 < len(p.src) && p.src[p.pos+1] == '\'' {
+							text.WriteRune('\'')
+							p.pos += 2
+							continue
+						}
+						p.pos++
+						break
+					}
+					text.WriteRune(p.src[p.pos])
+					p.pos++
+				}
+			default:
+				text.WriteRune('\'')
+			}
+		case c == '{':
+			flush()
+			p.pos++
+			node, err := p.parseArg(depth, inPlural)
+			if err != nil {
+				return res, err
+			}
+			res = append(res, node)
+		case c == '}':
+			if depth == 0 {
+				return res, fmt.Errorf("unmatched } at position %d", p.pos)
+			}
+			flush()
+			return res, nil
+		case c == '#' && inPlural:
+			flush()
+			res = append(res, msgNode{hash: true})
+			p.pos++
+		default:
+			text.WriteRune(c)
+			p.pos++
+		}
+	}
+	if depth > 0 {
+		return res, fmt.Errorf("unmatched { at end of message")
+	}
+	flush()
+	return res, nil
+}
+
+// parseArg parses an argument, starting after the opening brace
+func (p *msgParser) parseArg(depth int, inPlural bool) (msgNode, error) {
+	res := msgNode{}
+	p.skipSpace()
+	res.arg = p.token()
+	if res.arg == "" {
+		return res, fmt.Errorf("missing argument name at position %d", p.pos)
+	}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return res, nil
+	}
+	if err := p.expect(','); err != nil {
+		return res, err
+	}
+	p.skipSpace()
+	res.argType = p.token()
+	p.skipSpace()
+
+	switch res.argType {
+	case "number", "date", "time":
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpace()
+			res.style = p.token()
+		}
+		if err := p.expect('}'); err != nil {
+			return res, err
+		}
+		styles := dateStyles
+		if res.argType == "number" {
+			styles = numberStyles
+		}
+		if !styles[res.style] {
+			return res, fmt.Errorf("unknown %s style for argument %s: %s", res.argType, res.arg, res.style)
+		}
+		return res, nil
+	case "select", "plural":
+		if err := p.expect(','); err != nil {
+			return res, err
+		}
+		return res, p.parseOptions(&res, depth, inPlural || res.argType == "plural")
+	case "":
+		return res, fmt.Errorf("missing argument type for argument %s", res.arg)
+	default:
+		return res, fmt.Errorf("unsupported argument type for argument %s: %s", res.arg, res.argType)
+	}
+}
+
+// parseOptions parses the options of a select or plural argument, including the closing brace
+func (p *msgParser) parseOptions(node *msgNode, depth int, inPlural bool) error {
+	node.options = make(map[string]message)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return fmt.Errorf("unmatched { at end of message")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			break
+		}
+		selector := p.token()
+		if selector == "" {
+			return fmt.Errorf("missing selector for argument %s at position %d", node.arg, p.pos)
+		}
+		if node.argType == "plural" && strings.HasPrefix(selector, "offset:") {
+			if len(node.options) > 0 {
+				return fmt.Errorf("plural offset must precede the options of argument %s", node.arg)
+			}
+			offset, err := strconv.Atoi(strings.TrimPrefix(selector, "offset:"))
+			if err != nil {
+				return fmt.Errorf("invalid plural offset for argument %s: %s", node.arg, selector)
+			}
+			node.offset = offset
+			continue
+		}
+		if node.argType == "plural" && !isPluralCategory(selector) {
+			if _, err := strconv.Atoi(strings.TrimPrefix(selector, "=")); err != nil || !strings.HasPrefix(selector, "=") {
+				return fmt.Errorf("invalid plural selector for argument %s: %s", node.arg, selector)
+			}
+		}
+		if _, ok := node.options[selector]; ok {
+			return fmt.Errorf("duplicate selector for argument %s: %s", node.arg, selector)
+		}
+		if err := p.expect('{'); err != nil {
+			return err
+		}
+		sub, err := p.parse(depth+1, inPlural)
+		if err != nil {
+			return err
+		}
+		if err := p.expect('}'); err != nil {
+			return err
+		}
+		node.options[selector] = sub
+	}
+	if _, ok := node.options["other"]; !ok {
+		return fmt.Errorf("missing 'other' option for argument %s", node.arg)
+	}
+	return nil
+}
+
+// argNames lists the (unique) argument names used in the message, sorted alphabetically
+func (m message) argNames() []string {
+	seen := make(map[string]bool)
+	var collect func(m message)
+	collect = func(m message) {
+		for _, n := range m {
+			if n.arg != "" {
+				seen[n.arg] = true
+			}
+			for _, opt := range n.options {
+				collect(opt)
+			}
+		}
+	}
+	collect(m)
+	res := []string{}
+	for name := range seen {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// format formats the message for the locale (using the format data fd), with the named arguments. Missing or invalid arguments are left as placeholders in the output, and reported in the returned error.
+func (m message) format(locale string, fd *formatData, args map[string]interface{}) (string, error) {
+	var b strings.Builder
+	errs := []string{}
+	m.write(&b, locale, fd, args, nil, &errs)
+	if len(errs) > 0 {
+		return b.String(), fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return b.String(), nil
+}
+
+// write writes the formatted message to b. The count is the value for #, if inside a plural argument.
+func (m message) write(b *strings.Builder, locale string, fd *formatData, args map[string]interface{}, count interface{}, errs *[]string) {
+	for _, n := range m {
+		switch {
+		case n.hash:
+			b.WriteString(fd.formatNumber(count, ""))
+		case n.arg == "":
+			b.WriteString(n.text)
+		default:
+			v, ok := args[n.arg]
+			if !ok {
+				*errs = append(*errs, fmt.Sprintf("missing argument %s", n.arg))
+				fmt.Fprintf(b, "{%s}", n.arg)
+				continue
+			}
+			if err := n.write(b, locale, fd, args, v, count, errs); err != nil {
+				*errs = append(*errs, err.Error())
+				fmt.Fprintf(b, "{%s}", n.arg)
+			}
+		}
+	}
+}
+
+// write writes the formatted argument node to b, with the argument value v. The count is the value for # of an enclosing plural argument, if any (passed on to select options).
+func (n msgNode) write(b *strings.Builder, locale string, fd *formatData, args map[string]interface{}, v interface{}, count interface{}, errs *[]string) error {
+	switch n.argType {
+	case "":
+		if t, ok := v.(time.Time); ok {
+			b.WriteString(fd.formatDate(t, "short") + " " + fd.formatTime(t, "short"))
+		} else if _, ok := toFloat(v); ok {
+			b.WriteString(fd.formatNumber(v, ""))
+		} else {
+			fmt.Fprint(b, v)
+		}
+	case "number":
+		if _, ok := toFloat(v); !ok {
+			return fmt.Errorf("argument %s is not a number: %v", n.arg, v)
+		}
+		b.WriteString(fd.formatNumber(v, n.style))
+	case "date", "time":
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("argument %s is not a time.Time: %v", n.arg, v)
+		}
+		if n.argType == "date" {
+			b.WriteString(fd.formatDate(t, n.style))
+		} else {
+			b.WriteString(fd.formatTime(t, n.style))
+		}
+	case "select":
+		opt, ok := n.options[fmt.Sprint(v)]
+		if !ok {
+			opt = n.options["other"]
+		}
+		opt.write(b, locale, fd, args, count, errs)
+	case "plural":
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("argument %s is not a number: %v", n.arg, v)
+		}
+		opt, ok := n.options["="+strconv.FormatFloat(f, 'f', -1, 64)]
+		if !ok {
+			cat := PluralOther
+			if rest := f - float64(n.offset); rest == float64(int(rest)) {
+				cat = PluralCategory(locale, int(rest))
+			}
+			if opt, ok = n.options[cat]; !ok {
+				opt = n.options["other"]
+			}
+		}
+		var optCount interface{} = f - float64(n.offset)
+		if n.offset == 0 {
+			optCount = v
+		}
+		opt.write(b, locale, fd, args, optCount, errs)
+	}
+	return nil
+}
+
+// message returns the parsed message for s (or its "other" plural form, or the select variant chosen by attrs), searching the fallback chain
+func (i *I18N) message(s string, attrs map[string]string) (message, bool) {
+	for _, loc := range i.fallbackChain() {
+		if k, _, ok := loc.selectVariant(s, attrs); ok {
+			return loc.messages[k], true
+		}
+		if msg, ok := loc.messages[s]; ok {
+			return msg, true
+		}
+		if msg, ok := loc.messages[s+"["+PluralOther+"]"]; ok {
+			return msg, true
+		}
+	}
+	return nil, false
+}
+
+// M is used to look up the localized version of the input message (s), and format it as an ICU MessageFormat message (see https://unicode-org.github.io/icu/userguide/format_parse/messages/) using the named arguments (args). Numbers and dates are formatted according to the locale. For keys with select variants (see WithAttributes), the variant is chosen by the named argument with the name of the selector, if it is a string, or else by the attributes of the instance.
+func (i *I18N) M(s string, args map[string]interface{}) string {
+	i.checkMissing(s)
+
+	// select variants are chosen by the (string) named argument with the name of the selector, or else by the attributes of the instance
+	attrs := i.Attributes()
+	if name, ok := i.selectorName(s); ok {
+		if v, ok := args[name].(string); ok {
+			attrs[name] = v
+		}
+	}
+	msg, ok := i.message(s, attrs)
+	if !ok {
+		log.Printf("Missing %s localization for input string %s", i.locale, displayKey(s))
+		var err error
+		if msg, err = parseMessage(keyText(s)); err != nil {
+			log.Printf("Invalid message %s : %v", s, err)
+			return s
+		}
+	}
+
+	res, err := msg.format(i.pluralLocale(), i.formatData(), args)
+	if err != nil {
+		log.Printf("Couldn't format %s message %s : %v", i.locale, s, err)
+	}
+	return res
+}
@@ -0,0 +1,165 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_I18N_M(t *testing.T) {
+	i18n := newI18N("sv")
+	for k, v := range map[string]string{
+		"Hello, {name}!": "Hej, {name}!",
+		"{count, plural, one {# file} other {# files}}":                                      "{count, plural, =0 {inga filer} one {# fil} other {# filer}}",
+		"{gender, select, female {She} male {He} other {They}} liked it":                     "{gender, select, female {Hon} male {Han} other {Hen}} gillade det",
+		"{n, number} bytes ({p, number, percent})":                                           "{n, number} byte ({p, number, percent})",
+		"Updated on {d, date, long} at {d, time, short}":                                     "Uppdaterad {d, date, long} kl. {d, time, short}",
+		"{host} and {guests, plural, offset:1 =0 {nobody} one {one other} other {# others}}": "{host} och {guests, plural, offset:1 =0 {ingen} one {en till} other {# till}}",
+		"Don't use '{braces}'": "Använd inte '{klamrar}'",
+		"{n, plural, one {# new message from {g, select, female {her} other {them}}} other {# new messages from {g, select, female {her} other {them}}}}": "{n, plural, one {# nytt meddelande {g, select, female {från henne} other {# st, från dem}}} other {{g, select, female {# nya meddelanden från henne} other {# nya meddelanden från dem}}}}",
+	} {
+		if err := i18n.add(k, v); err != nil {
+			t.Errorf("Unexpected error : %v", err)
+		}
+	}
+	d := time.Date(2021, time.March, 4, 15, 30, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		key  string
+		args map[string]interface{}
+		exp  string
+	}{
+		{"Hello, {name}!", map[string]interface{}{"name": "hanna"}, "Hej, hanna!"},
+		{"Hello, {name}!", map[string]interface{}{}, "Hej, {name}!"},
+		{"{count, plural, one {# file} other {# files}}", map[string]interface{}{"count": 0}, "inga filer"},
+		{"{count, plural, one {# file} other {# files}}", map[string]interface{}{"count": 1}, "1 fil"},
+		{"{count, plural, one {# file} other {# files}}", map[string]interface{}{"count": 1234}, "1 234 filer"},
+		{"{gender, select, female {She} male {He} other {They}} liked it", map[string]interface{}{"gender": "female"}, "Hon gillade det"},
+		{"{gender, select, female {She} male {He} other {They}} liked it", map[string]interface{}{"gender": "x"}, "Hen gillade det"},
+		{"{n, number} bytes ({p, number, percent})", map[string]interface{}{"n": 1024.5, "p": 0.25}, "1 024,5 byte (25 %)"},
+		{"Updated on {d, date, long} at {d, time, short}", map[string]interface{}{"d": d}, "Uppdaterad 4 mars 2021 kl. 15:30"},
+		{"{host} and {guests, plural, offset:1 =0 {nobody} one {one other} other {# others}}", map[string]interface{}{"host": "Ann", "guests": 0}, "Ann och ingen"},
+		{"{host} and {guests, plural, offset:1 =0 {nobody} one {one other} other {# others}}", map[string]interface{}{"host": "Ann", "guests": 2}, "Ann och en till"},
+		{"{host} and {guests, plural, offset:1 =0 {nobody} one {one other} other {# others}}", map[string]interface{}{"host": "Ann", "guests": 4}, "Ann och 3 till"},
+		{"Don't use '{braces}'", nil, "Använd inte {klamrar}"},
+		// # in a select nested in a plural is the count of the plural
+		{"{n, plural, one {# new message from {g, select, female {her} other {them}}} other {# new messages from {g, select, female {her} other {them}}}}", map[string]interface{}{"n": 3, "g": "female"}, "3 nya meddelanden från henne"},
+		{"{n, plural, one {# new message from {g, select, female {her} other {them}}} other {# new messages from {g, select, female {her} other {them}}}}", map[string]interface{}{"n": 1, "g": "x"}, "1 nytt meddelande 1 st, från dem"},
+		// not localized: the key is used as message
+		{"{n, number} items", map[string]interface{}{"n": 12345}, "12 345 items"},
+	} {
+		if got := i18n.M(test.key, test.args); test.exp != got {
+			t.Errorf(fs, test.exp, got)
+		}
+	}
+
+	en := newI18N("en")
+	if exp, got := "Updated March 4, 2021, 3:30 PM, 1,234.5", en.M("Updated {d, date, long}, {d, time, short}, {n}", map[string]interface{}{"d": d, "n": 1234.5}); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
+
+func Test_ParseMessage_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"Hello, {name",
+		"Hello, name}",
+		"{}",
+		"{n, number, currency}",
+		"{n, choice, 0#none}",
+		"{n, plural, one {# file}}",
+		"{n, plural, some {# file} other {# files}}",
+		"{g, select, male {He} male {He} other {They}}",
+		"{n, plural, one {# file} other {# files}",
+	} {
+		if _, err := parseMessage(s); err == nil {
+			t.Errorf("Expected error for %s, got nil", s)
+		}
+	}
+
+	// invalid messages are used as literal text, and reported by cross validation
+	en := newI18N("en")
+	if err := en.add("Hello", "Hello, {name} {"); err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	if w, g := "Hello, {name} {", en.M("Hello", map[string]interface{}{"name": "Anna"}); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Hello, {name} {", en.S("Hello"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	// plain translations with literal braces are not reported
+	en.add("Brace { test", "Brace { test")
+	sv := newI18N("sv")
+	sv.add("Hello", "Hej, {name}")
+	sv.add("Brace { test", "Klammer { test")
+	db := newI18NDB("", "")
+	db.data = map[string]*I18N{"en": en, "sv": sv}
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		if f.Kind == FindingInvalidMessage {
+			got = append(got, f.Message)
+			if w, g := SeverityError, f.Severity; w != g {
+				t.Errorf(fs, w, g)
+			}
+		}
+	}
+	if w, g := []string{"invalid message in en (missing argument name at position 15)\tHello"}, got; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_CrossValidate_Placeholders(t *testing.T) {
+	en := newI18N("en")
+	en.add("Hello, {name}!", "Hello, {name}!")
+	en.add("Logged in as user %s", "Logged in as user %s")
+	en.add("%d%% done", "%d%% done")
+	sv := newI18N("sv")
+	sv.add("Hello, {name}!", "Hej, {namn}!")
+	sv.add("Logged in as user %s", "Inloggad som användare %d")
+	sv.add("%d%% done", "%d %% klart")
+	db := newI18NDB("", "")
+	db.data = map[string]*I18N{"en": en, "sv": sv}
+
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	exp := []string{
+		"mismatching placeholders; en:[{name}] vs. sv:[{namn}]\tHello, {name}!",
+		"mismatching placeholders; en:[%s] vs. sv:[%d]\tLogged in as user %s",
+	}
+	if fmt.Sprintf("%v", exp) != fmt.Sprintf("%v", msgs) {
+		t.Errorf(fs, exp, msgs)
+	}
+}
+
+func Test_CrossValidate_LiteralBraces(t *testing.T) {
+	// a catalog of plain fmt.Sprintf translations with literal braces is valid
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties": "Brace { test\tBrace { test\nLogged in as %s }\tLogged in as %s }\n",
+		"sv.properties": "Brace { test\tKlammer { test\nLogged in as %s }\tInloggad som %s }\n",
+	})
+	defer os.RemoveAll(dir)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(msgs); w != g {
+		t.Errorf(fs, w, g)
+		t.Errorf("Unexpected findings : %v", msgs)
+	}
+	if w, g := "Klammer { test", db.GetOrDefault("sv").S("Brace { test"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
@@ -0,0 +1,294 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Text directions
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// metadataPrefix starts the metadata lines in the header of a property file, e.g. "#@dir: rtl"
+const metadataPrefix = "#@"
+
+// LocaleMetadata is the metadata of a locale, defined in the header of its catalog. In property files, the header is the comment lines at the top of the file, and metadata lines start with #@:
+//
+//	#@name: العربية
+//	#@dir: rtl
+//	#@fallback: en
+//	#@plural: ar
+//
+// In PO and MO files, metadata is defined in the header entry (X-Locale-Name, X-Text-Direction, X-Fallback-Locale and X-Plural-Rule), in JSON files in a top level "@metadata" object, and in XLIFF files using the metadata module. All fields are optional.
+type LocaleMetadata struct {
+	// Name is the native name of the locale, e.g. Svenska
+	Name string `json:"name,omitempty"`
+	// Direction is the text direction of the locale (ltr or rtl). If undefined, the direction of the script (or language) of the locale is used.
+	Direction string `json:"dir,omitempty"`
+	// Fallback is the locale used for keys missing in this locale, instead of the parent locale (or the default locale), e.g. nb for nn
+	Fallback string `json:"fallback,omitempty"`
+	// PluralRule is the id of the plural rule of the locale, named after a representative language (e.g. ar, en, fr or ru). If undefined, the rule of the language is used (see PluralCategories).
+	PluralRule string `json:"plural,omitempty"`
+}
+
+// metadataField is a metadata key-value pair, as written in catalog headers
+type metadataField struct {
+	key   string
+	value string
+}
+
+// IsEmpty checks if no metadata is defined
+func (m LocaleMetadata) IsEmpty() bool {
+	return m == LocaleMetadata{}
+}
+
+// fields lists the defined metadata fields, in file order (name, dir, fallback, plural)
+func (m LocaleMetadata) fields() []metadataField {
+	res := []metadataField{}
+	for _, f := range []metadataField{{"name", m.Name}, {"dir", m.Direction}, {"fallback", m.Fallback}, {"plural", m.PluralRule}} {
+		if f.value != "" {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// set validates and sets a metadata field (name, dir, fallback or plural)
+func (m *LocaleMetadata) set(key, value string) error {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "name":
+		m.Name = value
+	case "dir":
+		if value != DirectionLTR && value != DirectionRTL {
+			return fmt.Errorf("invalid text direction: %s (expected ltr or rtl)", value)
+		}
+		m.Direction = value
+	case "fallback":
+		if _, err := ParseTag(value); err != nil {
+			return fmt.Errorf("invalid fallback locale : %v", err)
+		}
+		m.Fallback = value
+	case "plural":
+		if _, ok := pluralRules[value]; !ok {
+			return fmt.Errorf("unknown plural rule: %s", value)
+		}
+		m.PluralRule = value
+	default:
+		return fmt.Errorf("unknown metadata key: %s", key)
+	}
+	return nil
+}
+
+// splitMetadataHeader reads the metadata lines (see LocaleMetadata) in the header of a property file, i.e., the comment and empty lines before the first entry or section. The lines are returned with the metadata lines blanked out, so that they are not read as comments.
+func splitMetadataHeader(fName string, lines []string) (LocaleMetadata, []string, error) {
+	res := LocaleMetadata{}
+	errs := ParseErrors{}
+	out := append([]string{}, lines...)
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, metadataPrefix) {
+			out[i] = ""
+			fs := strings.SplitN(strings.TrimPrefix(l, metadataPrefix), ":", 2)
+			if len(fs) != 2 {
+				errs = append(errs, &ParseError{File: fName, Line: i + 1, Msg: "invalid metadata line (expected #@key: value)"})
+				continue
+			}
+			if err := res.set(fs[0], fs[1]); err != nil {
+				errs = append(errs, &ParseError{File: fName, Line: i + 1, Msg: err.Error()})
+			}
+			continue
+		}
+		if l != "" && !strings.HasPrefix(l, "#") && !strings.HasPrefix(l, "!") {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return res, out, errs
+	}
+	return res, out, nil
+}
+
+// rtlScripts are the ISO 15924 codes of scripts written right-to-left
+var rtlScripts = map[string]bool{"Adlm": true, "Arab": true, "Hebr": true, "Mand": true, "Nkoo": true, "Rohg": true, "Samr": true, "Syrc": true, "Thaa": true}
+
+// rtlLanguages are the languages written right-to-left by default, i.e., unless another script is specified in the locale (as in az-Arab, or ku-Latn)
+var rtlLanguages = map[string]bool{"ar": true, "ckb": true, "dv": true, "fa": true, "he": true, "iw": true, "ks": true, "ps": true, "sd": true, "ug": true, "ur": true, "yi": true}
+
+// scriptDirection returns the text direction of the script (or, if no script is specified, the language) of a locale
+func scriptDirection(locale string) string {
+	t, err := ParseTag(locale)
+	if err != nil {
+		return DirectionLTR
+	}
+	if t.Script != "" {
+		if rtlScripts[t.Script] {
+			return DirectionRTL
+		}
+		return DirectionLTR
+	}
+	if rtlLanguages[t.Language] {
+		return DirectionRTL
+	}
+	return DirectionLTR
+}
+
+// Metadata returns the metadata defined for the locale (see LocaleMetadata)
+func (i *I18N) Metadata() LocaleMetadata {
+	return i.meta
+}
+
+// Lang returns the BCP 47 language tag of the locale, e.g. sv-FI for sv_FI, for use in <html lang="...">
+func (i *I18N) Lang() string {
+	if t, err := ParseTag(i.locale); err == nil {
+		return t.String()
+	}
+	return i.locale
+}
+
+// Dir returns the text direction of the locale (ltr or rtl), for use in <html dir="...">: the direction defined in the metadata, or else the direction of the script of the locale
+func (i *I18N) Dir() string {
+	if i.meta.Direction != "" {
+		return i.meta.Direction
+	}
+	return scriptDirection(i.locale)
+}
+
+// Name returns the native name of the locale, as defined in the metadata (or else the locale name)
+func (i *I18N) Name() string {
+	if i.meta.Name != "" {
+		return i.meta.Name
+	}
+	return i.locale
+}
+
+// pluralLocale returns the locale used for plural rules: the plural rule id of the metadata, if defined (all rule ids are also language codes using the rule)
+func (i *I18N) pluralLocale() string {
+	if i.meta.PluralRule != "" {
+		return i.meta.PluralRule
+	}
+	return i.locale
+}
+
+// LocaleInfo describes a locale in an I18NDB, e.g. for listing the available locales to the user
+type LocaleInfo struct {
+	Locale string `json:"locale"`
+	// Lang is the BCP 47 language tag (see I18N.Lang)
+	Lang string `json:"lang"`
+	// Name is the native name (see I18N.Name)
+	Name string `json:"name"`
+	// Direction is the text direction, ltr or rtl (see I18N.Dir)
+	Direction string `json:"dir"`
+	// Fallback is the locale used for missing keys, if any
+	Fallback string `json:"fallback,omitempty"`
+	// PluralRule is the id of the plural rule used by the locale
+	PluralRule string `json:"plural"`
+	// Default is true for the default locale of the I18NDB
+	Default bool `json:"default,omitempty"`
+}
+
+// info returns the LocaleInfo of the locale. NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) info(loc *I18N) LocaleInfo {
+	res := LocaleInfo{
+		Locale:     loc.locale,
+		Lang:       loc.Lang(),
+		Name:       loc.Name(),
+		Direction:  loc.Dir(),
+		PluralRule: pluralRuleForLocale(loc.pluralLocale()).id,
+		Default:    loc.locale == db.DefaultLocale,
+	}
+	if loc.fallback != nil {
+		res.Fallback = loc.fallback.locale
+	}
+	return res
+}
+
+// LocaleInfo returns information about a locale in the db, including its metadata (see LocaleMetadata)
+func (db *I18NDB) LocaleInfo(locale string) (LocaleInfo, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if loc, ok := db.data[locale]; ok {
+		return db.info(loc), true
+	}
+	return LocaleInfo{}, false
+}
+
+// ListLocaleInfo lists information about all locales in the db, sorted by locale name (see ListLocales)
+func (db *I18NDB) ListLocaleInfo() []LocaleInfo {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	res := []LocaleInfo{}
+	for _, name := range sortedKeysString2I18N(db.data) {
+		res = append(res, db.info(db.data[name]))
+	}
+	return res
+}
+
+// metadataFallback returns the fallback locale defined in the metadata of a locale, if it is in the data cache. NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) metadataFallback(name string) (*I18N, bool) {
+	loc, ok := db.data[name]
+	if !ok || loc.meta.Fallback == "" || loc.meta.Fallback == name {
+		return nil, false
+	}
+	fb, ok := db.data[loc.meta.Fallback]
+	return fb, ok
+}
+
+// fallbackCycle checks if following the fallbacks from the locale next leads back to the locale name. The fallbacks are the metadata fallbacks, the parent locales and the default locale (see linkFallbacks).
+func (db *I18NDB) fallbackCycle(name, next string) bool {
+	seen := map[string]bool{}
+	for loc := next; loc != "" && !seen[loc]; {
+		if loc == name {
+			return true
+		}
+		seen[loc] = true
+		if fb, ok := db.metadataFallback(loc); ok {
+			loc = fb.locale
+		} else if parent, ok := db.parentLocale(loc); ok {
+			loc = parent.locale
+		} else if def, ok := db.defaultFallback(loc); ok {
+			loc = def.locale
+		} else {
+			loc = ""
+		}
+	}
+	return false
+}
+
+// fallbackLocale returns the closest fallback of the locale in the data cache (not including the default locale): the fallback locale of the metadata (unless it leads to a cycle), or else the parent locale. NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) fallbackLocale(name string) (*I18N, bool) {
+	if fb, ok := db.metadataFallback(name); ok && !db.fallbackCycle(name, fb.locale) {
+		return fb, true
+	}
+	return db.parentLocale(name)
+}
+
+// crossValidateMetadata checks the metadata of the locales: that fallback locales exist and don't form cycles, that the text direction matches the script of the locale, and that native names are defined for all locales (if defined for any)
+func (db *I18NDB) crossValidateMetadata(locs []string) []Finding {
+	res := []Finding{}
+	named := ""
+	for _, loc := range locs {
+		if named == "" && db.data[loc].meta.Name != "" {
+			named = loc
+		}
+	}
+	for _, loc := range locs {
+		meta := db.data[loc].meta
+		if meta.Fallback != "" {
+			if _, ok := db.data[meta.Fallback]; !ok {
+				res = append(res, Finding{Kind: FindingFallback, Locale: loc, Message: fmt.Sprintf("fallback locale of %s is not defined\t%s", loc, meta.Fallback)})
+			} else if meta.Fallback == loc || db.fallbackCycle(loc, meta.Fallback) {
+				res = append(res, Finding{Kind: FindingFallback, Locale: loc, Message: fmt.Sprintf("fallback locale of %s leads to a cycle\t%s", loc, meta.Fallback)})
+			}
+		}
+		if dir := scriptDirection(loc); meta.Direction != "" && meta.Direction != dir {
+			res = append(res, Finding{Kind: FindingDirection, Locale: loc, Message: fmt.Sprintf("text direction of %s does not match the script of the locale (%s)\t%s", loc, dir, meta.Direction)})
+		}
+		if named != "" && meta.Name == "" {
+			res = append(res, Finding{Kind: FindingMetadata, Locale: loc, Ref: named, Message: fmt.Sprintf("metadata in %s is not present in %s\tname", named, loc)})
+		}
+	}
+	return res
+}
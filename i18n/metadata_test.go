@@ -0,0 +1,262 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_SplitMetadataHeader(t *testing.T) {
+	lines := []string{
+		"# Persian translations",
+		"#@name: فارسی",
+		"#@ dir : rtl",
+		"",
+		"#@plural: fr",
+		"Hello\tسلام",
+		"#@name: ignored",
+	}
+	meta, rest, err := splitMetadataHeader("fa.properties", lines)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "{Name:فارسی Direction:rtl Fallback: PluralRule:fr}", fmt.Sprintf("%+v", meta); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "# Persian translations|||||Hello\tسلام|#@name: ignored", strings.Join(rest, "|"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	_, _, err = splitMetadataHeader("fa.properties", []string{"#@dir: up", "#@plural: xx", "#@fallback: -", "#@colour: red", "#@name"})
+	if err == nil {
+		t.Fatalf("Expected error, got nil")
+	}
+	exp := []string{
+		"fa.properties:1: invalid text direction: up (expected ltr or rtl)",
+		"fa.properties:2: unknown plural rule: xx",
+		"fa.properties:3: invalid fallback locale : invalid subtag  in language tag -",
+		"fa.properties:4: unknown metadata key: colour",
+		"fa.properties:5: invalid metadata line (expected #@key: value)",
+	}
+	if w, g := strings.Join(exp, "\n"), err.Error(); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Metadata(t *testing.T) {
+	db, err := ReadI18NPropDir("test_files/metadata", "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	ar := db.GetOrDefault("ar")
+	if w, g := "ar rtl العربية", strings.Join([]string{ar.Lang(), ar.Dir(), ar.Name()}, " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "3 ملفات", ar.N("%d files", 3); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// fallback from the metadata
+	nn := db.GetOrDefault("nn")
+	if w, g := "Hei", nn.S("Hello"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "ltr", nn.Dir(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	info, ok := db.LocaleInfo("nn")
+	if !ok {
+		t.Fatalf("Expected locale info for nn")
+	}
+	if w, g := "{Locale:nn Lang:nn Name:Norsk nynorsk Direction:ltr Fallback:nb PluralRule:en Default:false}", fmt.Sprintf("%+v", info); w != g {
+		t.Errorf(fs, w, g)
+	}
+	locs := []string{}
+	for _, info := range db.ListLocaleInfo() {
+		locs = append(locs, fmt.Sprintf("%s:%s:%s:%v", info.Locale, info.Direction, info.Fallback, info.Default))
+	}
+	if w, g := "ar:rtl:en:false en:ltr::true nb:ltr:en:false nn:ltr:nb:false", strings.Join(locs, " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(msgs); w != g {
+		t.Errorf(fs, w, g)
+		t.Errorf("Unexpected messages: %v", msgs)
+	}
+}
+
+func Test_MetadataPluralRule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n-metadata")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"en.properties": "%d files[one]\t%d file\n%d files[other]\t%d files\n",
+		// a constructed language using the Russian plural rule
+		"x-test.properties": "#@plural: ru\n\n%d files[one]\t%d one\n%d files[few]\t%d few\n%d files[many]\t%d many\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	loc := db.GetOrDefault("x-test")
+	if w, g := "21 one|22 few|25 many", strings.Join([]string{loc.N("%d files", 21), loc.N("%d files", 22), loc.N("%d files", 25)}, "|"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(msgs); w != g {
+		t.Errorf(fs, w, g)
+		t.Errorf("Unexpected messages: %v", msgs)
+	}
+}
+
+func Test_CrossValidateMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n-metadata")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"en.properties": "#@name: English\n\nHello\tHello\n",
+		"fa.properties": "#@dir: ltr\n\nHello\tسلام\n",
+		"nb.properties": "#@name: Norsk bokmål\n#@fallback: nn\n\nHello\tHei\n",
+		"nn.properties": "#@name: Norsk nynorsk\n#@fallback: nb\n\nHello\tHei\n",
+		"se.properties": "#@name: Davvisámegiella\n#@fallback: fi\n\nHello\tBuorre beaivi\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	// cyclic fallbacks are not used
+	if info, _ := db.LocaleInfo("nb"); info.Fallback != "en" {
+		t.Errorf(fs, "en", info.Fallback)
+	}
+
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	exp := []string{
+		"text direction of fa does not match the script of the locale (rtl)\tltr",
+		"metadata in en is not present in fa\tname",
+		"fallback locale of nb leads to a cycle\tnn",
+		"fallback locale of nn leads to a cycle\tnb",
+		"fallback locale of se is not defined\tfi",
+	}
+	if w, g := strings.Join(exp, "\n"), strings.Join(msgs, "\n"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_CrossValidateMetadata_DefaultCycle(t *testing.T) {
+	// the fallback of the default locale leads back to the default locale (en -> sv -> en)
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties": "#@fallback: sv\n\nHello\tHello\n",
+		"sv.properties": "Hello\tHej\nBye\tHej då\n",
+	})
+	defer os.RemoveAll(dir)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if info, _ := db.LocaleInfo("en"); info.Fallback != "" {
+		t.Errorf(fs, "", info.Fallback)
+	}
+	if w, g := "Bye", db.GetOrDefault("en").S("Bye"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Missing", db.GetOrDefault("sv").S("Missing"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		if f.Kind == FindingFallback {
+			got = append(got, f.Message)
+			if w, g := SeverityError, f.Severity; w != g {
+				t.Errorf(fs, w, g)
+			}
+		}
+	}
+	if w, g := "fallback locale of en leads to a cycle\tsv", strings.Join(got, "\n"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_MetadataNamespaceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n-metadata")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "en"), 0755); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "en.properties"), []byte("Hello\tHello\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "en", "auth.properties"), []byte("#@dir: ltr\nLogin\tLogin\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	_, err = ReadI18NPropDir(dir, "en")
+	if err == nil {
+		t.Fatalf("Expected error, got nil")
+	}
+	if w, g := "locale metadata is not allowed in namespace files (namespace auth)", err.Error(); !strings.HasSuffix(g, w) {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_MetadataCatalogFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n-metadata")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	meta := LocaleMetadata{Name: "العربية", Direction: "rtl", Fallback: "en", PluralRule: "ar"}
+	c := &Catalog{Locale: "ar", Metadata: meta, Entries: []*CatalogEntry{{Key: "Hello", Value: "مرحبا"}}}
+	for _, ext := range []string{".properties", ".po", ".mo", ".json", ".nested.json", ".xlf"} {
+		fName := filepath.Join(dir, "ar"+ext)
+		if err := c.WriteFile(fName, SyntaxJava); err != nil {
+			t.Fatalf("Unexpected error for %s : %v", ext, err)
+		}
+		res, err := ReadCatalog(fName, SyntaxJava)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s : %v", ext, err)
+		}
+		if w, g := meta, res.Metadata; w != g {
+			t.Errorf("%s: "+fs, ext, w, g)
+		}
+		if w, g := 1, len(res.Entries); w != g {
+			t.Errorf("%s: "+fs, ext, w, g)
+		}
+	}
+}
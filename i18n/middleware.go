@@ -0,0 +1,194 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// contextKey is the type of the request context keys used by the package
+type contextKey int
+
+// i18nContextKey is the request context key for the I18N instance resolved by a LocaleMiddleware
+const i18nContextKey contextKey = iota
+
+// I18NFromContext returns the I18N instance resolved for a request by a LocaleMiddleware, if any
+func I18NFromContext(ctx context.Context) (*I18N, bool) {
+	res, ok := ctx.Value(i18nContextKey).(*I18N)
+	return res, ok && res != nil
+}
+
+// LocaleCookie configures the cookie used to persist the locale chosen by the user (see LocaleMiddleware). The zero value uses the defaults: a cookie named locale for the path /, kept for a year, with SameSite=Lax.
+type LocaleCookie struct {
+	Name     string
+	Path     string
+	Domain   string
+	MaxAge   time.Duration
+	Secure   bool
+	HTTPOnly bool
+	SameSite http.SameSite
+}
+
+func (c LocaleCookie) name() string {
+	if c.Name == "" {
+		return "locale"
+	}
+	return c.Name
+}
+
+// cookie returns the cookie for the locale
+func (c LocaleCookie) cookie(locale string) *http.Cookie {
+	res := &http.Cookie{
+		Name:     c.name(),
+		Value:    locale,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		MaxAge:   int(c.MaxAge.Seconds()),
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+		SameSite: c.SameSite,
+	}
+	if res.Path == "" {
+		res.Path = "/"
+	}
+	if res.MaxAge == 0 {
+		res.MaxAge = int((365 * 24 * time.Hour).Seconds())
+	}
+	if res.SameSite == 0 {
+		res.SameSite = http.SameSiteLaxMode
+	}
+	return res
+}
+
+// UserLocales stores the locales chosen by logged-in users (see LocaleMiddleware). The request is used to find the user, so that the i18n package doesn't depend on a specific authentication package.
+type UserLocales interface {
+	// UserLocale returns the locale chosen by the user of the request, if the user is logged in and has chosen a locale
+	UserLocale(r *http.Request) (string, bool)
+	// SetUserLocale saves the locale chosen by the user of the request. It is a no-op if the user isn't logged in.
+	SetUserLocale(r *http.Request, locale string) error
+}
+
+// UserAttributes provides the attributes of logged-in users, such as gender, used to choose between select variants of translations (see LocaleMiddleware and I18N.WithAttributes). The request is used to find the user.
+type UserAttributes interface {
+	// UserAttributes returns the attributes of the user of the request, if the user is logged in
+	UserAttributes(r *http.Request) (map[string]string, bool)
+}
+
+// LocaleMiddleware resolves the locale once per request, and stores the resolved I18N instance in the request context, so that GetI18NFromRequest (and I18NFromContext) return the same instance in all handlers. The locale is selected from the locale param, the locale chosen by the logged-in user (if Users is set), the locale cookie, or the Accept-Language header, in that order. Locales set explicitly using the locale param are persisted in the cookie (and for the user). If Attributes is set, the attributes of the logged-in user are set on the I18N instance (see I18N.WithAttributes).
+type LocaleMiddleware struct {
+	DB     *I18NDB
+	Cookie LocaleCookie
+	// Users (optional) stores the locales chosen by logged-in users
+	Users UserLocales
+	// Attributes (optional) provides the attributes of logged-in users, used to choose between select variants
+	Attributes UserAttributes
+}
+
+// NewLocaleMiddleware creates a LocaleMiddleware for db, using the default cookie settings. Set Cookie, Users and Attributes (if needed) before use.
+func NewLocaleMiddleware(db *I18NDB) *LocaleMiddleware {
+	return &LocaleMiddleware{DB: db}
+}
+
+// Handler is the middleware function (for use with gorilla/mux's Router.Use, or for wrapping a single handler)
+func (m *LocaleMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locName, source := m.requestedLocale(r)
+		if source == "param" {
+			if name, ok := m.match(locName); ok {
+				if err := m.persist(w, r, name); err != nil {
+					log.Printf("Couldn't save locale %s : %v", name, err)
+				}
+			}
+		}
+		res := m.DB.requestI18N(r, locName, source)
+		if m.Attributes != nil {
+			if attrs, ok := m.Attributes.UserAttributes(r); ok {
+				res = res.WithAttributes(attrs)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), i18nContextKey, res)))
+	})
+}
+
+// requestedLocale returns the requested locale, and the source from which the locale was retrieved (param, user, cookie or header)
+func (m *LocaleMiddleware) requestedLocale(r *http.Request) (string, string) {
+	if locName := util.GetParam(r, "locale"); locName != "" {
+		return locName, "param"
+	}
+	if m.Users != nil {
+		if locName, ok := m.Users.UserLocale(r); ok && locName != "" {
+			return locName, "user"
+		}
+	}
+	if cookie, err := r.Cookie(m.Cookie.name()); err == nil && cookie.Value != "" {
+		return cookie.Value, "cookie"
+	}
+	if prefs := ParseAcceptLanguage(strings.Join(r.Header["Accept-Language"], ",")); len(prefs) > 0 {
+		return prefs[0].Tag.String(), "header"
+	}
+	return "", ""
+}
+
+// match returns the name of the locale in the db matching the requested locale
+func (m *LocaleMiddleware) match(locName string) (string, bool) {
+	t, err := ParseTag(locName)
+	if err != nil {
+		return "", false
+	}
+	return m.DB.Match(t)
+}
+
+// persist saves the chosen locale in the cookie, and for the user
+func (m *LocaleMiddleware) persist(w http.ResponseWriter, r *http.Request, locName string) error {
+	http.SetCookie(w, m.Cookie.cookie(locName))
+	if m.Users != nil {
+		return m.Users.SetUserLocale(r, locName)
+	}
+	return nil
+}
+
+// isLocalRedirect checks that a redirect URL is a local path (to avoid open redirects). Control characters and backslashes are rejected anywhere in the URL, since browsers strip or rewrite them (e.g. "/\t/evil.example" is followed as "//evil.example").
+func isLocalRedirect(u string) bool {
+	if !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") || strings.ContainsRune(u, '\\') {
+		return false
+	}
+	for _, r := range u {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
+
+// SetLocaleHandler is a handler for changing the locale (e.g. /locale/set?locale=sv&redirect=/auth/login). The locale is persisted in the cookie (and for the user, if Users is set). If the redirect param is a local path, the client is redirected to it; otherwise, the name of the selected locale is returned. Unknown locales are rejected with status 400 (Bad Request).
+func (m *LocaleMiddleware) SetLocaleHandler(w http.ResponseWriter, r *http.Request) {
+	locName := util.GetParam(r, "locale")
+	if locName == "" {
+		http.Error(w, "Missing param locale", http.StatusBadRequest)
+		return
+	}
+	name, ok := m.match(locName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown locale: %s", locName), http.StatusBadRequest)
+		return
+	}
+	if err := m.persist(w, r, name); err != nil {
+		log.Printf("Couldn't save locale %s : %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if redirect := util.GetParam(r, "redirect"); isLocalRedirect(redirect) {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s\n", name)
+}
@@ -0,0 +1,141 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUserLocales map[string]string
+
+func (ul testUserLocales) UserLocale(r *http.Request) (string, bool) {
+	loc, ok := ul[r.Header.Get("X-User")]
+	return loc, ok
+}
+
+func (ul testUserLocales) SetUserLocale(r *http.Request, locale string) error {
+	if user := r.Header.Get("X-User"); user != "" {
+		ul[user] = locale
+	}
+	return nil
+}
+
+func newTestLocaleDB() *I18NDB {
+	db := newI18NDB("", "en")
+	en, sv, fr := newI18N("en"), newI18N("sv"), newI18N("fr")
+	en.add("Login", "Login")
+	sv.add("Login", "Logga in")
+	fr.add("Login", "Connexion")
+	db.data = map[string]*I18N{"en": en, "sv": sv, "fr": fr}
+	db.linkFallbacks()
+	return db
+}
+
+func Test_LocaleMiddleware(t *testing.T) {
+	db := newTestLocaleDB()
+	m := NewLocaleMiddleware(db)
+	m.Cookie = LocaleCookie{Name: "lang", MaxAge: time.Hour, Secure: true}
+	users := testUserLocales{}
+	m.Users = users
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxI18N, _ := I18NFromContext(r.Context())
+		if ctxI18N != db.GetI18NFromRequest(r) {
+			t.Errorf("Expected the resolved I18N from the request context")
+		}
+		w.Write([]byte(db.GetI18NFromRequest(r).S("Login")))
+	}))
+	serve := func(target string, header map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// explicit choice is persisted
+	w := serve("/?locale=sv-SE", map[string]string{"X-User": "anna"})
+	if w, g := "Logga in", w.Body.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+	cookies := w.Result().Cookies()
+	if w, g := 1, len(cookies); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	c := cookies[0]
+	if w, g := "lang=sv; Path=/; Max-Age=3600; Secure; SameSite=Lax", c.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "sv", users["anna"]; w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// unknown locales are not persisted
+	w = serve("/?locale=xx", nil)
+	if w, g := 0, len(w.Result().Cookies()); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	for _, test := range []struct {
+		header  map[string]string
+		cookies []*http.Cookie
+		exp     string
+	}{
+		{nil, []*http.Cookie{{Name: "lang", Value: "sv"}}, "Logga in"},
+		// the default cookie name is not used
+		{nil, []*http.Cookie{{Name: "locale", Value: "sv"}}, "Login"},
+		{map[string]string{"Accept-Language": "fr-CA, sv;q=0.5"}, []*http.Cookie{{Name: "lang", Value: "sv"}}, "Logga in"},
+		{map[string]string{"Accept-Language": "fr-CA, sv;q=0.5"}, nil, "Connexion"},
+		// the user's choice is used before the cookie
+		{map[string]string{"X-User": "anna"}, []*http.Cookie{{Name: "lang", Value: "fr"}}, "Logga in"},
+		{map[string]string{"X-User": "bertil"}, []*http.Cookie{{Name: "lang", Value: "fr"}}, "Connexion"},
+	} {
+		if w, g := test.exp, serve("/", test.header, test.cookies...).Body.String(); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_SetLocaleHandler(t *testing.T) {
+	m := NewLocaleMiddleware(newTestLocaleDB())
+	for _, test := range []struct {
+		target   string
+		status   int
+		location string
+		cookie   string
+	}{
+		{"/locale/set?locale=sv", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=fr-CA&redirect=/auth/login", http.StatusSeeOther, "/auth/login", "locale=fr; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=sv&redirect=//evil.example.com/", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		// browsers strip tabs and newlines, and treat backslashes as slashes
+		{"/locale/set?locale=sv&redirect=/%09/evil.example", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=sv&redirect=/%0A/evil.example", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=sv&redirect=/%5Cevil.example", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=sv&redirect=/auth/%5C%5Cevil.example", http.StatusOK, "", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=sv&redirect=/auth/login?next=%2Fhome", http.StatusSeeOther, "/auth/login?next=/home", "locale=sv; Path=/; Max-Age=31536000; SameSite=Lax"},
+		{"/locale/set?locale=xx", http.StatusBadRequest, "", ""},
+		{"/locale/set", http.StatusBadRequest, "", ""},
+	} {
+		w := httptest.NewRecorder()
+		m.SetLocaleHandler(w, httptest.NewRequest("POST", test.target, nil))
+		if w, g := test.status, w.Code; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := test.location, w.Header().Get("Location"); w != g {
+			t.Errorf(fs, w, g)
+		}
+		cookies := []string{}
+		for _, c := range w.Result().Cookies() {
+			cookies = append(cookies, c.String())
+		}
+		if w, g := test.cookie, strings.Join(cookies, " "); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
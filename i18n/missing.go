@@ -0,0 +1,150 @@
+package i18n
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// maxMissingTranslations limits the number of missing translations collected by an I18NDB, since keys may come from user input
+const maxMissingTranslations = 10000
+
+// maxRequestedLocales limits the number of requested locales saved for each missing translation
+const maxRequestedLocales = 10
+
+// MissingTranslation is a key that was looked up (using I18N.S, N or M), but is not translated for the locale, i.e., it is not defined for the locale or any of its parent locales (it may still be defined for the default locale)
+type MissingTranslation struct {
+	Locale string `json:"locale"`
+	Key    string `json:"key"`
+	// Count is the number of lookups
+	Count int `json:"count"`
+	// FirstSeen is the time of the first lookup
+	FirstSeen time.Time `json:"first_seen"`
+	// FirstPath is the URL path of the first request where the key was looked up (if the I18N instance was retrieved using GetI18NFromRequest)
+	FirstPath string `json:"first_path,omitempty"`
+	// RequestedLocales lists the locales requested by the client (e.g. sv-SE, if the sv locale was used), if known
+	RequestedLocales []string `json:"requested_locales,omitempty"`
+}
+
+// requestInfo is the request that an I18N instance was retrieved for
+type requestInfo struct {
+	path   string
+	locale string
+}
+
+// missingCollector collects missing translations for an I18NDB
+type missingCollector struct {
+	mutex   *sync.Mutex
+	data    map[string]*MissingTranslation // locale + \x00 + key -> missing translation
+	dropped int
+}
+
+func newMissingCollector() *missingCollector {
+	return &missingCollector{mutex: &sync.Mutex{}, data: make(map[string]*MissingTranslation)}
+}
+
+func (c *missingCollector) record(locale, key string, req *requestInfo) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	id := locale + "\x00" + key
+	m, ok := c.data[id]
+	if !ok {
+		if len(c.data) >= maxMissingTranslations {
+			if c.dropped == 0 {
+				log.Printf("Too many missing translations, only the first %d are saved", maxMissingTranslations)
+			}
+			c.dropped++
+			return
+		}
+		m = &MissingTranslation{Locale: locale, Key: key, FirstSeen: time.Now()}
+		if req != nil {
+			m.FirstPath = req.path
+		}
+		c.data[id] = m
+	}
+	m.Count++
+	if req != nil && req.locale != "" && req.locale != locale && !contains(m.RequestedLocales, req.locale) && len(m.RequestedLocales) < maxRequestedLocales {
+		m.RequestedLocales = append(m.RequestedLocales, req.locale)
+	}
+}
+
+// list returns copies of the missing translations, sorted by locale and key
+func (c *missingCollector) list() []MissingTranslation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := []MissingTranslation{}
+	for _, m := range c.data {
+		cp := *m
+		cp.RequestedLocales = append([]string{}, m.RequestedLocales...)
+		res = append(res, cp)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Locale != res[j].Locale {
+			return res[i].Locale < res[j].Locale
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+func (c *missingCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data = make(map[string]*MissingTranslation)
+	c.dropped = 0
+}
+
+// isTranslated checks if key is defined for the locale, or any of its parent locales (but not the default locale, unless this is the default locale)
+func (i *I18N) isTranslated(key string) bool {
+	for _, loc := range i.fallbackChain() {
+		if loc.hasKey(key) {
+			return true
+		}
+		if loc.defaultFallback {
+			break
+		}
+	}
+	return false
+}
+
+// checkMissing records key as missing, if it isn't translated for the locale
+func (i *I18N) checkMissing(key string) {
+	if i.missing != nil && !i.isTranslated(key) {
+		i.missing.record(i.locale, key, i.request)
+	}
+}
+
+// MissingTranslations lists the keys that have been looked up since the I18NDB was created (or since ResetMissingTranslations was called), but are not translated for the locale. The list is sorted by locale and key.
+func (db *I18NDB) MissingTranslations() []MissingTranslation {
+	return db.missing.list()
+}
+
+// ResetMissingTranslations clears the list of missing translations
+func (db *I18NDB) ResetMissingTranslations() {
+	db.missing.reset()
+}
+
+// MissingTranslationsHandler serves the missing translations as JSON (see MissingTranslations). The optional locale param limits the list to a single locale. A POST or DELETE request with the param reset=true clears the list. NB that the handler should only be available to admin users.
+func (db *I18NDB) MissingTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	if (r.Method == http.MethodPost || r.Method == http.MethodDelete) && util.GetParam(r, "reset") == "true" {
+		db.ResetMissingTranslations()
+	}
+	locale := util.GetParam(r, "locale")
+	res := []MissingTranslation{}
+	for _, m := range db.MissingTranslations() {
+		if locale == "" || m.Locale == locale {
+			res = append(res, m)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		log.Printf("Couldn't write missing translations : %v", err)
+	}
+}
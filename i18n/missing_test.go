@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func Test_MissingTranslations(t *testing.T) {
+	db := testDB()
+
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("Accept-Language", "sv-SE")
+	sv := db.GetI18NFromRequest(r)
+	sv.S("Email")             // translated
+	sv.S("Users")             // only in the default locale
+	sv.N("%d users", 2)       // not defined
+	sv.M("Hello {name}", nil) // not defined
+	sv.S("Users")
+
+	fi := db.GetOrDefault("sv_FI")
+	fi.S("Password") // translated in the parent locale (sv)
+	fi.S("Users")
+
+	db.Default().S("Email")
+	db.Default().S("Logout")
+
+	got := []string{}
+	for _, m := range db.MissingTranslations() {
+		got = append(got, fmt.Sprintf("%s|%s|%d|%s|%v", m.Locale, m.Key, m.Count, m.FirstPath, m.RequestedLocales))
+	}
+	exp := []string{
+		"en|Logout|1||[]",
+		"sv|%d users|1|/users|[sv-SE]",
+		"sv|Hello {name}|1|/users|[sv-SE]",
+		"sv|Users|2|/users|[sv-SE]",
+		"sv_FI|Users|1||[]",
+	}
+	if w, g := fmt.Sprintf("%v", exp), fmt.Sprintf("%v", got); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// admin handler
+	r = httptest.NewRequest("GET", "/admin/i18n/missing?locale=sv_FI", nil)
+	w := httptest.NewRecorder()
+	db.MissingTranslationsHandler(w, r)
+	var res []MissingTranslation
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 1, len(res); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := "Users", res[0].Key; w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	r = httptest.NewRequest("POST", "/admin/i18n/missing?reset=true", nil)
+	db.MissingTranslationsHandler(httptest.NewRecorder(), r)
+	if w, g := 0, len(db.MissingTranslations()); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_MissingTranslations_Concurrent(t *testing.T) {
+	db := testDB()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Language", "sv")
+			loc := db.GetI18NFromRequest(r)
+			loc.S("Missing")
+			loc.S(fmt.Sprintf("Missing %d", i%5))
+			db.MissingTranslations()
+		}(i)
+	}
+	wg.Wait()
+	missing := db.MissingTranslations()
+	if w, g := 6, len(missing); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := 50, missing[0].Count; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
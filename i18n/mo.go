@@ -0,0 +1,165 @@
+package i18n
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"strings"
+)
+
+const moMagic = 0x950412de
+
+// ReadMO reads a compiled gettext MO file. MO files contain no comments or flags, so only the contexts, keys and translations are read. The locale is taken from the Language header, or else from the file name.
+func ReadMO(fName string) (*Catalog, error) {
+	return readMO(nil, fName)
+}
+
+func readMO(fsys iofs.FS, fName string) (*Catalog, error) {
+	res := &Catalog{Locale: locNameFromFile(fName)}
+	data, err := readFileFS(fsys, fName)
+	if err != nil {
+		return res, err
+	}
+	if len(data) < 28 {
+		return res, fmt.Errorf("invalid MO file %s : too short", fName)
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(data) == moMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data) == moMagic:
+		order = binary.BigEndian
+	default:
+		return res, fmt.Errorf("invalid MO file %s : bad magic number", fName)
+	}
+	n := int(order.Uint32(data[8:]))
+	keyTable := int(order.Uint32(data[12:]))
+	valueTable := int(order.Uint32(data[16:]))
+
+	str := func(table, i int) (string, error) {
+		pos := table + i*8
+		if pos < 0 || pos+8 > len(data) {
+			return "", fmt.Errorf("invalid MO file %s : string table out of range", fName)
+		}
+		length := int(order.Uint32(data[pos:]))
+		offset := int(order.Uint32(data[pos+4:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return "", fmt.Errorf("invalid MO file %s : string out of range", fName)
+		}
+		return string(data[offset : offset+length]), nil
+	}
+
+	type moEntry struct{ key, value string }
+	entries := []moEntry{}
+	nplurals := -1
+	for i := 0; i < n; i++ {
+		key, err := str(keyTable, i)
+		if err != nil {
+			return res, err
+		}
+		value, err := str(valueTable, i)
+		if err != nil {
+			return res, err
+		}
+		if key == "" {
+			lang, n, meta, err := parseCatalogHeader(value)
+			if err != nil {
+				return res, fmt.Errorf("invalid MO file %s : %v", fName, err)
+			}
+			if lang != "" {
+				res.Locale = lang
+			}
+			nplurals, res.Metadata = n, meta
+			continue
+		}
+		entries = append(entries, moEntry{key, value})
+	}
+
+	cats := PluralCategories(res.pluralLocale())
+	if nplurals >= 0 && nplurals != len(cats) {
+		return res, fmt.Errorf("invalid MO file %s : Plural-Forms header has nplurals=%d, but locale %s has %d plural categories %v", fName, nplurals, res.Locale, len(cats), cats)
+	}
+	for _, me := range entries {
+		e := &CatalogEntry{}
+		key := me.key
+		if i := strings.Index(key, "\x04"); i >= 0 {
+			e.Context, key = key[:i], key[i+1:]
+		}
+		if i := strings.Index(key, "\x00"); i >= 0 {
+			e.Key, e.KeyPlural = key[:i], key[i+1:]
+			e.Plurals = make(map[string]string)
+			for j, v := range strings.Split(me.value, "\x00") {
+				if j >= len(cats) {
+					return res, fmt.Errorf("invalid MO file %s : too many plural forms for locale %s: %s", fName, res.Locale, e.Key)
+				}
+				e.Plurals[cats[j]] = v
+			}
+		} else {
+			e.Key, e.Value = key, me.value
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+// WriteMO writes the catalog in (little-endian) gettext MO format. Untranslated and fuzzy entries are left out, as by msgfmt.
+func (c *Catalog) WriteMO(w io.Writer) error {
+	type moEntry struct{ key, value string }
+	entries := []moEntry{{"", catalogHeader(c)}}
+
+	cats := PluralCategories(c.pluralLocale())
+	for _, e := range c.Entries {
+		if !e.IsTranslated() || e.IsFuzzy() {
+			continue
+		}
+		key := e.Key
+		if e.Context != "" {
+			key = e.Context + "\x04" + key
+		}
+		if !e.IsPlural() {
+			entries = append(entries, moEntry{key, e.Value})
+			continue
+		}
+		keyPlural := e.KeyPlural
+		if keyPlural == "" {
+			keyPlural = e.Key
+		}
+		values := []string{}
+		for _, cat := range cats {
+			values = append(values, e.Plurals[cat])
+		}
+		entries = append(entries, moEntry{key + "\x00" + keyPlural, strings.Join(values, "\x00")})
+	}
+	// msgfmt sorts the keys, so that they can be looked up using binary search
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	n := len(entries)
+	keyTable := 28
+	valueTable := keyTable + n*8
+	offset := valueTable + n*8
+	tables := make([]uint32, 0, n*4)
+	var strs []byte
+	for _, e := range entries {
+		tables = append(tables, uint32(len(e.key)), uint32(offset+len(strs)))
+		strs = append(strs, e.key...)
+		strs = append(strs, 0)
+	}
+	for _, e := range entries {
+		tables = append(tables, uint32(len(e.value)), uint32(offset+len(strs)))
+		strs = append(strs, e.value...)
+		strs = append(strs, 0)
+	}
+
+	// magic, revision, number of strings, key table offset, value table offset, hash table size and offset (no hash table)
+	head := []uint32{moMagic, 0, uint32(n), uint32(keyTable), uint32(valueTable), 0, uint32(offset)}
+	if err := binary.Write(w, binary.LittleEndian, head); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, tables); err != nil {
+		return err
+	}
+	_, err := w.Write(strs)
+	return err
+}
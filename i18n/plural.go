@@ -0,0 +1,276 @@
+package i18n
+
+import (
+	"strings"
+)
+
+// CLDR plural categories
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+var pluralCategories = []string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}
+
+func isPluralCategory(s string) bool {
+	for _, cat := range pluralCategories {
+		if cat == s {
+			return true
+		}
+	}
+	return false
+}
+
+// pluralRule is a CLDR plural rule, restricted to integer counts (CLDR operand v = 0). Categories only used for fractions are left out.
+type pluralRule struct {
+	// id of the rule, named after a representative language
+	id string
+	// categories used by the rule, in CLDR order
+	categories []string
+	// gettext Plural-Forms header for the rule. The plural form indexes correspond to the categories, in order.
+	pluralForms string
+	// category returns the plural category for the (non-negative) count n
+	category func(n int) string
+}
+
+func between(n, from, to int) bool {
+	return n >= from && n <= to
+}
+
+var pluralRules = map[string]pluralRule{
+	"ja": {"ja", []string{PluralOther}, "nplurals=1; plural=0;", func(n int) string {
+		return PluralOther
+	}},
+	"en": {"en", []string{PluralOne, PluralOther}, "nplurals=2; plural=(n != 1);", func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}},
+	"fr": {"fr", []string{PluralOne, PluralMany, PluralOther}, "nplurals=3; plural=(n == 0 || n == 1 ? 0 : n != 0 && n % 1000000 == 0 ? 1 : 2);", func(n int) string {
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+		if n%1000000 == 0 {
+			return PluralMany
+		}
+		return PluralOther
+	}},
+	"es": {"es", []string{PluralOne, PluralMany, PluralOther}, "nplurals=3; plural=(n == 1 ? 0 : n != 0 && n % 1000000 == 0 ? 1 : 2);", func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		if n != 0 && n%1000000 == 0 {
+			return PluralMany
+		}
+		return PluralOther
+	}},
+	"hi": {"hi", []string{PluralOne, PluralOther}, "nplurals=2; plural=(n > 1);", func(n int) string {
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}},
+	"ru": {"ru", []string{PluralOne, PluralFew, PluralMany}, "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);", func(n int) string {
+		if n%10 == 1 && n%100 != 11 {
+			return PluralOne
+		}
+		if between(n%10, 2, 4) && !between(n%100, 12, 14) {
+			return PluralFew
+		}
+		return PluralMany
+	}},
+	"pl": {"pl", []string{PluralOne, PluralFew, PluralMany}, "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);", func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		if between(n%10, 2, 4) && !between(n%100, 12, 14) {
+			return PluralFew
+		}
+		return PluralMany
+	}},
+	"hr": {"hr", []string{PluralOne, PluralFew, PluralOther}, "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);", func(n int) string {
+		if n%10 == 1 && n%100 != 11 {
+			return PluralOne
+		}
+		if between(n%10, 2, 4) && !between(n%100, 12, 14) {
+			return PluralFew
+		}
+		return PluralOther
+	}},
+	"cs": {"cs", []string{PluralOne, PluralFew, PluralOther}, "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);", func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		if between(n, 2, 4) {
+			return PluralFew
+		}
+		return PluralOther
+	}},
+	"lt": {"lt", []string{PluralOne, PluralFew, PluralOther}, "nplurals=3; plural=(n%10==1 && (n%100<11 || n%100>19) ? 0 : n%10>=2 && (n%100<11 || n%100>19) ? 1 : 2);", func(n int) string {
+		if n%10 == 1 && !between(n%100, 11, 19) {
+			return PluralOne
+		}
+		if between(n%10, 2, 9) && !between(n%100, 11, 19) {
+			return PluralFew
+		}
+		return PluralOther
+	}},
+	"lv": {"lv", []string{PluralZero, PluralOne, PluralOther}, "nplurals=3; plural=(n%10==0 || (n%100>=11 && n%100<=19) ? 0 : n%10==1 && n%100!=11 ? 1 : 2);", func(n int) string {
+		if n%10 == 0 || between(n%100, 11, 19) {
+			return PluralZero
+		}
+		if n%10 == 1 && n%100 != 11 {
+			return PluralOne
+		}
+		return PluralOther
+	}},
+	"ro": {"ro", []string{PluralOne, PluralFew, PluralOther}, "nplurals=3; plural=(n==1 ? 0 : n==0 || (n%100>=1 && n%100<=19) ? 1 : 2);", func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		if n == 0 || between(n%100, 1, 19) {
+			return PluralFew
+		}
+		return PluralOther
+	}},
+	"sl": {"sl", []string{PluralOne, PluralTwo, PluralFew, PluralOther}, "nplurals=4; plural=(n%100==1 ? 0 : n%100==2 ? 1 : n%100==3 || n%100==4 ? 2 : 3);", func(n int) string {
+		switch {
+		case n%100 == 1:
+			return PluralOne
+		case n%100 == 2:
+			return PluralTwo
+		case between(n%100, 3, 4):
+			return PluralFew
+		}
+		return PluralOther
+	}},
+	"he": {"he", []string{PluralOne, PluralTwo, PluralOther}, "nplurals=3; plural=(n==1 ? 0 : n==2 ? 1 : 2);", func(n int) string {
+		switch n {
+		case 1:
+			return PluralOne
+		case 2:
+			return PluralTwo
+		}
+		return PluralOther
+	}},
+	"ga": {"ga", []string{PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}, "nplurals=5; plural=(n==1 ? 0 : n==2 ? 1 : n>=3 && n<=6 ? 2 : n>=7 && n<=10 ? 3 : 4);", func(n int) string {
+		switch {
+		case n == 1:
+			return PluralOne
+		case n == 2:
+			return PluralTwo
+		case between(n, 3, 6):
+			return PluralFew
+		case between(n, 7, 10):
+			return PluralMany
+		}
+		return PluralOther
+	}},
+	"cy": {"cy", []string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}, "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n==3 ? 3 : n==6 ? 4 : 5);", func(n int) string {
+		switch n {
+		case 0:
+			return PluralZero
+		case 1:
+			return PluralOne
+		case 2:
+			return PluralTwo
+		case 3:
+			return PluralFew
+		case 6:
+			return PluralMany
+		}
+		return PluralOther
+	}},
+	"ar": {"ar", []string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}, "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);", func(n int) string {
+		switch {
+		case n == 0:
+			return PluralZero
+		case n == 1:
+			return PluralOne
+		case n == 2:
+			return PluralTwo
+		case between(n%100, 3, 10):
+			return PluralFew
+		case between(n%100, 11, 99):
+			return PluralMany
+		}
+		return PluralOther
+	}},
+}
+
+// pluralRuleIDs maps language codes to plural rule ids. Languages not listed use the CLDR root rule (ja, i.e., other only).
+var pluralRuleIDs = map[string]string{
+	// other
+	"ja": "ja", "zh": "ja", "ko": "ja", "vi": "ja", "th": "ja", "id": "ja", "ms": "ja", "lo": "ja", "my": "ja", "km": "ja",
+	// one, other
+	"en": "en", "sv": "en", "de": "en", "nl": "en", "da": "en", "nb": "en", "nn": "en", "no": "en", "fi": "en", "et": "en",
+	"el": "en", "hu": "en", "tr": "en", "bg": "en", "eu": "en", "gl": "en", "ur": "en",
+	"sw": "en", "af": "en", "sq": "en", "ka": "en", "az": "en", "kk": "en", "uz": "en", "mn": "en", "ta": "en", "te": "en",
+	// one (0 and 1), other
+	"hi": "hi", "fa": "hi", "bn": "hi", "am": "hi", "zu": "hi", "hy": "hi", "gu": "hi", "kn": "hi",
+	// one, many (millions), other
+	"fr": "fr", "pt": "fr",
+	"es": "es", "it": "es", "ca": "es",
+	// one, few, many
+	"ru": "ru", "uk": "ru", "be": "ru",
+	"pl": "pl",
+	// one, few, other
+	"hr": "hr", "sr": "hr", "bs": "hr",
+	"cs": "cs", "sk": "cs",
+	"lt": "lt",
+	"ro": "ro",
+	// other rules
+	"lv": "lv",
+	"sl": "sl",
+	"he": "he", "iw": "he",
+	"ga": "ga",
+	"cy": "cy",
+	"ar": "ar",
+}
+
+// pluralRuleForLocale returns the plural rule for the language of the locale
+func pluralRuleForLocale(locale string) pluralRule {
+	if id, ok := pluralRuleIDs[localeLanguage(locale)]; ok {
+		return pluralRules[id]
+	}
+	return pluralRules["ja"]
+}
+
+// PluralCategories returns the CLDR plural categories used by the locale for integer counts
+func PluralCategories(locale string) []string {
+	return append([]string{}, pluralRuleForLocale(locale).categories...)
+}
+
+// PluralForms returns the gettext Plural-Forms header value for the locale, e.g. "nplurals=2; plural=(n != 1);". The plural form indexes correspond to the categories returned by PluralCategories, in order.
+func PluralForms(locale string) string {
+	return pluralRuleForLocale(locale).pluralForms
+}
+
+// PluralCategory returns the CLDR plural category for the count n in the locale
+func PluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	return pluralRuleForLocale(locale).category(n)
+}
+
+// splitPluralKey splits a plural variant key, such as "%d users[one]", into the base key and the plural category. If the key is not a plural variant, the second return value is empty.
+func splitPluralKey(key string) (string, string) {
+	if !strings.HasSuffix(key, "]") {
+		return key, ""
+	}
+	i := strings.LastIndex(key, "[")
+	if i <= 0 {
+		return key, ""
+	}
+	cat := key[i+1 : len(key)-1]
+	if !isPluralCategory(cat) {
+		return key, ""
+	}
+	return key[:i], cat
+}
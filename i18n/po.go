@@ -0,0 +1,300 @@
+package i18n
+
+import (
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// poEntry is an entry read from a PO file, before the plural forms are mapped to plural categories
+type poEntry struct {
+	entry   *CatalogEntry
+	hasID   bool
+	strs    map[int]*string
+	line    int
+	comment bool // the last line read was a comment
+}
+
+var msgstrIndexRE = regexp.MustCompile(`^msgstr\[([0-9]+)\]$`)
+var npluralsRE = regexp.MustCompile(`nplurals\s*=\s*([0-9]+)`)
+
+// ReadPO reads a gettext PO (or POT) file. The locale is taken from the Language header, or else from the file name, and the locale metadata from the X- header fields (see LocaleMetadata). Plural forms (msgstr[N]) are mapped to the CLDR plural categories of the locale, in order (see PluralForms). Obsolete entries (#~) are ignored.
+func ReadPO(fName string) (*Catalog, error) {
+	return readPO(nil, fName)
+}
+
+func readPO(fsys iofs.FS, fName string) (*Catalog, error) {
+	res := &Catalog{Locale: locNameFromFile(fName)}
+	lines, err := readLinesFS(fsys, fName)
+	if err != nil {
+		return res, err
+	}
+
+	entries := []*poEntry{}
+	errs := ParseErrors{}
+	var cur *poEntry
+	var last *string // the string that continuation lines are appended to
+	newEntry := func(lineNo int) {
+		if cur != nil && cur.hasID {
+			entries = append(entries, cur)
+		}
+		if cur == nil || cur.hasID {
+			cur = &poEntry{entry: &CatalogEntry{}, strs: make(map[int]*string), line: lineNo}
+		}
+		last = nil
+	}
+	newEntry(1)
+
+	for i, l := range lines {
+		lineNo := i + 1
+		l = strings.TrimSpace(l)
+		if l == "" {
+			newEntry(lineNo + 1)
+			continue
+		}
+		if strings.HasPrefix(l, "#") {
+			if cur.hasID && !cur.comment {
+				newEntry(lineNo)
+			}
+			cur.comment = true
+			e := cur.entry
+			switch {
+			case strings.HasPrefix(l, "#~"), strings.HasPrefix(l, "#|"):
+				// obsolete entries and previous strings are ignored
+			case strings.HasPrefix(l, "#."):
+				e.ExtractedComments = append(e.ExtractedComments, strings.TrimSpace(l[2:]))
+			case strings.HasPrefix(l, "#:"):
+				e.References = append(e.References, strings.Fields(l[2:])...)
+			case strings.HasPrefix(l, "#,"):
+				for _, flag := range strings.Split(l[2:], ",") {
+					if flag = strings.TrimSpace(flag); flag != "" {
+						e.Flags = append(e.Flags, flag)
+					}
+				}
+			default:
+				e.Comments = append(e.Comments, strings.TrimSpace(l[1:]))
+			}
+			continue
+		}
+		cur.comment = false
+
+		keyword, quoted := l, ""
+		if i := strings.IndexAny(l, " \t"); i >= 0 {
+			keyword, quoted = l[:i], strings.TrimSpace(l[i:])
+		}
+		if strings.HasPrefix(l, `"`) {
+			keyword, quoted = "", l
+		}
+		s, err := strconv.Unquote(quoted)
+		if err != nil || !strings.HasPrefix(quoted, `"`) {
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: fmt.Sprintf("invalid string: %s", quoted)})
+			continue
+		}
+
+		e := cur.entry
+		switch {
+		case keyword == "":
+			if last == nil {
+				errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: "unexpected string"})
+				continue
+			}
+			*last += s
+		case keyword == "msgctxt":
+			if cur.hasID {
+				newEntry(lineNo)
+				e = cur.entry
+			}
+			e.Context = s
+			last = &e.Context
+		case keyword == "msgid":
+			if cur.hasID {
+				newEntry(lineNo)
+				e = cur.entry
+			}
+			cur.hasID = true
+			cur.line = lineNo
+			e.Key = s
+			last = &e.Key
+		case keyword == "msgid_plural":
+			e.KeyPlural = s
+			last = &e.KeyPlural
+		case keyword == "msgstr":
+			e.Value = s
+			last = &e.Value
+		case msgstrIndexRE.MatchString(keyword):
+			n, _ := strconv.Atoi(msgstrIndexRE.FindStringSubmatch(keyword)[1])
+			cur.strs[n] = &s
+			last = &s
+		default:
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: fmt.Sprintf("unknown keyword: %s", keyword)})
+			continue
+		}
+		if !cur.hasID && keyword != "msgctxt" && keyword != "" {
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: fmt.Sprintf("%s without msgid", keyword)})
+		}
+	}
+	newEntry(len(lines) + 1)
+
+	// header
+	nplurals := -1
+	for i, pe := range entries {
+		if pe.entry.Key != "" || pe.entry.Context != "" {
+			continue
+		}
+		lang, n, meta, err := parseCatalogHeader(pe.entry.Value)
+		if err != nil {
+			errs = append(errs, &ParseError{File: fName, Line: pe.line, Msg: err.Error()})
+		}
+		if lang != "" {
+			res.Locale = lang
+		}
+		nplurals, res.Metadata = n, meta
+		entries = append(entries[:i], entries[i+1:]...)
+		break
+	}
+
+	cats := PluralCategories(res.pluralLocale())
+	if nplurals >= 0 && nplurals != len(cats) {
+		errs = append(errs, &ParseError{File: fName, Line: 1, Msg: fmt.Sprintf("Plural-Forms header has nplurals=%d, but locale %s has %d plural categories %v", nplurals, res.Locale, len(cats), cats)})
+	}
+	for _, pe := range entries {
+		e := pe.entry
+		if e.KeyPlural != "" {
+			e.Plurals = make(map[string]string)
+			idxs := []int{}
+			for n := range pe.strs {
+				idxs = append(idxs, n)
+			}
+			sort.Ints(idxs)
+			for _, n := range idxs {
+				if n >= len(cats) {
+					errs = append(errs, &ParseError{File: fName, Line: pe.line, Msg: fmt.Sprintf("msgstr[%d] is out of range for locale %s (plural categories %v)", n, res.Locale, cats)})
+					continue
+				}
+				e.Plurals[cats[n]] = *pe.strs[n]
+			}
+		} else if len(pe.strs) > 0 {
+			errs = append(errs, &ParseError{File: fName, Line: pe.line, Msg: "msgstr[N] without msgid_plural"})
+		}
+		res.Entries = append(res.Entries, e)
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return res, errs
+	}
+	return res, nil
+}
+
+// catalogMetadataHeaders are the gettext header fields used for the locale metadata, by metadata key (see LocaleMetadata)
+var catalogMetadataHeaders = map[string]string{"name": "X-Locale-Name", "dir": "X-Text-Direction", "fallback": "X-Fallback-Locale", "plural": "X-Plural-Rule"}
+
+// parseCatalogHeader returns the Language, the number of plural forms (or -1, if there is no Plural-Forms header) and the locale metadata of a gettext header entry. Invalid metadata is returned as an error.
+func parseCatalogHeader(header string) (string, int, LocaleMetadata, error) {
+	lang, nplurals := "", -1
+	meta := LocaleMetadata{}
+	for _, h := range strings.Split(header, "\n") {
+		fs := strings.SplitN(h, ":", 2)
+		if len(fs) != 2 {
+			continue
+		}
+		name, value := strings.TrimSpace(fs[0]), strings.TrimSpace(fs[1])
+		switch name {
+		case "Language":
+			lang = value
+		case "Plural-Forms":
+			if m := npluralsRE.FindStringSubmatch(value); m != nil {
+				nplurals, _ = strconv.Atoi(m[1])
+			}
+		}
+		for key, header := range catalogMetadataHeaders {
+			if name == header {
+				if err := meta.set(key, value); err != nil {
+					return lang, nplurals, meta, fmt.Errorf("invalid %s header : %v", header, err)
+				}
+			}
+		}
+	}
+	return lang, nplurals, meta, nil
+}
+
+// catalogHeader returns the gettext header entry for the catalog, including the locale metadata
+func catalogHeader(c *Catalog) string {
+	lines := []string{
+		"Language: " + c.Locale,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+		"Plural-Forms: " + PluralForms(c.pluralLocale()),
+	}
+	for _, f := range c.Metadata.fields() {
+		lines = append(lines, catalogMetadataHeaders[f.key]+": "+f.value)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// poQuote quotes a string for a PO file. Strings with newlines are split into several lines.
+func poQuote(s string) string {
+	escape := func(s string) string {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+		return `"` + r.Replace(s) + `"`
+	}
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return escape(s)
+	}
+	lines := []string{`""`}
+	for _, l := range strings.SplitAfter(s, "\n") {
+		if l != "" {
+			lines = append(lines, escape(l))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WritePO writes the catalog in gettext PO format. The header includes the Language and Plural-Forms of the locale (see PluralForms), and the locale metadata (see LocaleMetadata).
+func (c *Catalog) WritePO(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "msgid \"\"\nmsgstr %s\n", poQuote(catalogHeader(c))); err != nil {
+		return err
+	}
+
+	cats := PluralCategories(c.pluralLocale())
+	for _, e := range c.Entries {
+		lines := []string{""}
+		for _, comment := range e.Comments {
+			lines = append(lines, "# "+comment)
+		}
+		for _, comment := range e.ExtractedComments {
+			lines = append(lines, "#. "+comment)
+		}
+		if len(e.References) > 0 {
+			lines = append(lines, "#: "+strings.Join(e.References, " "))
+		}
+		if len(e.Flags) > 0 {
+			lines = append(lines, "#, "+strings.Join(e.Flags, ", "))
+		}
+		if e.Context != "" {
+			lines = append(lines, "msgctxt "+poQuote(e.Context))
+		}
+		lines = append(lines, "msgid "+poQuote(e.Key))
+		if e.IsPlural() {
+			keyPlural := e.KeyPlural
+			if keyPlural == "" {
+				keyPlural = e.Key
+			}
+			lines = append(lines, "msgid_plural "+poQuote(keyPlural))
+			for i, cat := range cats {
+				lines = append(lines, fmt.Sprintf("msgstr[%d] %s", i, poQuote(e.Plurals[cat])))
+			}
+		} else {
+			lines = append(lines, "msgstr "+poQuote(e.Value))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
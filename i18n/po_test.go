@@ -0,0 +1,197 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadPO(t *testing.T) {
+	cat, err := ReadPO("test_files/po/ru.po")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "ru", cat.Locale; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := 5, len(cat.Entries); w != g {
+		t.Fatalf(fs, w, g)
+	}
+
+	login := cat.Entries[0]
+	if w, g := "Войти", login.Value; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[Translator comment]", fmt.Sprintf("%v", login.Comments); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[Extracted comment]", fmt.Sprintf("%v", login.ExtractedComments); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[auth_handlers.go:12 auth_handlers.go:40]", fmt.Sprintf("%v", login.References); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	open := cat.Entries[1]
+	if w, g := "menu|Open|Открыть", open.Context+"|"+open.Key+"|"+open.Value; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := true, cat.Entries[2].IsFuzzy(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	users := cat.Entries[3]
+	if w, g := "%d users", users.KeyPlural; w != g {
+		t.Errorf(fs, w, g)
+	}
+	exp := map[string]string{"one": "%d пользователь", "few": "%d пользователя", "many": "%d пользователей"}
+	if w, g := exp, users.Plurals; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+
+	if w, g := "Multi\nline|Много\nстрок", cat.Entries[4].Key+"|"+cat.Entries[4].Value; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_ReadPO_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fName := filepath.Join(dir, "sv.po")
+	po := `msgid "a"
+msgstr "b"
+msgstr[0] "c"
+
+msgid "d"
+msgfoo "e"
+"f
+`
+	if err := ioutil.WriteFile(fName, []byte(po), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadPO(fName)
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("Expected ParseErrors, got %#v", err)
+	}
+	lines := []int{}
+	for _, e := range errs {
+		lines = append(lines, e.Line)
+	}
+	if w, g := "[1 6 7]", fmt.Sprintf("%v", lines); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Catalog_RoundTrip(t *testing.T) {
+	cat, err := ReadPO("test_files/po/ru.po")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	dir, err := ioutil.TempDir("", "po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// PO -> PO
+	poFile := filepath.Join(dir, "ru.po")
+	if err := cat.WriteFile(poFile, SyntaxTab); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	po, err := ReadPO(poFile)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := cat, po; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+
+	// PO -> MO (fuzzy entries are left out)
+	moFile := filepath.Join(dir, "ru.mo")
+	if err := cat.WriteFile(moFile, SyntaxTab); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	mo, err := ReadMO(moFile)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range mo.Entries {
+		got[e.Context+"|"+e.Key] = fmt.Sprintf("%s%v", e.Value, e.Plurals)
+	}
+	exp := map[string]string{
+		"|Login":       "Войтиmap[]",
+		"menu|Open":    "Открытьmap[]",
+		"|%d user":     "map[few:%d пользователя many:%d пользователей one:%d пользователь]",
+		"|Multi\nline": "Много\nстрокmap[]",
+	}
+	if w, g := exp, got; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Catalog_Properties(t *testing.T) {
+	cat, err := ReadPropertiesCatalog("test_files/valid/sv.properties", SyntaxTab)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	users := cat.Entries[len(cat.Entries)-1]
+	if w, g := "%d users", users.Key; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "map[one:%d användare other:%d användare]", fmt.Sprintf("%v", users.Plurals); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// properties -> PO -> properties
+	var buf bytes.Buffer
+	if err := cat.WritePO(&buf); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	dir, err := ioutil.TempDir("", "po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	poFile := filepath.Join(dir, "sv.po")
+	if err := ioutil.WriteFile(poFile, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	po, err := ReadCatalog(poFile, SyntaxTab)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	var props bytes.Buffer
+	if err := po.WriteProperties(&props, SyntaxTab); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	orig, err := ioutil.ReadFile("test_files/valid/sv.properties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, g := string(orig), props.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// message contexts are written as sections
+	ru, err := ReadPO("test_files/po/ru.po")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	props.Reset()
+	if err := ru.WriteProperties(&props, SyntaxJava); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := "\n[menu]\nOpen = Открыть\n", props.String()[strings.Index(props.String(), "\n[menu]"):]; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
@@ -0,0 +1,302 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Syntax of the property files
+type Syntax int
+
+const (
+	// SyntaxTab is the strict tab separated format: one key and one value per line, separated by a single tab. Lines starting with # are comments. Empty lines are ignored. A line with a name in brackets, such as [auth.login], starts a section: the keys that follow are in that message context (see ContextKey), until the next section; [] ends the section. Any other line is an error.
+	SyntaxTab Syntax = iota
+
+	// SyntaxJava is the Java properties format (see https://docs.oracle.com/javase/8/docs/api/java/util/Properties.html#load-java.io.Reader-): the key is separated from the value by =, : or white space; # and ! start comments; special characters are escaped using backslash (including \uXXXX escapes); and lines ending with a backslash continue on the next line. Since white space ends the key, spaces in keys must be escaped (as in "Logged\ in = Inloggad"). Files are read as UTF-8. Sections ([auth.login]) are used for message contexts, as in SyntaxTab.
+	SyntaxJava
+)
+
+func (s Syntax) String() string {
+	switch s {
+	case SyntaxTab:
+		return "tab"
+	case SyntaxJava:
+		return "java"
+	}
+	return fmt.Sprintf("Syntax(%d)", int(s))
+}
+
+// ParseSyntax parses a syntax name (tab or java)
+func ParseSyntax(name string) (Syntax, error) {
+	switch strings.ToLower(name) {
+	case "tab":
+		return SyntaxTab, nil
+	case "java":
+		return SyntaxJava, nil
+	}
+	return SyntaxTab, fmt.Errorf("unknown property file syntax: %s", name)
+}
+
+// ParseError is a malformed line in a property file (or other i18n file)
+type ParseError struct {
+	File string
+	// Line is 0 if the line number is unknown
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ParseErrors lists all malformed lines in a property file
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+	msgs := []string{}
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// sectionRE matches section lines, e.g. [auth.login]
+var sectionRE = regexp.MustCompile(`^\[([^\[\]\t]*)\]$`)
+
+// parseSection returns the section name, if the line is a section line
+func parseSection(l string) (string, bool) {
+	if m := sectionRE.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+		return strings.TrimSpace(m[1]), true
+	}
+	return "", false
+}
+
+// propEntry is a key-value pair read from a property file, with the (first) line number, and the comment lines immediately preceding the entry. Keys in sections are context keys (see ContextKey).
+type propEntry struct {
+	key      string
+	value    string
+	line     int
+	comments []string
+}
+
+// parseProperties parses the lines of a property file. Malformed lines are returned as ParseErrors.
+func parseProperties(fName string, lines []string, syntax Syntax) ([]propEntry, error) {
+	switch syntax {
+	case SyntaxTab:
+		return parseTabProperties(fName, lines)
+	case SyntaxJava:
+		return parseJavaProperties(fName, lines)
+	}
+	return nil, fmt.Errorf("unknown property file syntax: %v", syntax)
+}
+
+func parseTabProperties(fName string, lines []string) ([]propEntry, error) {
+	res := []propEntry{}
+	errs := ParseErrors{}
+	comments := []string{}
+	section := ""
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			comments = []string{}
+			continue
+		}
+		if name, ok := parseSection(l); ok {
+			section = name
+			comments = []string{}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(l), "#") {
+			comments = append(comments, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "#")))
+			continue
+		}
+		fs := strings.Split(l, "\t")
+		switch {
+		case len(fs) == 1:
+			errs = append(errs, &ParseError{File: fName, Line: i + 1, Msg: "missing tab between key and value"})
+		case len(fs) > 2:
+			errs = append(errs, &ParseError{File: fName, Line: i + 1, Msg: fmt.Sprintf("expected one tab, found %d", len(fs)-1)})
+		case fs[0] == "":
+			errs = append(errs, &ParseError{File: fName, Line: i + 1, Msg: "empty key"})
+		default:
+			res = append(res, propEntry{key: ContextKey(section, fs[0]), value: fs[1], line: i + 1, comments: comments})
+		}
+		comments = []string{}
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// endsWithContinuation checks if the line ends with an odd number of backslashes
+func endsWithContinuation(l string) bool {
+	n := 0
+	for i := len(l) - 1; i >= 0 && l[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func parseJavaProperties(fName string, lines []string) ([]propEntry, error) {
+	res := []propEntry{}
+	errs := ParseErrors{}
+	comments := []string{}
+	section := ""
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		l := strings.TrimLeft(lines[i], " \t\f")
+		if l == "" {
+			comments = []string{}
+			continue
+		}
+		if name, ok := parseSection(l); ok {
+			section = name
+			comments = []string{}
+			continue
+		}
+		if strings.HasPrefix(l, "#") || strings.HasPrefix(l, "!") {
+			comments = append(comments, strings.TrimSpace(l[1:]))
+			continue
+		}
+		// join continuation lines (leading white space on the following lines is dropped)
+		for endsWithContinuation(l) {
+			l = l[:len(l)-1]
+			if i+1 >= len(lines) {
+				break
+			}
+			i++
+			l += strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		entryComments := comments
+		comments = []string{}
+
+		key, value := splitJavaProperty(l)
+		if key == "" {
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: "empty key"})
+			continue
+		}
+		var err error
+		if key, err = unescapeJava(key); err != nil {
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: err.Error()})
+			continue
+		}
+		if value, err = unescapeJava(value); err != nil {
+			errs = append(errs, &ParseError{File: fName, Line: lineNo, Msg: err.Error()})
+			continue
+		}
+		res = append(res, propEntry{key: ContextKey(section, key), value: value, line: lineNo, comments: entryComments})
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// splitJavaProperty splits a logical line into the (escaped) key and value
+func splitJavaProperty(l string) (string, string) {
+	keyEnd := len(l)
+	for i := 0; i < len(l); i++ {
+		if l[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", l[i]) >= 0 {
+			keyEnd = i
+			break
+		}
+	}
+	key, rest := l[:keyEnd], strings.TrimLeft(l[keyEnd:], " \t\f")
+	if strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, ":") {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return key, rest
+}
+
+// unescapeJava resolves the escape sequences of a Java properties key or value
+func unescapeJava(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\uXXXX escape: \\%s", s[i:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uXXXX escape: \\%s", s[i:i+5])
+			}
+			i += 4
+			// surrogate pair
+			if utf16.IsSurrogate(rune(r)) && i+6 < len(s) && s[i+1:i+3] == "\\u" {
+				if r2, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if dec := utf16.DecodeRune(rune(r), rune(r2)); dec != unicode.ReplacementChar {
+						b.WriteRune(dec)
+						i += 6
+						continue
+					}
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// escapeJava escapes a key or value for a Java properties file. Non-ASCII characters are written as is (UTF-8).
+func escapeJava(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if isKey {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case ' ':
+			// spaces end the key, and leading spaces in the value are dropped
+			if isKey || i == 0 {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
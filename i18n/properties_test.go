@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_ParseJavaProperties(t *testing.T) {
+	lines := []string{
+		"# comment",
+		"   ! comment",
+		"",
+		"key1=value1",
+		"key2 : value2",
+		"key3 value3",
+		"   key4\t=\t value4 with spaces  ",
+		"key\\ 5 = \\u00e5\\u00e4\\u00f6 \\uD83D\\uDE00",
+		"key6 = line 1 \\",
+		"       line 2\\\\",
+		"key7 = tab\\there\\nnewline \\# \\= \\:",
+		"key8",
+		"key9 = a \\",
+		"",
+		"key10 = b",
+	}
+	entries, err := parseProperties("test.properties", lines, SyntaxJava)
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	exp := []propEntry{
+		{"key1", "value1", 4, nil},
+		{"key2", "value2", 5, nil},
+		{"key3", "value3", 6, nil},
+		{"key4", "value4 with spaces  ", 7, nil},
+		{"key 5", "åäö 😀", 8, nil},
+		{"key6", "line 1 line 2\\", 9, nil},
+		{"key7", "tab\there\nnewline # = :", 11, nil},
+		{"key8", "", 12, nil},
+		{"key9", "a ", 13, nil},
+		{"key10", "b", 15, nil},
+	}
+	if w, g := fmt.Sprintf("%q", exp), fmt.Sprintf("%q", entries); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	_, err = parseProperties("test.properties", []string{"ok = 1", "bad = \\u00g1", "= no key", "ok2 = 2"}, SyntaxJava)
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("Expected ParseErrors, got %v", err)
+	}
+	if w, g := "test.properties:2: malformed \\uXXXX escape: \\u00g1\ntest.properties:3: empty key", fmt.Sprintf("%v", err); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_ParseTabProperties(t *testing.T) {
+	lines := []string{
+		"# comment",
+		"Logged in\tInloggad",
+		"",
+		"Logged out",
+		"Users\tAnvändare\textra",
+		"\tno key",
+	}
+	entries, err := parseProperties("sv.properties", lines, SyntaxTab)
+	if w, g := 1, len(entries); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "sv.properties:4: missing tab between key and value\nsv.properties:5: expected one tab, found 2\nsv.properties:6: empty key", fmt.Sprintf("%v", err); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Load_JavaSyntax(t *testing.T) {
+	db := NewI18NDB("test_files/java", "en")
+	db.Syntax = SyntaxJava
+	if err := db.Load(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Errorf("Unexpected error : %v", err)
+	}
+	for _, msg := range msgs {
+		t.Errorf("Unexpected validation error : %v", msg)
+	}
+	sv := db.GetOrDefault("sv")
+	if w, g := "Inloggad som användare hanna", sv.S("Logged in as user %s", "hanna"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Välkommen till demoservern. Logga in.", sv.S("Welcome text"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Hej, hanna!", sv.M("Hello, {name}!", map[string]interface{}{"name": "hanna"}); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "värde", sv.S("Key=with:separators"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// the same files are malformed in the strict tab syntax
+	db = NewI18NDB("test_files/java", "en")
+	err = db.Load()
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("Expected ParseErrors, got %v", err)
+	} else if w, g := "test_files/java/en.properties:2: missing tab between key and value", errs[0].Error(); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
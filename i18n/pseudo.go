@@ -0,0 +1,149 @@
+package i18n
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// pseudoAccents maps ASCII letters to accented look-alikes (see Pseudolocalize)
+var pseudoAccents = map[rune]rune{
+	'A': 'Å', 'B': 'Ɓ', 'C': 'Ç', 'D': 'Ð', 'E': 'É', 'F': 'Ƒ', 'G': 'Ĝ', 'H': 'Ĥ', 'I': 'Î', 'J': 'Ĵ', 'K': 'Ķ', 'L': 'Ļ', 'M': 'Ṁ',
+	'N': 'Ñ', 'O': 'Ö', 'P': 'Þ', 'Q': 'Ǫ', 'R': 'Ŕ', 'S': 'Š', 'T': 'Ţ', 'U': 'Û', 'V': 'Ṽ', 'W': 'Ŵ', 'X': 'Ẋ', 'Y': 'Ý', 'Z': 'Ž',
+	'a': 'á', 'b': 'ƀ', 'c': 'ç', 'd': 'ð', 'e': 'é', 'f': 'ƒ', 'g': 'ĝ', 'h': 'ĥ', 'i': 'î', 'j': 'ĵ', 'k': 'ķ', 'l': 'ļ', 'm': 'ṁ',
+	'n': 'ñ', 'o': 'ö', 'p': 'þ', 'q': 'ǫ', 'r': 'ŕ', 's': 'š', 't': 'ţ', 'u': 'û', 'v': 'ṽ', 'w': 'ŵ', 'x': 'ẋ', 'y': 'ý', 'z': 'ž',
+}
+
+// pseudoProtectRE matches the parts of a translation kept as is by Pseudolocalize: fmt.Sprintf verbs, HTML tags and HTML entities
+var pseudoProtectRE = regexp.MustCompile(`^(?:%%|%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z]|<[^<>]*>|&#?[a-zA-Z0-9]+;)`)
+
+// pseudoPadding is the character used to elongate pseudolocalized text
+const pseudoPadding = "~"
+
+// Pseudolocalize returns a pseudo translation of s, for testing the localization of a user interface: letters are replaced by accented look-alikes, the text is elongated by about a third (to reveal layout overflow), and wrapped in brackets (to reveal truncated and concatenated strings), e.g. "Hello, %s!" becomes "[Ĥéļļö, %s!~~]". Placeholders are preserved: fmt.Sprintf verbs, ICU MessageFormat arguments (the texts of plural and select options are pseudolocalized), HTML tags and HTML entities.
+func Pseudolocalize(s string) string {
+	var b strings.Builder
+	src := []rune(s)
+	letters := 0
+	pseudoMessage(src, 0, false, &b, &letters)
+	return "[" + b.String() + strings.Repeat(pseudoPadding, (letters+2)/3) + "]"
+}
+
+// pseudoMessage pseudolocalizes the literal text of a message from pos, until the end (or, in a nested message, an unmatched }), and returns the end position. The number of replaced letters is added to letters.
+func pseudoMessage(src []rune, pos int, nested bool, b *strings.Builder, letters *int) int {
+	for pos < len(src) {
+		r := src[pos]
+		switch {
+		case r == '}' && nested:
+			return pos
+		case r == '{':
+			pos = pseudoArg(src, pos, b, letters)
+			continue
+		case r == '#' && nested:
+			b.WriteRune(r)
+		case r == '\'' && pos+1 < len(src) && src[pos+1] == '\'':
+			b.WriteString("''")
+			pos++
+		case r == '\'' && pos+1 < len(src) && strings.ContainsRune("{}#|", src[pos+1]):
+			// quoted literal, up to the next single apostrophe
+			end := pos + 1
+			for end < len(src) && src[end] != '\'' {
+				end++
+			}
+			if end < len(src) {
+				end++
+			}
+			b.WriteString(string(src[pos:end]))
+			pos = end
+			continue
+		case r == '%' || r == '<' || r == '&':
+			if m := pseudoProtectRE.FindString(string(src[pos:])); m != "" {
+				b.WriteString(m)
+				pos += len([]rune(m))
+				continue
+			}
+			b.WriteRune(r)
+		default:
+			if a, ok := pseudoAccents[r]; ok {
+				b.WriteRune(a)
+				*letters++
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		pos++
+	}
+	return pos
+}
+
+// pseudoArg copies an ICU MessageFormat argument starting at pos (at the opening brace) as is, except for the texts of plural and select options, which are pseudolocalized. Returns the position after the closing brace.
+func pseudoArg(src []rune, pos int, b *strings.Builder, letters *int) int {
+	b.WriteRune(src[pos])
+	pos++
+	for pos < len(src) {
+		r := src[pos]
+		b.WriteRune(r)
+		pos++
+		switch r {
+		case '}':
+			return pos
+		case '{':
+			pos = pseudoMessage(src, pos, true, b, letters)
+			if pos < len(src) {
+				b.WriteRune(src[pos])
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+// newPseudoI18N returns a pseudo locale with the pseudolocalized translations of src (see Pseudolocalize). The metadata is copied from src, with a pseudolocalized name.
+func newPseudoI18N(name string, src *I18N) *I18N {
+	res := newI18N(name)
+	res.meta = src.meta
+	res.meta.Name = Pseudolocalize(src.Name())
+	res.meta.Fallback = ""
+	// the plural categories are those of the source locale
+	res.meta.PluralRule = pluralRuleForLocale(src.pluralLocale()).id
+	res.pseudo = true
+	add := func(key, value string) {
+		if err := res.add(key, Pseudolocalize(value)); err != nil {
+			log.Printf("Couldn't add pseudo translation : %v", err)
+		}
+	}
+	for _, key := range src.keys {
+		if value, ok := src.dict[key]; ok {
+			add(key, value)
+		}
+		for _, cat := range pluralCategories {
+			if value, ok := src.plurals[key][cat]; ok {
+				add(key+"["+cat+"]", value)
+			}
+		}
+		if name, values, ok := src.selectValues(key); ok {
+			for _, v := range values {
+				add(selectVariantKey(key, name, v), src.selects[key].values[v])
+			}
+		}
+	}
+	return res
+}
+
+// addPseudoLocale adds the pseudo locale (see PseudoLocale) to i18ns, synthesized from the default locale. If there is an i18n file for the pseudo locale, or no default locale, nothing is added.
+func (db *I18NDB) addPseudoLocale(i18ns map[string]*I18N) {
+	if db.PseudoLocale == "" {
+		return
+	}
+	if _, ok := i18ns[db.PseudoLocale]; ok {
+		log.Printf("Pseudo locale %s is defined in an i18n file, and is not synthesized", db.PseudoLocale)
+		return
+	}
+	def, ok := i18ns[db.DefaultLocale]
+	if !ok {
+		log.Printf("No default locale %s, couldn't create pseudo locale %s", db.DefaultLocale, db.PseudoLocale)
+		return
+	}
+	i18ns[db.PseudoLocale] = newPseudoI18N(db.PseudoLocale, def)
+	log.Printf("Created pseudo locale %s from locale %s", db.PseudoLocale, db.DefaultLocale)
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_Pseudolocalize(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"Hello, %s!", "[Ĥéļļö, %s!~~]"},
+		{"%[1]d of %5.2f%%", "[%[1]d öƒ %5.2f%%~]"},
+		{"Click <a href=\"/x\">here</a>&nbsp;now", "[Çļîçķ <a href=\"/x\">ĥéŕé</a>&nbsp;ñöŵ~~~~]"},
+		{"{count, plural, one {# file} other {# files in {dir}}}", "[{count, plural, one {# ƒîļé} other {# ƒîļéš îñ {dir}}}~~~~]"},
+		{"It''s '{literal}'", "[Îţ''š '{literal}'~]"},
+		{"", "[]"},
+	}
+	for _, test := range tests {
+		if w, g := test.exp, Pseudolocalize(test.in); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_PseudoLocale(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties": "#@name: English\n\nHello, %s\tHello, %s\n%d files[one]\t%d file\n%d files[other]\t%d files\n",
+		"sv.properties": "#@name: Svenska\n\nHello, %s\tHej, %s\n%d files[one]\t%d fil\n%d files[other]\t%d filer\n",
+	})
+	defer os.RemoveAll(dir)
+
+	// opt-in
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if _, ok := db.LocaleInfo("en-XA"); ok {
+		t.Errorf("Expected no pseudo locale by default")
+	}
+
+	db = NewI18NDB(dir, "en")
+	db.PseudoLocale = "en-XA"
+	if err := db.Load(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	xa := db.GetOrDefault("en-XA")
+	if w, g := "en-XA", xa.locale; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[Ĥéļļö, Anna~~]", xa.S("Hello, %s", "Anna"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[1 ƒîļé~~]", xa.N("%d files", 1); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[2 ƒîļéš~~]", xa.N("%d files", 2); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[Éñĝļîšĥ~~~]", xa.Name(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// the pseudo locale is not cross validated
+	msgs, err := db.CrossValidate()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 0, len(msgs); w != g {
+		t.Errorf(fs, w, g)
+		t.Errorf("Unexpected messages: %v", msgs)
+	}
+
+	r := httptest.NewRequest("GET", "/?locale=en-XA", nil)
+	if w, g := "en-XA", db.GetI18NFromRequest(r).locale; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
@@ -0,0 +1,227 @@
+package i18n
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileStat is used to detect changes in the property files
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// statPropDir lists the i18n files in dir (including namespace files), with modification times and sizes
+func statPropDir(fsys iofs.FS, dir string) (map[string]fileStat, error) {
+	files, err := listI18NFiles(fsys, dir)
+	if err != nil {
+		return make(map[string]fileStat), err
+	}
+	return statFiles(fsys, files)
+}
+
+// statFiles lists the i18n files, with modification times and sizes
+func statFiles(fsys iofs.FS, files []string) (map[string]fileStat, error) {
+	res := make(map[string]fileStat)
+	for _, f := range files {
+		if !isI18NFile(f) && !isFormatDataFile(f) {
+			continue
+		}
+		info, err := statFS(fsys, f)
+		if err != nil {
+			return res, err
+		}
+		res[f] = fileStat{modTime: info.ModTime(), size: info.Size()}
+	}
+	return res, nil
+}
+
+// changedFiles lists the files that have been added, removed or modified between two snapshots
+func changedFiles(old, new map[string]fileStat) []string {
+	res := []string{}
+	for f, st := range new {
+		if oldSt, ok := old[f]; !ok || oldSt != st {
+			res = append(res, f)
+		}
+	}
+	for f := range old {
+		if _, ok := new[f]; !ok {
+			res = append(res, f)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// ValidationError is returned when reloaded property files fail cross validation, i.e., there are findings with severity error (see CrossValidateFindings)
+type ValidationError struct {
+	// Messages of the findings with severity error
+	Messages []string
+	// Findings lists all findings, including warnings
+	Findings []Finding
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("cross validation failed with %d errors", len(e.Messages))
+}
+
+// ReloadEvent reports the result of a reload triggered by Watch
+type ReloadEvent struct {
+	// Changed lists the property files that were added, removed or modified
+	Changed []string
+	// Err is nil if the new property files were loaded. Otherwise, the previously loaded files are still in use. If the new files failed cross validation, Err is a *ValidationError.
+	Err error
+}
+
+// Reload re-reads all property files in Dir. The new files are cross validated, and replace the current data only if there are no findings with severity error (if there are, a *ValidationError is returned; warnings are ignored). I18N instances retrieved before the reload are not affected.
+func (db *I18NDB) Reload() error {
+	stats, err := statPropDir(db.FS, db.Dir)
+	if err != nil {
+		return err
+	}
+	return db.reload(stats, nil)
+}
+
+// reload reads the property files in stats. Files not listed in changed (if non-nil) are assumed to be unmodified, and are not re-read.
+func (db *I18NDB) reload(stats map[string]fileStat, changed []string) error {
+	db.reloadMutex.Lock()
+	defer db.reloadMutex.Unlock()
+
+	db.mutex.RLock()
+	old := db.data
+	db.mutex.RUnlock()
+
+	files := []string{}
+	for fName := range stats {
+		files = append(files, fName)
+	}
+	sort.Strings(files)
+	if err := checkDuplicateLocales(db.Dir, files); err != nil {
+		return err
+	}
+	// a locale is re-read if any of its files has changed
+	changedLocs := make(map[string]bool)
+	for _, fName := range changed {
+		locName, _ := i18nFileID(db.Dir, fName)
+		changedLocs[locName] = true
+	}
+
+	tmp := newI18NDB(db.Dir, db.DefaultLocale)
+	tmp.Syntax = db.Syntax
+	tmp.FS = db.FS
+	tmp.Severities = db.Severities
+	tmp.ReferenceLocale = db.ReferenceLocale
+	tmp.PseudoLocale = db.PseudoLocale
+	tmp.missing = db.missing
+	formatFiles := []string{}
+	for _, fName := range files {
+		locName, ns := i18nFileID(db.Dir, fName)
+		if isFormatDataFile(fName) {
+			formatFiles = append(formatFiles, fName)
+			continue
+		}
+		if loc, ok := old[locName]; ok && changed != nil && !changedLocs[locName] {
+			if _, copied := tmp.data[locName]; !copied {
+				// copy, since the fallbacks are relinked below
+				cp := *loc
+				tmp.data[locName] = &cp
+			}
+			continue
+		}
+		loc, ok := tmp.data[locName]
+		if !ok {
+			loc = newI18N(locName)
+			tmp.data[locName] = loc
+		}
+		if err := readI18NFile(db.FS, loc, ns, fName, db.Syntax); err != nil {
+			return err
+		}
+	}
+	for _, fName := range formatFiles {
+		if locName, _ := i18nFileID(db.Dir, fName); changed == nil || changedLocs[locName] {
+			if err := readFormatDataFile(db.FS, tmp.data, fName); err != nil {
+				return err
+			}
+		}
+	}
+	tmp.addPseudoLocale(tmp.data)
+	tmp.linkFallbacks()
+
+	findings, err := tmp.CrossValidateFindings()
+	if err != nil {
+		return err
+	}
+	if HasErrors(findings) {
+		msgs := []string{}
+		for _, f := range findings {
+			if f.Severity == SeverityError {
+				msgs = append(msgs, f.Message)
+			}
+		}
+		return &ValidationError{Messages: msgs, Findings: findings}
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.data = tmp.data
+	db.tags = tmp.tags
+	db.files = stats
+	return nil
+}
+
+// Watch polls Dir for changes to the property files, at the specified interval. When files are added, removed or modified, the changed files are re-read and the data is reloaded (see Reload). The callback (if not nil) is called after each reload attempt. Watch returns a function that stops watching.
+func (db *I18NDB) Watch(interval time.Duration, callback func(ReloadEvent)) (func(), error) {
+	if db.Dir == "" {
+		return nil, fmt.Errorf("no i18n folder to watch")
+	}
+	if _, err := statPropDir(db.FS, db.Dir); err != nil {
+		return nil, err
+	}
+	db.mutex.RLock()
+	seen := db.files
+	db.mutex.RUnlock()
+
+	stop := make(chan bool)
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			stats, err := statPropDir(db.FS, db.Dir)
+			if err != nil {
+				log.Printf("Couldn't watch i18n folder : %v", err)
+				continue
+			}
+			if len(changedFiles(seen, stats)) == 0 {
+				continue
+			}
+			// don't retry until the files are changed again
+			seen = stats
+
+			// compare to the loaded files, in case a previous reload failed
+			db.mutex.RLock()
+			changed := changedFiles(db.files, stats)
+			db.mutex.RUnlock()
+
+			err = db.reload(stats, changed)
+			if err != nil {
+				log.Printf("Couldn't reload i18n files %v : %v", changed, err)
+			} else {
+				log.Printf("Reloaded i18n files %v", changed)
+			}
+			if callback != nil {
+				callback(ReloadEvent{Changed: changed, Err: err})
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(stop) }) }, nil
+}
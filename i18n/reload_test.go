@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePropFile(t *testing.T, fName, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(fName, []byte(content), 0644); err != nil {
+		t.Fatalf("Couldn't write file : %v", err)
+	}
+	if err := os.Chtimes(fName, modTime, modTime); err != nil {
+		t.Fatalf("Couldn't set file time : %v", err)
+	}
+}
+
+func Test_I18NDB_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir : %v", err)
+	}
+	defer os.RemoveAll(dir)
+	en, sv := filepath.Join(dir, "en.properties"), filepath.Join(dir, "sv.properties")
+	now := time.Now()
+	writePropFile(t, en, "Users\tUsers\n", now)
+	writePropFile(t, sv, "Users\tAnvändare\n", now)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	events := make(chan ReloadEvent)
+	stop, err := db.Watch(10*time.Millisecond, func(e ReloadEvent) { events <- e })
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer stop()
+	wait := func() ReloadEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for reload")
+		}
+		return ReloadEvent{}
+	}
+
+	// valid change
+	oldSv := db.GetOrDefault("sv")
+	writePropFile(t, sv, "Users\tAnvändarna\n", now.Add(time.Second))
+	e := wait()
+	if e.Err != nil {
+		t.Errorf("Unexpected error : %v", e.Err)
+	}
+	if exp, got := "["+sv+"]", fmt.Sprintf("%v", e.Changed); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Användarna", db.GetOrDefault("sv").S("Users"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Användare", oldSv.S("Users"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+
+	// invalid change: the previous data is kept
+	writePropFile(t, sv, "Users\tAnvändarna\nGroups\tGrupper\n", now.Add(2*time.Second))
+	e = wait()
+	var valErr *ValidationError
+	if !errors.As(e.Err, &valErr) {
+		t.Errorf("Expected validation error, got %v", e.Err)
+	}
+	if _, ok := db.GetOrDefault("sv").dict["Groups"]; ok {
+		t.Errorf("Expected invalid data to be discarded")
+	}
+
+	// fixing another file reloads both
+	writePropFile(t, en, "Users\tUsers\nGroups\tGroups\n", now.Add(3*time.Second))
+	e = wait()
+	if e.Err != nil {
+		t.Errorf("Unexpected error : %v", e.Err)
+	}
+	if exp, got := "["+en+" "+sv+"]", fmt.Sprintf("%v", e.Changed); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+	if exp, got := "Grupper", db.GetOrDefault("sv").S("Groups"); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+
+	// removed file
+	if err := os.Remove(sv); err != nil {
+		t.Fatalf("Couldn't remove file : %v", err)
+	}
+	if e = wait(); e.Err != nil {
+		t.Errorf("Unexpected error : %v", e.Err)
+	}
+	if exp, got := "[en]", fmt.Sprintf("%v", db.ListLocales()); exp != got {
+		t.Errorf(fs, exp, got)
+	}
+}
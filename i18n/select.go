@@ -0,0 +1,149 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// SelectOther is the select value used when the value of the selector is not defined for a key (like the "other" option of an ICU MessageFormat select argument)
+const SelectOther = "other"
+
+// selectKeyRE matches select variant keys, such as "Welcome, %s![gender=female]"
+var selectKeyRE = regexp.MustCompile(`^(.+)\[([a-zA-Z][a-zA-Z0-9_-]*)=([^\[\]=]+)\]$`)
+
+// splitSelectKey splits a select variant key, such as "Welcome, %s![gender=female]", into the base key, the selector name and the selector value. If the key is not a select variant, the name and value are empty.
+func splitSelectKey(key string) (string, string, string) {
+	m := selectKeyRE.FindStringSubmatch(key)
+	if m == nil {
+		return key, "", ""
+	}
+	return m[1], m[2], m[3]
+}
+
+// selectVariantKey returns the key of the variant for the selector name and value
+func selectVariantKey(key, name, value string) string {
+	return key + "[" + name + "=" + value + "]"
+}
+
+// baseKey returns key without plural category or select value
+func baseKey(key string) string {
+	if base, name, _ := splitSelectKey(key); name != "" {
+		return base
+	}
+	base, _ := splitPluralKey(key)
+	return base
+}
+
+// selectVariants are the select variants of a key: the name of the selector, and the translations by selector value
+type selectVariants struct {
+	name   string
+	values dict
+}
+
+// sortedValues lists the selector values, sorted
+func (v *selectVariants) sortedValues() []string {
+	res := []string{}
+	for value := range v.values {
+		res = append(res, value)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// choose returns the selector value to use, given the value of the selector: the value itself, if defined, or else SelectOther, or else the first value in alphabetical order
+func (v *selectVariants) choose(value string) string {
+	if _, ok := v.values[value]; ok {
+		return value
+	}
+	if _, ok := v.values[SelectOther]; ok {
+		return SelectOther
+	}
+	return v.sortedValues()[0]
+}
+
+// addSelect adds a select variant of baseKey. Returns an error if the key has variants for another selector, or a regular translation.
+func (i *I18N) addSelect(baseKey, name, value, translation string) error {
+	if _, ok := i.dict[baseKey]; ok {
+		return fmt.Errorf("key %s has both a regular translation and select variants", baseKey)
+	}
+	if _, ok := i.plurals[baseKey]; ok {
+		return fmt.Errorf("key %s has both plural and select variants", baseKey)
+	}
+	vs, ok := i.selects[baseKey]
+	if !ok {
+		vs = &selectVariants{name: name, values: make(dict)}
+		i.selects[baseKey] = vs
+	}
+	if vs.name != name {
+		return fmt.Errorf("key %s has variants for different selectors: %s and %s", baseKey, vs.name, name)
+	}
+	vs.values[value] = translation
+	return nil
+}
+
+// selectVariant returns the key and translation of the select variant of key chosen by attrs, if key has select variants
+func (i *I18N) selectVariant(key string, attrs map[string]string) (string, string, bool) {
+	vs, ok := i.selects[key]
+	if !ok {
+		return "", "", false
+	}
+	value := vs.choose(attrs[vs.name])
+	return selectVariantKey(key, vs.name, value), vs.values[value], true
+}
+
+// selectorName returns the selector name of key, if key has select variants in the locale or any of its fallbacks
+func (i *I18N) selectorName(key string) (string, bool) {
+	for _, loc := range i.fallbackChain() {
+		if vs, ok := loc.selects[key]; ok {
+			return vs.name, true
+		}
+	}
+	return "", false
+}
+
+// WithAttributes returns a copy of the I18N instance using the attributes attrs (e.g. the gender of the user) to choose between select variants of translations (see S). Select variants are defined in the i18n files using the selector name and value in brackets after the key, e.g. "Welcome, %s![gender=female]". If there is no variant for the value of the attribute, the variant with value "other" is used.
+func (i *I18N) WithAttributes(attrs map[string]string) *I18N {
+	cp := *i
+	cp.attrs = make(map[string]string)
+	for k, v := range attrs {
+		cp.attrs[k] = v
+	}
+	return &cp
+}
+
+// Attributes returns the attributes used to choose between select variants (see WithAttributes)
+func (i *I18N) Attributes() map[string]string {
+	res := make(map[string]string)
+	for k, v := range i.attrs {
+		res[k] = v
+	}
+	return res
+}
+
+// Select is used to look up the localized version of the input string (s), like S, but with the select variant chosen by attrs (e.g. map[string]string{"gender": "female"}), rather than by the attributes of the instance. Attributes not in attrs are taken from the instance (see WithAttributes).
+func (i *I18N) Select(s string, attrs map[string]string, args ...interface{}) string {
+	merged := i.Attributes()
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	return i.WithAttributes(merged).S(s, args...)
+}
+
+// selectInfo describes the select variants of key, for cross validation findings, e.g. "gender=[female male other]" (or "-" if key has no select variants)
+func (i *I18N) selectInfo(key string) string {
+	name, values, ok := i.selectValues(key)
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%s=%v", name, values)
+}
+
+// selectValues returns the selector name and the (sorted) selector values of key, if key has select variants
+func (i *I18N) selectValues(key string) (string, []string, bool) {
+	vs, ok := i.selects[key]
+	if !ok {
+		return "", nil, false
+	}
+	return vs.name, vs.sortedValues(), true
+}
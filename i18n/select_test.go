@@ -0,0 +1,200 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_splitSelectKey(t *testing.T) {
+	tests := []struct {
+		in       string
+		expBase  string
+		expName  string
+		expValue string
+	}{
+		{"Welcome, %s![gender=female]", "Welcome, %s!", "gender", "female"},
+		{"[menu] Open[role=admin]", "[menu] Open", "role", "admin"},
+		{"%d users[one]", "%d users[one]", "", ""},
+		{"Welcome text[html]", "Welcome text[html]", "", ""},
+		{"[gender=female]", "[gender=female]", "", ""},
+		{"Welcome[gender=]", "Welcome[gender=]", "", ""},
+	}
+	for _, test := range tests {
+		base, name, value := splitSelectKey(test.in)
+		if w, g := test.expBase, base; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := test.expName, name; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := test.expValue, value; w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func newTestSelectI18N(t *testing.T) *I18N {
+	i := newI18N("sv")
+	for _, kv := range [][]string{
+		{"Welcome, %s![gender=female]", "Välkommen, %s! Hon är inloggad."},
+		{"Welcome, %s![gender=male]", "Välkommen, %s! Han är inloggad."},
+		{"Welcome, %s![gender=other]", "Välkommen, %s! Hen är inloggad."},
+		{"{name} logged in[gender=female]", "{name} loggade in. Hon"},
+		{"{name} logged in[gender=male]", "{name} loggade in. Han"},
+		{"Login", "Logga in"},
+	} {
+		if err := i.add(kv[0], kv[1]); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	return i
+}
+
+func Test_Select(t *testing.T) {
+	i := newTestSelectI18N(t)
+	if w, g := []string{"Welcome, %s!", "{name} logged in", "Login"}, i.keys; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+	if !i.hasKey("Welcome, %s!") {
+		t.Errorf("Expected select key to be defined")
+	}
+
+	// no attributes: the other variant
+	if w, g := "Välkommen, Kim! Hen är inloggad.", i.S("Welcome, %s!", "Kim"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	anna := i.WithAttributes(map[string]string{"gender": "female"})
+	if w, g := "Välkommen, Anna! Hon är inloggad.", anna.S("Welcome, %s!", "Anna"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "female", anna.Attributes()["gender"]; w != g {
+		t.Errorf(fs, w, g)
+	}
+	// the original instance is not changed
+	if w, g := 0, len(i.Attributes()); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Logga in", anna.S("Login"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Välkommen, Bo! Han är inloggad.", anna.Select("Welcome, %s!", map[string]string{"gender": "male"}, "Bo"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	// unknown values: the other variant
+	if w, g := "Välkommen, Kim! Hen är inloggad.", i.Select("Welcome, %s!", map[string]string{"gender": "x"}, "Kim"); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// M: chosen by the named argument, or else by the attributes
+	if w, g := "Bo loggade in. Han", anna.M("{name} logged in", map[string]interface{}{"name": "Bo", "gender": "male"}); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "Anna loggade in. Hon", anna.M("{name} logged in", map[string]interface{}{"name": "Anna"}); w != g {
+		t.Errorf(fs, w, g)
+	}
+	// only a string argument with the name of the selector is used
+	bo := i.WithAttributes(map[string]string{"gender": "male"})
+	if w, g := "Bo loggade in. Han", bo.M("{name} logged in", map[string]interface{}{"name": "Bo", "gender": 1}); w != g {
+		t.Errorf(fs, w, g)
+	}
+	// no other variant: the first variant in alphabetical order
+	if w, g := "Kim loggade in. Hon", i.M("{name} logged in", map[string]interface{}{"name": "Kim"}); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_Select_AddErrors(t *testing.T) {
+	tests := []struct {
+		keys []string
+	}{
+		{[]string{"Welcome[gender=female]", "Welcome[role=admin]"}},
+		{[]string{"Welcome", "Welcome[gender=female]"}},
+		{[]string{"Welcome[gender=female]", "Welcome"}},
+		{[]string{"%d users[one]", "%d users[gender=female]"}},
+		{[]string{"%d users[gender=female]", "%d users[one]"}},
+	}
+	for _, test := range tests {
+		i := newI18N("en")
+		var err error
+		for _, key := range test.keys {
+			if err = i.add(key, "x"); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("Expected error for keys %v", test.keys)
+		}
+	}
+}
+
+func Test_CrossValidate_SelectValues(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties":    "Welcome[gender=female]\tWelcome, she\nWelcome[gender=male]\tWelcome, he\nWelcome[gender=other]\tWelcome, they\nLogin\tLogin\n",
+		"sv.properties":    "Welcome[gender=female]\tVälkommen, hon\nWelcome[gender=other]\tVälkommen, hen\nLogin[gender=other]\tLogga in\n",
+		"sv-FI.properties": "Welcome[role=admin]\tVälkommen, admin\n",
+	})
+	defer os.RemoveAll(dir)
+
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	findings, err := db.CrossValidateFindings()
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		if f.Kind == FindingSelectValues {
+			got = append(got, f.Message)
+		}
+	}
+	exp := []string{
+		"mismatching select values; sv:gender=[female other] vs. sv-FI:role=[admin]\tWelcome",
+		"mismatching select values; en:- vs. sv:gender=[other]\tLogin",
+		"mismatching select values; en:gender=[female male other] vs. sv:gender=[female other]\tWelcome",
+	}
+	if w, g := exp, got; !reflect.DeepEqual(w, g) {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := SeverityError, findings[0].Severity; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+type testUserAttributes map[string]map[string]string
+
+func (ua testUserAttributes) UserAttributes(r *http.Request) (map[string]string, bool) {
+	attrs, ok := ua[r.Header.Get("X-User")]
+	return attrs, ok
+}
+
+func Test_LocaleMiddleware_Attributes(t *testing.T) {
+	db := newTestLocaleDB()
+	sv := db.data["sv"]
+	sv.add("Welcome[gender=female]", "Välkommen, hon")
+	sv.add("Welcome[gender=other]", "Välkommen, hen")
+	m := NewLocaleMiddleware(db)
+	m.Attributes = testUserAttributes{"anna": {"gender": "female"}}
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(db.GetI18NFromRequest(r).S("Welcome")))
+	}))
+	for _, test := range []struct {
+		user string
+		exp  string
+	}{
+		{"anna", "Välkommen, hon"},
+		{"bertil", "Välkommen, hen"},
+	} {
+		r := httptest.NewRequest("GET", "/?locale=sv", nil)
+		r.Header.Set("X-User", test.user)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w, g := test.exp, w.Body.String(); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package i18n
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/stts-se/weblib/util"
+)
+
+// LocaleStats is the translation coverage of a locale, compared to the default locale (see Stats)
+type LocaleStats struct {
+	Locale string `json:"locale"`
+	// Keys is the number of keys in the default locale (plural keys are counted once)
+	Keys int `json:"keys"`
+	// Translated is the number of keys translated for the locale (or its parent locales), not counting Identical
+	Translated int `json:"translated"`
+	// Identical is the number of keys translated with the same text as in the default locale (i.e., likely not translated)
+	Identical int `json:"identical"`
+	// Missing is the number of keys not translated for the locale
+	Missing int `json:"missing"`
+	// Coverage is the share of the keys that are translated (Translated/Keys), between 0 and 1. It is 1 if there are no keys.
+	Coverage float64 `json:"coverage"`
+	// Modified is the last modification time of the i18n files of the locale (zero if unknown, e.g. for files embedded in the binary)
+	Modified time.Time `json:"modified,omitzero"`
+}
+
+// translation returns the translation of key (or its "other" plural form or select variant) for the locale, or any of its parent locales (but not the default locale, unless this is the default locale). See isTranslated.
+func (i *I18N) translation(key string) (string, bool) {
+	for _, loc := range i.fallbackChain() {
+		if r, ok := loc.dict[key]; ok {
+			return r, true
+		}
+		if r, ok := loc.plurals[key][PluralOther]; ok {
+			return r, true
+		}
+		if _, r, ok := loc.selectVariant(key, nil); ok {
+			return r, true
+		}
+		if loc.defaultFallback {
+			break
+		}
+	}
+	return "", false
+}
+
+// Stats computes the translation coverage of each locale against the keys of the default locale, sorted by locale. Keys inherited from a parent locale (e.g. from sv for sv-FI) count as translated. The pseudo locale is not included.
+func (db *I18NDB) Stats() []LocaleStats {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	res := []LocaleStats{}
+	for _, locName := range sortedKeysString2I18N(db.data) {
+		if db.data[locName].pseudo {
+			continue
+		}
+		res = append(res, db.localeStats(locName))
+	}
+	return res
+}
+
+// LocaleStats computes the translation coverage of a single locale (see Stats)
+func (db *I18NDB) LocaleStats(locale string) (LocaleStats, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if _, ok := db.data[locale]; !ok {
+		return LocaleStats{}, false
+	}
+	return db.localeStats(locale), true
+}
+
+// localeStats computes the translation coverage of a locale. NB that it is not thread-safe, and should be called after locking.
+func (db *I18NDB) localeStats(locName string) LocaleStats {
+	loc := db.data[locName]
+	res := LocaleStats{Locale: locName}
+	if def, ok := db.data[db.DefaultLocale]; ok {
+		for _, key := range def.keys {
+			res.Keys++
+			src, _ := def.translation(key)
+			trans, ok := loc.translation(key)
+			switch {
+			case !ok:
+				res.Missing++
+			case trans == src && loc != def:
+				res.Identical++
+			default:
+				res.Translated++
+			}
+		}
+	}
+	res.Coverage = 1
+	if res.Keys > 0 {
+		res.Coverage = float64(res.Translated) / float64(res.Keys)
+	}
+	for f, st := range db.files {
+		if l, _ := i18nFileID(db.Dir, f); l == locName && st.modTime.After(res.Modified) {
+			res.Modified = st.modTime
+		}
+	}
+	return res
+}
+
+// StatsHandler serves the translation coverage of the locales as JSON (see Stats). The optional locale param limits the list to a single locale.
+func (db *I18NDB) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	res := db.Stats()
+	if locale := util.GetParam(r, "locale"); locale != "" {
+		st, ok := db.LocaleStats(locale)
+		if !ok {
+			http.Error(w, "no such locale: "+locale, http.StatusNotFound)
+			return
+		}
+		res = []LocaleStats{st}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		log.Printf("Couldn't write locale stats : %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Stats(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties":    "Login\tLogin\nLogout\tLogout\nOK\tOK\n%d files[one]\t%d file\n%d files[other]\t%d files\n",
+		"sv.properties":    "Login\tLogga in\nOK\tOK\n%d files[one]\t%d fil\n%d files[other]\t%d filer\nExtra\tExtra\n",
+		"sv-FI.properties": "Logout\tLogga ut\n",
+		"fi.properties":    "Login\tKirjaudu\n",
+	})
+	defer os.RemoveAll(dir)
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "sv.properties"), modified, modified); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	db := NewI18NDB(dir, "en")
+	db.PseudoLocale = "en-XA"
+	if err := db.Load(); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+
+	got := []string{}
+	for _, st := range db.Stats() {
+		got = append(got, fmt.Sprintf("%s:%d/%d/%d/%d:%.2f", st.Locale, st.Keys, st.Translated, st.Identical, st.Missing, st.Coverage))
+	}
+	exp := []string{
+		"en:4/4/0/0:1.00",
+		"fi:4/1/0/3:0.25",
+		"sv:4/2/1/1:0.50",
+		// Logout from sv-FI, and the others from sv
+		"sv-FI:4/3/1/0:0.75",
+	}
+	if w, g := strings.Join(exp, " "), strings.Join(got, " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	st, ok := db.LocaleStats("sv")
+	if !ok {
+		t.Fatalf("Expected stats for sv")
+	}
+	if w, g := modified, st.Modified; !w.Equal(g) {
+		t.Errorf(fs, w, g)
+	}
+	if _, ok := db.LocaleStats("de"); ok {
+		t.Errorf("Expected no stats for de")
+	}
+
+	r := httptest.NewRequest("GET", "/locale/stats?locale=sv", nil)
+	w := httptest.NewRecorder()
+	db.StatsHandler(w, r)
+	var res []LocaleStats
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 1, len(res); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := "sv 2 1", fmt.Sprintf("%s %d %d", res[0].Locale, res[0].Translated, res[0].Missing); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	w = httptest.NewRecorder()
+	db.StatsHandler(w, httptest.NewRequest("GET", "/locale/stats?locale=de", nil))
+	if w, g := 404, w.Code; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
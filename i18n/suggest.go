@@ -0,0 +1,212 @@
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Suggestion is a translated key similar to a key missing in a locale (see Suggest)
+type Suggestion struct {
+	// Key is the similar key, printed as "[context] key"
+	Key string
+	// Translation is the translation of the similar key in the locale (the "other" form or variant, for plural and select keys)
+	Translation string
+	// Score is the similarity of the keys, between 0 and 1 (see Similarity)
+	Score float64
+}
+
+// SuggestionReport lists suggestions for each key missing in a locale (see Suggest)
+type SuggestionReport struct {
+	Locale string
+	// Missing lists the missing keys, printed as "[context] key", in the order of the locales defining them
+	Missing []string
+	// Suggestions are the suggestions by missing key, best first. Keys without suggestions are not included.
+	Suggestions map[string][]Suggestion
+}
+
+// Suggest finds, for each key missing in a locale but defined in another locale, the most similar keys translated in the locale (a simple translation memory for translators). Keys are compared using Similarity; at most max suggestions (if max > 0) with a score of at least minScore are listed for each missing key. Keys are only compared to keys with the same message context. Regional locales (and locales with a fallback locale in the metadata) are not checked for missing keys, and neither is the pseudo locale.
+func (db *I18NDB) Suggest(minScore float64, max int) []SuggestionReport {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	locs := []string{}
+	for _, loc := range sortedKeysString2I18N(db.data) {
+		if !db.data[loc].pseudo {
+			locs = append(locs, loc)
+		}
+	}
+	allKeys := []string{}
+	seen := make(map[string]bool)
+	for _, loc := range db.withReferenceFirst(locs) {
+		for _, k := range db.data[loc].keys {
+			if !seen[k] {
+				allKeys = append(allKeys, k)
+				seen[k] = true
+			}
+		}
+	}
+
+	res := []SuggestionReport{}
+	for _, locName := range locs {
+		if _, regional := db.fallbackLocale(locName); regional {
+			continue
+		}
+		loc := db.data[locName]
+		rep := SuggestionReport{Locale: locName, Missing: []string{}, Suggestions: make(map[string][]Suggestion)}
+		for _, key := range allKeys {
+			if loc.hasKey(key) {
+				continue
+			}
+			rep.Missing = append(rep.Missing, displayKey(key))
+			ctx, text := splitContextKey(key)
+			sugs := []Suggestion{}
+			for _, cand := range loc.keys {
+				candCtx, candText := splitContextKey(cand)
+				if candCtx != ctx {
+					continue
+				}
+				score := Similarity(text, candText)
+				if score < minScore {
+					continue
+				}
+				trans, _ := loc.translation(cand)
+				sugs = append(sugs, Suggestion{Key: displayKey(cand), Translation: trans, Score: score})
+			}
+			sort.SliceStable(sugs, func(i, j int) bool { return sugs[i].Score > sugs[j].Score })
+			if max > 0 && len(sugs) > max {
+				sugs = sugs[:max]
+			}
+			if len(sugs) > 0 {
+				rep.Suggestions[displayKey(key)] = sugs
+			}
+		}
+		res = append(res, rep)
+	}
+	return res
+}
+
+// Similarity returns the similarity of two strings, between 0 (nothing in common) and 1 (equal, ignoring case): the mean of the normalized edit distance (Levenshtein) and the overlap of character trigrams (Dice coefficient). The edit distance favours strings of similar form, while the trigrams favour shared words in any order.
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	if string(ra) == string(rb) {
+		return 1
+	}
+	max := len(ra)
+	if len(rb) > max {
+		max = len(rb)
+	}
+	edit := 1 - float64(levenshtein(ra, rb))/float64(max)
+	return (edit + trigramDice(ra, rb)) / 2
+}
+
+// levenshtein returns the edit distance between a and b, i.e., the minimum number of rune insertions, deletions and substitutions
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(n int, ns ...int) int {
+	for _, m := range ns {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
+
+// trigrams returns the character trigrams of s (with a space added at start and end), counting duplicates. Punctuation is treated as space.
+func trigrams(s []rune) map[string]int {
+	norm := []rune{' '}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			r = ' '
+		}
+		norm = append(norm, r)
+	}
+	norm = append(norm, ' ')
+	res := make(map[string]int)
+	for i := 0; i+3 <= len(norm); i++ {
+		res[string(norm[i:i+3])]++
+	}
+	return res
+}
+
+// trigramDice returns the Dice coefficient of the trigrams of a and b
+func trigramDice(a, b []rune) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared, total := 0, 0
+	for t, n := range ta {
+		shared += minInt(n, tb[t])
+		total += n
+	}
+	for _, n := range tb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+// WriteSuggestions writes a suggestion report, one line per suggestion: the missing key, the score (two decimals), the similar key and its translation, separated by tabs. Missing keys without suggestions are written without score, key and translation.
+func WriteSuggestions(w io.Writer, rep SuggestionReport) error {
+	for _, key := range rep.Missing {
+		sugs := rep.Suggestions[key]
+		if len(sugs) == 0 {
+			if _, err := fmt.Fprintln(w, key); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, s := range sugs {
+			if _, err := fmt.Fprintf(w, "%s\t%.2f\t%s\t%s\n", key, s.Score, s.Key, s.Translation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteSuggestionFiles writes a suggestion file (<locale>_suggestions.txt, see WriteSuggestions) to dir for each locale with missing keys. The written files are returned.
+func WriteSuggestionFiles(dir string, reps []SuggestionReport) ([]string, error) {
+	res := []string{}
+	for _, rep := range reps {
+		if len(rep.Missing) == 0 {
+			continue
+		}
+		fName := filepath.Join(dir, rep.Locale+"_suggestions.txt")
+		fh, err := os.Create(fName)
+		if err != nil {
+			return res, fmt.Errorf("couldn't create suggestion file : %v", err)
+		}
+		if err := WriteSuggestions(fh, rep); err != nil {
+			fh.Close()
+			return res, fmt.Errorf("couldn't write suggestion file : %v", err)
+		}
+		if err := fh.Close(); err != nil {
+			return res, fmt.Errorf("couldn't close suggestion file : %v", err)
+		}
+		res = append(res, fName)
+	}
+	return res, nil
+}
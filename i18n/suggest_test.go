@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Similarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		exp  string
+	}{
+		{"Login", "login", "1.00"},
+		{"Log out", "Logout", "0.74"},
+		{"Save file", "Save files", "0.87"},
+		{"Delete user", "Delete the user", "0.79"},
+		{"Hello", "Goodbye", "0.00"},
+		{"", "Hello", "0.00"},
+	}
+	for _, test := range tests {
+		if w, g := test.exp, fmt.Sprintf("%.2f", Similarity(test.a, test.b)); w != g {
+			t.Errorf("%s vs. %s: "+fs, test.a, test.b, w, g)
+		}
+	}
+}
+
+func Test_Suggest(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"en.properties":    "Save file\tSave file\nDelete the user\tDelete the user\nSave files\tSave files\nHello\tHello\n\n[menu]\nOpen file\tOpen file\n",
+		"sv.properties":    "Save file\tSpara fil\nDelete the user\tTa bort användaren\nGoodbye\tHej då\n\n[menu]\nOpen\tÖppna\n",
+		"sv-FI.properties": "Save file\tSpara filen\n",
+	})
+	defer os.RemoveAll(dir)
+	db, err := ReadI18NPropDir(dir, "en")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	reps := db.Suggest(0.4, 1)
+	if w, g := 2, len(reps); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	if w, g := "en:Goodbye|[menu] Open sv:Save files|Hello|[menu] Open file", fmt.Sprintf("%s:%s %s:%s", reps[0].Locale, strings.Join(reps[0].Missing, "|"), reps[1].Locale, strings.Join(reps[1].Missing, "|")); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	var b bytes.Buffer
+	if err := WriteSuggestions(&b, reps[1]); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	exp := "Save files\t0.87\tSave file\tSpara fil\nHello\n[menu] Open file\t0.53\t[menu] Open\tÖppna\n"
+	if w, g := exp, b.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	out, err := ioutil.TempDir("", "i18n-suggest")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	defer os.RemoveAll(out)
+	files, err := WriteSuggestionFiles(out, reps)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := 2, len(files); w != g {
+		t.Fatalf(fs, w, g)
+	}
+	bts, err := ioutil.ReadFile(filepath.Join(out, "sv_suggestions.txt"))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if w, g := exp, string(bts); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
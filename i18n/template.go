@@ -0,0 +1,186 @@
+package i18n
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// htmlKeySuffix marks translations that are trusted HTML, e.g. "Welcome text[html]" (see I18N.HTML)
+const htmlKeySuffix = "[html]"
+
+// Sanitizer cleans up trusted HTML translations before they are used in templates, e.g. by removing script elements and event handler attributes (see BasicSanitizer)
+type Sanitizer func(html string) string
+
+// TemplateLocalizer is implemented by template data that carries an I18N instance (see FuncMap)
+type TemplateLocalizer interface {
+	I18N() *I18N
+}
+
+// FuncMap returns template functions for translating strings in (html or text) templates:
+//
+//	{{t . "Login"}}                       translation of a string (see I18N.S)
+//	{{tn . "%d users" .Count}}            plural form for a count (see I18N.N)
+//	{{thtml . "Welcome, %s" .User}}       trusted HTML translation (see I18N.HTML)
+//
+// The first argument is the template data (or context), used to find the locale: an *I18N, a TemplateLocalizer, an *http.Request (see GetI18NFromRequest) or a locale name. For other values, the default locale of db is used. Arguments are HTML escaped by html/template, and by thtml. Since HTML translations must be sanitized, thtml fails unless db has a Sanitizer.
+func FuncMap(db *I18NDB) template.FuncMap {
+	return template.FuncMap{
+		"t": func(ctx interface{}, s string, args ...interface{}) (string, error) {
+			i, err := templateI18N(db, ctx)
+			if err != nil {
+				return "", err
+			}
+			return i.S(s, args...), nil
+		},
+		"tn": func(ctx interface{}, s string, n int, args ...interface{}) (string, error) {
+			i, err := templateI18N(db, ctx)
+			if err != nil {
+				return "", err
+			}
+			return i.N(s, n, args...), nil
+		},
+		"thtml": func(ctx interface{}, s string, args ...interface{}) (template.HTML, error) {
+			i, err := templateI18N(db, ctx)
+			if err != nil {
+				return "", err
+			}
+			if db == nil || db.Sanitizer == nil {
+				return "", fmt.Errorf("thtml requires an HTML sanitizer (see I18NDB.Sanitizer)")
+			}
+			return i.HTML(db.Sanitizer, s, args...), nil
+		},
+	}
+}
+
+// templateI18N returns the I18N instance for the template data (see FuncMap)
+func templateI18N(db *I18NDB, ctx interface{}) (*I18N, error) {
+	switch c := ctx.(type) {
+	case *I18N:
+		if c != nil {
+			return c, nil
+		}
+	case TemplateLocalizer:
+		if i := c.I18N(); i != nil {
+			return i, nil
+		}
+	}
+	if db == nil {
+		return nil, fmt.Errorf("no locale found in template data of type %T", ctx)
+	}
+	switch c := ctx.(type) {
+	case *http.Request:
+		return db.GetI18NFromRequest(c), nil
+	case string:
+		return db.GetOrDefault(c), nil
+	}
+	return db.Default(), nil
+}
+
+// escapeArgs HTML escapes arguments (except numbers and booleans)
+func escapeArgs(args []interface{}) []interface{} {
+	res := make([]interface{}, len(args))
+	for i, a := range args {
+		switch a.(type) {
+		case bool:
+			res[i] = a
+		default:
+			if _, ok := toFloat(a); ok {
+				res[i] = a
+				continue
+			}
+			res[i] = template.HTMLEscapeString(fmt.Sprint(a))
+		}
+	}
+	return res
+}
+
+// HTML is used to look up the trusted HTML translation of the input string (s), defined using the key s[html] (e.g. "Welcome text[html]"). The translation is cleaned up by the sanitizer, and the arguments (args) are HTML escaped before they are filled in using fmt.Sprintf. If there is no HTML translation of s, the regular translation (see S) is HTML escaped.
+func (i *I18N) HTML(sanitize Sanitizer, s string, args ...interface{}) template.HTML {
+	key := s + htmlKeySuffix
+	res, ok := i.lookup(key)
+	if !ok {
+		return template.HTML(template.HTMLEscapeString(i.S(s, escapeArgs(args)...)))
+	}
+	i.checkMissing(key)
+	return template.HTML(sprintf(sanitize(res), escapeArgs(args)...))
+}
+
+// sanitizerTags are the elements kept by BasicSanitizer
+var sanitizerTags = map[string]bool{"a": true, "abbr": true, "b": true, "br": true, "code": true, "em": true, "i": true, "kbd": true, "mark": true, "small": true, "span": true, "strong": true, "sub": true, "sup": true, "u": true}
+
+// sanitizerDropContent are the elements removed by BasicSanitizer along with their contents
+var sanitizerDropContent = map[string]bool{"script": true, "style": true, "iframe": true, "object": true, "template": true}
+
+// sanitizerURLRE matches the URLs allowed in links: relative URLs, and http, https and mailto URLs
+var sanitizerURLRE = regexp.MustCompile(`^(?i:https?://|mailto:|[^:]*$)`)
+
+var tagRE = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^<>]*)?)/?>`)
+var attrRE = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+)`)
+
+// BasicSanitizer is a simple allowlist HTML sanitizer for translations. It keeps basic inline elements (such as b, em, code, span and a), with the title attribute, and href for links (only relative, http, https and mailto URLs). Other elements and attributes are removed (script and style elements along with their contents), all text is HTML escaped, and unclosed elements are closed.
+func BasicSanitizer(s string) string {
+	var b strings.Builder
+	open := []string{}
+	drop := ""
+	text := func(t string) {
+		if drop == "" {
+			b.WriteString(html.EscapeString(html.UnescapeString(t)))
+		}
+	}
+	last := 0
+	for _, m := range tagRE.FindAllStringSubmatchIndex(s, -1) {
+		text(s[last:m[0]])
+		last = m[1]
+		closing, name, attrs := s[m[2]:m[3]] == "/", strings.ToLower(s[m[4]:m[5]]), s[m[6]:m[7]]
+		switch {
+		case drop != "":
+			if closing && name == drop {
+				drop = ""
+			}
+		case sanitizerDropContent[name]:
+			if !closing {
+				drop = name
+			}
+		case !sanitizerTags[name]:
+		case closing:
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for _, t := range reverse(open[j:]) {
+						fmt.Fprintf(&b, "</%s>", t)
+					}
+					open = open[:j]
+					break
+				}
+			}
+		case name == "br":
+			b.WriteString("<br>")
+		default:
+			b.WriteString("<" + name)
+			for _, a := range attrRE.FindAllStringSubmatch(attrs, -1) {
+				attr, value := strings.ToLower(a[1]), html.UnescapeString(strings.Trim(a[2], `"'`))
+				if attr == "title" || (attr == "href" && name == "a" && sanitizerURLRE.MatchString(strings.TrimSpace(value))) {
+					fmt.Fprintf(&b, ` %s="%s"`, attr, html.EscapeString(value))
+				}
+			}
+			b.WriteString(">")
+			open = append(open, name)
+		}
+	}
+	text(s[last:])
+	for _, t := range reverse(open) {
+		fmt.Fprintf(&b, "</%s>", t)
+	}
+	return b.String()
+}
+
+func reverse(s []string) []string {
+	res := make([]string, len(s))
+	for i, v := range s {
+		res[len(s)-1-i] = v
+	}
+	return res
+}
@@ -0,0 +1,116 @@
+package i18n
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testTemplateData struct {
+	loc  *I18N
+	User string
+}
+
+func (d testTemplateData) I18N() *I18N {
+	return d.loc
+}
+
+func Test_FuncMap(t *testing.T) {
+	en, sv := newI18N("en"), newI18N("sv")
+	for k, v := range map[string]string{
+		"Login":                        "Logga in",
+		"Welcome, %s":                  "Välkommen, %s",
+		"%d users[one]":                "%d användare",
+		"%d users[other]":              "%d användare",
+		"Logged in as <b>%s</b>[html]": "Inloggad som <b onclick='alert(1)'>%s</b><script>alert(1)</script>",
+	} {
+		if err := sv.add(k, v); err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+	}
+	db := newI18NDB("", "en")
+	db.data = map[string]*I18N{"en": en, "sv": sv}
+	db.linkFallbacks()
+
+	tmpl := template.Must(template.New("test").Funcs(FuncMap(db)).Parse(`<a title="{{t . "Login"}}">{{t . "Welcome, %s" .User}}</a> {{tn . "%d users" 2}} {{thtml . "Logged in as <b>%s</b>" .User}}`))
+	for _, test := range []struct {
+		data interface{}
+		exp  string
+	}{
+		{testTemplateData{loc: sv, User: "<anna>"}, `<a title="Logga in">Välkommen, &lt;anna&gt;</a> 2 användare `},
+		{testTemplateData{loc: en, User: "<anna>"}, `<a title="Login">Welcome, &lt;anna&gt;</a> 2 users `},
+	} {
+		// thtml fails without sanitizer
+		var b strings.Builder
+		if err := tmpl.Execute(&b, test.data); err == nil {
+			t.Errorf("Expected error for missing sanitizer, got nil")
+		}
+		if w, g := test.exp, b.String(); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+
+	db.Sanitizer = BasicSanitizer
+	for _, test := range []struct {
+		data interface{}
+		exp  string
+	}{
+		{testTemplateData{loc: sv, User: "<anna>"}, `<a title="Logga in">Välkommen, &lt;anna&gt;</a> 2 användare Inloggad som <b>&lt;anna&gt;</b>`},
+		// no HTML translation: the key is escaped
+		{testTemplateData{loc: en, User: "anna"}, `<a title="Login">Welcome, anna</a> 2 users Logged in as &lt;b&gt;anna&lt;/b&gt;`},
+	} {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, test.data); err != nil {
+			t.Errorf("Unexpected error : %v", err)
+		}
+		if w, g := test.exp, b.String(); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+
+	// other template data
+	tmpl = template.Must(template.New("test").Funcs(FuncMap(db)).Parse(`{{t . "Login"}}`))
+	r := httptest.NewRequest("GET", "/?locale=sv", nil)
+	for _, test := range []struct {
+		data interface{}
+		exp  string
+	}{
+		{sv, "Logga in"},
+		{"sv", "Logga in"},
+		{r, "Logga in"},
+		{nil, "Login"},
+		{map[string]string{}, "Login"},
+	} {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, test.data); err != nil {
+			t.Errorf("Unexpected error : %v", err)
+		}
+		if w, g := test.exp, b.String(); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_BasicSanitizer(t *testing.T) {
+	for _, test := range []struct {
+		in, exp string
+	}{
+		{"plain & simple", "plain &amp; simple"},
+		{"already &amp; escaped", "already &amp; escaped"},
+		{"<b>bold</b> and <em>em</em><br/>", "<b>bold</b> and <em>em</em><br>"},
+		{"<B onclick='x()' title=\"t\">x</B>", `<b title="t">x</b>`},
+		{"<script>alert(1)</script>ok", "ok"},
+		{"<div>div</div>", "div"},
+		{"<a href=\"https://example.com/?a=1&amp;b=2\">link</a>", `<a href="https://example.com/?a=1&amp;b=2">link</a>`},
+		{"<a href=\"javascript:alert(1)\">link</a>", "<a>link</a>"},
+		{"<a href=\"&#106;avascript:alert(1)\">link</a>", "<a>link</a>"},
+		{"<a href=\"/doc/\">link", `<a href="/doc/">link</a>`},
+		{"<b><i>x</b>", "<b><i>x</i></b>"},
+		{"1 < 2 > 0", "1 &lt; 2 &gt; 0"},
+	} {
+		if w, g := test.exp, BasicSanitizer(test.in); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build ignore
+
+package src
+
+func handler(loc localizer, other localizer, data struct{ Loc localizer }, name string, n int) {
+	loc.S("Login")
+	loc.S(`Logged in as user %s`, name)
+	loc.N("%d users", n)
+	loc.M("Welcome, {name}!", nil)
+	loc.S(name)
+	loc.S("Login")
+	loc.SC("auth.button", "Login")
+	other.M("Not a key", nil)
+	data.Loc.S("Login")
+}
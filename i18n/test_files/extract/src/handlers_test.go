@@ -0,0 +1,7 @@
+//go:build ignore
+
+package src
+
+func testHandler(loc localizer) {
+	loc.S("Test key")
+}
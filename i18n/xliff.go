@@ -0,0 +1,224 @@
+package i18n
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	iofs "io/fs"
+)
+
+// xliffSourceLocale is the source language of XLIFF files written by WriteXLIFF. The keys are assumed to be English source strings.
+const xliffSourceLocale = "en"
+
+type xliffDoc struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+// xliffInput is used for reading XLIFF files
+type xliffInput struct {
+	Version string      `xml:"version,attr"`
+	TrgLang string      `xml:"trgLang,attr"`
+	Files   []xliffNode `xml:"file"`
+}
+
+type xliffFile struct {
+	ID       string         `xml:"id,attr"`
+	Metadata *xliffMetadata `xml:"urn:oasis:names:tc:xliff:metadata:2.0 metadata"`
+	Units    []xliffUnit    `xml:"unit"`
+}
+
+// xliffMetadataCategory is the category of the metaGroup used for the locale metadata (see LocaleMetadata)
+const xliffMetadataCategory = "locale"
+
+// xliffMetadata is a metadata module element (mda:metadata)
+type xliffMetadata struct {
+	Groups []xliffMetaGroup `xml:"metaGroup"`
+}
+
+type xliffMetaGroup struct {
+	Category string      `xml:"category,attr,omitempty"`
+	Meta     []xliffMeta `xml:"meta"`
+}
+
+type xliffMeta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xliffNode is a file, group or unit element, used for reading units in document order
+type xliffNode struct {
+	XMLName  xml.Name
+	Name     string         `xml:"name,attr"`
+	Metadata *xliffMetadata `xml:"urn:oasis:names:tc:xliff:metadata:2.0 metadata"`
+	Notes    *xliffNotes    `xml:"notes"`
+	Segments []xliffSegment `xml:"segment"`
+	Children []xliffNode    `xml:",any"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Name     string         `xml:"name,attr,omitempty"`
+	Notes    *xliffNotes    `xml:"notes"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffNotes struct {
+	Notes []xliffNote `xml:"note"`
+}
+
+type xliffNote struct {
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type xliffSegment struct {
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// ReadXLIFF reads an XLIFF 2.0 file. Each unit is a translation: the key is the name of the unit (if any), or else the source text. Plural forms use the same keys as property files (e.g. "%d users[one]"). Units with state initial are read as fuzzy, and units without target as untranslated. Notes are read as comments (developer notes as extracted comments), and the locale metadata from a metaGroup with category locale (metadata module) in the file element. Inline markup is not supported. The locale is taken from the trgLang attribute, or else from the file name.
+func ReadXLIFF(fName string) (*Catalog, error) {
+	return readXLIFF(nil, fName)
+}
+
+func readXLIFF(fsys iofs.FS, fName string) (*Catalog, error) {
+	locale := locNameFromFile(fName)
+	fh, err := openFS(fsys, fName)
+	if err != nil {
+		return &Catalog{Locale: locale}, err
+	}
+	defer fh.Close()
+
+	var doc xliffInput
+	if err := xml.NewDecoder(fh).Decode(&doc); err != nil {
+		return &Catalog{Locale: locale}, ParseErrors{&ParseError{File: fName, Msg: fmt.Sprintf("invalid XLIFF : %v", err)}}
+	}
+	if doc.Version != "2.0" {
+		return &Catalog{Locale: locale}, ParseErrors{&ParseError{File: fName, Msg: fmt.Sprintf("unsupported XLIFF version: %s", doc.Version)}}
+	}
+	if doc.TrgLang != "" {
+		locale = doc.TrgLang
+	}
+
+	units := []catalogUnit{}
+	var readUnits func([]xliffNode)
+	readUnits = func(nodes []xliffNode) {
+		for _, u := range nodes {
+			if u.XMLName.Local != "unit" {
+				readUnits(u.Children)
+				continue
+			}
+			e := &CatalogEntry{}
+			if u.Notes != nil {
+				for _, n := range u.Notes.Notes {
+					if n.Category == "developer" {
+						e.ExtractedComments = append(e.ExtractedComments, n.Text)
+					} else {
+						e.Comments = append(e.Comments, n.Text)
+					}
+				}
+			}
+			source, target := "", ""
+			for _, s := range u.Segments {
+				source += s.Source
+				if s.Target != nil {
+					target += *s.Target
+				}
+				if s.State == "initial" && s.Target != nil && !e.IsFuzzy() {
+					e.Flags = append(e.Flags, "fuzzy")
+				}
+			}
+			key := u.Name
+			if key == "" {
+				key = source
+			}
+			units = append(units, catalogUnit{key, target, e})
+		}
+	}
+	meta := LocaleMetadata{}
+	errs := ParseErrors{}
+	for _, f := range doc.Files {
+		if f.Metadata != nil {
+			for _, g := range f.Metadata.Groups {
+				if g.Category != xliffMetadataCategory {
+					continue
+				}
+				for _, m := range g.Meta {
+					if err := meta.set(m.Type, m.Value); err != nil {
+						errs = append(errs, &ParseError{File: fName, Msg: err.Error()})
+					}
+				}
+			}
+		}
+		readUnits(f.Children)
+	}
+	res := newCatalog(locale, units)
+	res.Metadata = meta
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// WriteXLIFF writes the catalog in XLIFF 2.0 format, with one unit for each translation (or plural form). Untranslated entries are included (without target), so that the file can be sent for translation. Fuzzy entries get state initial. The locale metadata is written using the metadata module. Message contexts are not supported.
+func (c *Catalog) WriteXLIFF(w io.Writer) error {
+	doc := xliffDoc{Version: "2.0", SrcLang: xliffSourceLocale, TrgLang: c.Locale}
+	file := xliffFile{ID: "f1"}
+	if fields := c.Metadata.fields(); len(fields) > 0 {
+		group := xliffMetaGroup{Category: xliffMetadataCategory}
+		for _, f := range fields {
+			group.Meta = append(group.Meta, xliffMeta{Type: f.key, Value: f.value})
+		}
+		file.Metadata = &xliffMetadata{Groups: []xliffMetaGroup{group}}
+	}
+	for i, u := range c.units(true) {
+		e := u.entry
+		if e.Context != "" {
+			return fmt.Errorf("message contexts are not supported in XLIFF files: %s", e.Key)
+		}
+		unit := xliffUnit{ID: fmt.Sprintf("u%d", i+1), Name: u.key}
+		notes := []xliffNote{}
+		for _, comment := range e.Comments {
+			notes = append(notes, xliffNote{Text: comment})
+		}
+		for _, comment := range e.ExtractedComments {
+			notes = append(notes, xliffNote{Category: "developer", Text: comment})
+		}
+		if len(notes) > 0 {
+			unit.Notes = &xliffNotes{Notes: notes}
+		}
+		seg := xliffSegment{Source: e.Key, State: "initial"}
+		if e.KeyPlural != "" && u.key != e.Key {
+			// plural forms other than one use the plural source string
+			if _, cat := splitPluralKey(u.key); cat != "one" {
+				seg.Source = e.KeyPlural
+			}
+		}
+		if u.value != "" {
+			value := u.value
+			seg.Target = &value
+			if !e.IsFuzzy() {
+				seg.State = "translated"
+			}
+		}
+		unit.Segments = []xliffSegment{seg}
+		file.Units = append(file.Units, unit)
+	}
+	doc.Files = []xliffFile{file}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,72 @@
+package userdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkAttribute checks that an attribute name and value can be saved to file
+func checkAttribute(name, value string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("attribute name cannot be empty")
+	}
+	if strings.ContainsAny(name+value, FieldSeparator+"\n") {
+		return fmt.Errorf("attribute names and values cannot contain tabs or newlines")
+	}
+	return nil
+}
+
+// setAttribute sets (or, if value is empty, removes) an attribute. NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) setAttribute(userName, name, value string) {
+	if value == "" {
+		delete(udb.attributes[userName], name)
+		if len(udb.attributes[userName]) == 0 {
+			delete(udb.attributes, userName)
+		}
+		return
+	}
+	if _, ok := udb.attributes[userName]; !ok {
+		udb.attributes[userName] = make(map[string]string)
+	}
+	udb.attributes[userName][name] = value
+}
+
+// SetAttribute sets a profile attribute of a user, such as gender (e.g. used to choose between select variants of translations, see the i18n package). An empty value removes the attribute.
+func (udb *UserDB) SetAttribute(userName, name, value string) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return fmt.Errorf("no such user: %s", userName)
+	}
+	if err := checkAttribute(name, value); err != nil {
+		return err
+	}
+	udb.setAttribute(userName, name, value)
+	if udb.fileName != "" {
+		udb.appendToFile(attributeLine(userName, name, value))
+	}
+	return nil
+}
+
+// Attributes returns the profile attributes of a user (see SetAttribute)
+func (udb *UserDB) Attributes(userName string) (map[string]string, error) {
+	udb.mutex.RLock()
+	defer udb.mutex.RUnlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return nil, fmt.Errorf("no such user: %s", userName)
+	}
+	res := make(map[string]string)
+	for name, value := range udb.attributes[userName] {
+		res[name] = value
+	}
+	return res, nil
+}
+
+// attributeLine returns the file line for an attribute
+func attributeLine(userName, name, value string) string {
+	return strings.Join([]string{"ATTR", userName, name, value}, FieldSeparator)
+}
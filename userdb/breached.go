@@ -0,0 +1,79 @@
+package userdb
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswords is an offline list of breached passwords, in the k-anonymity range format used by Have I Been Pwned: passwords are stored as upper case hex SHA-1 hashes, grouped by the first five characters of the hash (the prefix).
+//
+// The list is either a folder of range files, one per prefix (named <PREFIX> or <PREFIX>.txt), where each line has the format SUFFIX:COUNT; or a single file, sorted by hash, where each line has the format HASH:COUNT.
+type BreachedPasswords struct {
+	path  string
+	isDir bool
+}
+
+// NewBreachedPasswords creates a breached password list from a range folder or a single hash file (see BreachedPasswords)
+func NewBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read breached passwords : %v", err)
+	}
+	return &BreachedPasswords{path: path, isDir: info.IsDir()}, nil
+}
+
+func sha1Hex(password string) string {
+	return strings.ToUpper(fmt.Sprintf("%x", sha1.Sum([]byte(password))))
+}
+
+// Count returns the number of times the password has appeared in a breach, according to the list (zero if it's not in the list)
+func (bp *BreachedPasswords) Count(password string) (int, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+
+	fileName := bp.path
+	if bp.isDir {
+		fileName = filepath.Join(bp.path, prefix)
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			fileName = filepath.Join(bp.path, prefix+".txt")
+		}
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			return 0, nil
+		}
+	} else {
+		// in a single file, compare full hashes
+		suffix = hash
+	}
+
+	fh, err := os.Open(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		fs := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		key := strings.ToUpper(fs[0])
+		if key < suffix {
+			continue
+		}
+		if key > suffix {
+			break // sorted by hash
+		}
+		if len(fs) < 2 {
+			return 1, nil
+		}
+		count, err := strconv.Atoi(fs[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid line in %s : %s", fileName, scanner.Text())
+		}
+		return count, nil
+	}
+	return 0, scanner.Err()
+}
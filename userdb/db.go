@@ -0,0 +1,219 @@
+package userdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserDependent is implemented by stores that keep per-user records outside of the user and role databases (e.g. sessions or invitation tokens). A UserDependent added to a DB is kept in sync when users are deleted or renamed.
+type UserDependent interface {
+	// Name of the record type (used in reports)
+	Name() string
+
+	// ListUserRecords lists the records kept for the specified user
+	ListUserRecords(userName string) []string
+
+	// DeleteUserRecords removes all records kept for the specified user
+	DeleteUserRecords(userName string) error
+
+	// RenameUserRecords moves all records kept for oldName to newName
+	RenameUserRecords(oldName, newName string) error
+}
+
+// Report lists the records affected by a user operation on a DB
+type Report struct {
+	UserName    string
+	NewUserName string // set for renamed users
+	DryRun      bool
+	Roles       []string            // roles that the user is a member of
+	Records     map[string][]string // other per-user records, by record type (see UserDependent.Name)
+}
+
+func (r Report) String() string {
+	var lines []string
+	user := fmt.Sprintf("user %s", r.UserName)
+	prefix, recPrefix, rolePrep := "Removed", "Removed", "from"
+	if r.DryRun {
+		prefix, recPrefix = "Would remove", "Would remove"
+	}
+	if r.NewUserName != "" {
+		user = fmt.Sprintf("user %s to %s", r.UserName, r.NewUserName)
+		prefix, recPrefix, rolePrep = "Renamed", "Updated", "in"
+		if r.DryRun {
+			prefix, recPrefix = "Would rename", "Would update"
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%s %s", prefix, user))
+	for _, role := range r.Roles {
+		lines = append(lines, fmt.Sprintf("%s %s %s role %s", prefix, user, rolePrep, role))
+	}
+	names := []string{}
+	for name := range r.Records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, rec := range r.Records[name] {
+			lines = append(lines, fmt.Sprintf("%s %s: %s", recPrefix, name, rec))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DB coordinates a user database and a role database, so that changes to a user are propagated to the user's role memberships and to any other per-user records (see UserDependent)
+type DB struct {
+	mutex      *sync.Mutex
+	userDB     *UserDB
+	roleDB     *RoleDB
+	dependents []UserDependent
+}
+
+// NewDB creates a new DB from a user database and a role database. The databases are validated before use (see Validate).
+func NewDB(userDB *UserDB, roleDB *RoleDB) (*DB, error) {
+	res := &DB{
+		mutex:  &sync.Mutex{},
+		userDB: userDB,
+		roleDB: roleDB,
+	}
+	if err := Validate(userDB, roleDB); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// UserDB returns the user database
+func (db *DB) UserDB() *UserDB {
+	return db.userDB
+}
+
+// RoleDB returns the role database
+func (db *DB) RoleDB() *RoleDB {
+	return db.roleDB
+}
+
+// AddDependent registers a store of per-user records, to be updated when users are deleted or renamed
+func (db *DB) AddDependent(dep UserDependent) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.dependents = append(db.dependents, dep)
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (db *DB) report(userName string, dryRun bool) Report {
+	res := Report{
+		UserName: userName,
+		DryRun:   dryRun,
+		Roles:    db.roleDB.RolesForUser(userName),
+		Records:  make(map[string][]string),
+	}
+	for _, dep := range db.dependents {
+		if recs := dep.ListUserRecords(userName); len(recs) > 0 {
+			res.Records[dep.Name()] = append(res.Records[dep.Name()], recs...)
+		}
+	}
+	return res
+}
+
+// DeleteUser deletes a user from the user database, along with the user's role memberships and other per-user records. If dryRun is true, nothing is deleted, but the returned report lists what would have been removed.
+//
+// The deletion is all or nothing: if any step fails, the user and the user's role memberships are restored. Dependents are updated last, since their records can't be restored; if a dependent fails, records already removed by earlier dependents stay removed.
+func (db *DB) DeleteUser(userName string, dryRun bool) (Report, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if exists, _ := db.userDB.UserExists(userName); !exists {
+		return Report{UserName: userName, DryRun: dryRun}, fmt.Errorf("no such user: %s", userName)
+	}
+	res := db.report(userName, dryRun)
+	if dryRun {
+		return res, nil
+	}
+
+	roles, err := db.roleDB.DeleteUser(userName)
+	if err != nil {
+		err = fmt.Errorf("failed to delete roles for user %s : %v", userName, err)
+		return res, db.undo(err, func() error { return db.roleDB.restoreUser(userName, roles) })
+	}
+	rec, err := db.userDB.deleteUser(userName)
+	if err != nil {
+		return res, db.undo(err, func() error { return db.roleDB.restoreUser(userName, roles) })
+	}
+	for _, dep := range db.dependents {
+		if err := dep.DeleteUserRecords(userName); err != nil {
+			err = fmt.Errorf("failed to delete %s for user %s : %v", dep.Name(), userName, err)
+			return res, db.undo(err,
+				func() error { return db.userDB.restoreUser(rec) },
+				func() error { return db.roleDB.restoreUser(userName, roles) },
+			)
+		}
+	}
+	return res, nil
+}
+
+// undo runs the undo steps after a failed operation, and returns the error of the operation, along with any errors from the undo steps
+func (db *DB) undo(err error, steps ...func() error) error {
+	for _, step := range steps {
+		if undoErr := step(); undoErr != nil {
+			err = fmt.Errorf("%v (undo failed : %v)", err, undoErr)
+		}
+	}
+	return err
+}
+
+// RenameUser changes the user name of a user, keeping the password, and updates the user's role memberships and other per-user records accordingly. If dryRun is true, nothing is changed, but the returned report lists what would have been updated.
+//
+// The rename is all or nothing: if any step fails, the user, the user's role memberships and the records of the dependents already updated are renamed back.
+func (db *DB) RenameUser(oldName, newName string, dryRun bool) (Report, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	oldName = normaliseField(oldName)
+	newName = normaliseField(newName)
+
+	db.userDB.mutex.RLock()
+	err := db.userDB.checkRename(oldName, newName)
+	db.userDB.mutex.RUnlock()
+	if err != nil {
+		return Report{UserName: oldName, NewUserName: newName, DryRun: dryRun}, err
+	}
+	res := db.report(oldName, dryRun)
+	res.NewUserName = newName
+	if dryRun {
+		return res, nil
+	}
+
+	sessions, err := db.userDB.renameUser(oldName, newName, time.Now())
+	if err != nil {
+		return res, err
+	}
+	undoUser := func() error {
+		_, err := db.userDB.renameUser(newName, oldName, sessions)
+		return err
+	}
+	if _, err := db.roleDB.RenameUser(oldName, newName); err != nil {
+		err = fmt.Errorf("failed to rename user %s in roles : %v", oldName, err)
+		return res, db.undo(err, undoUser)
+	}
+	for i, dep := range db.dependents {
+		if err := dep.RenameUserRecords(oldName, newName); err != nil {
+			err = fmt.Errorf("failed to update %s for user %s : %v", dep.Name(), oldName, err)
+			steps := []func() error{}
+			for j := i - 1; j >= 0; j-- {
+				prev := db.dependents[j]
+				steps = append(steps, func() error { return prev.RenameUserRecords(newName, oldName) })
+			}
+			steps = append(steps,
+				func() error {
+					_, err := db.roleDB.RenameUser(newName, oldName)
+					return err
+				},
+				undoUser,
+			)
+			return res, db.undo(err, steps...)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,358 @@
+package userdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testDependent struct {
+	records map[string][]string
+}
+
+func (td *testDependent) Name() string { return "tokens" }
+
+func (td *testDependent) ListUserRecords(userName string) []string {
+	return td.records[userName]
+}
+
+func (td *testDependent) DeleteUserRecords(userName string) error {
+	delete(td.records, userName)
+	return nil
+}
+
+func (td *testDependent) RenameUserRecords(oldName, newName string) error {
+	td.records[newName] = td.records[oldName]
+	delete(td.records, oldName)
+	return nil
+}
+
+// failingDependent fails to update its records
+type failingDependent struct{}
+
+func (fd failingDependent) Name() string { return "keys" }
+
+func (fd failingDependent) ListUserRecords(userName string) []string { return nil }
+
+func (fd failingDependent) DeleteUserRecords(userName string) error {
+	return fmt.Errorf("failed")
+}
+
+func (fd failingDependent) RenameUserRecords(oldName, newName string) error {
+	return fmt.Errorf("failed")
+}
+
+func Test_DB_DeleteUser(t *testing.T) {
+	var err error
+
+	udb, err := EmptyUserDB("test_files/db_test_users")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	rdb, err := EmptyRoleDB("test_files/db_test_roles")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james"} {
+		err = udb.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+	err = rdb.InsertRole("admin", []string{"angela", "james"})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = rdb.InsertRole("user", []string{"james"})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	db, err := NewDB(udb, rdb)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	dep := &testDependent{records: map[string][]string{"james": {"token1"}}}
+	db.AddDependent(dep)
+
+	// dry run
+	report, err := db.DeleteUser("james", true)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if w, g := "[admin user]", fmt.Sprintf("%v", report.Roles); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "[token1]", fmt.Sprintf("%v", report.Records["tokens"]); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if exists, _ := udb.UserExists("james"); !exists {
+		t.Errorf("expected user james to remain after dry run")
+	}
+	if !rdb.Authorized("user", "james") {
+		t.Errorf("expected role user for james to remain after dry run")
+	}
+
+	// delete
+	_, err = db.DeleteUser("james", false)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if exists, _ := udb.UserExists("james"); exists {
+		t.Errorf("expected user james to be deleted")
+	}
+	if w, g := 0, len(rdb.RolesForUser("james")); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := 0, len(dep.records); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if !rdb.RoleExists("user") {
+		t.Errorf("expected empty role user to remain")
+	}
+
+	_, err = db.DeleteUser("james", false)
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	// re-read from file
+	udb2, err := ReadUserDB(udb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	rdb2, err := ReadRoleDB(rdb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = Validate(udb2, rdb2)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if w, g := true, rdb2.Authorized("admin", "angela"); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_DB_RenameUser(t *testing.T) {
+	var err error
+
+	udb, err := EmptyUserDB("test_files/db_test_rename_users")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	rdb, err := EmptyRoleDB("test_files/db_test_rename_roles")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james"} {
+		err = udb.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+	err = rdb.InsertRole("admin", []string{"angela", "james"})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	db, err := NewDB(udb, rdb)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	dep := &testDependent{records: map[string][]string{"james": {"token1"}}}
+	db.AddDependent(dep)
+
+	_, err = db.RenameUser("james", "angela", false)
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	_, err = db.RenameUser("jim", "jimmy", false)
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	report, err := db.RenameUser("james", "Jim", true)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if w, g := "Would rename user james to jim\nWould rename user james to jim in role admin\nWould update tokens: token1", report.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if exists, _ := udb.UserExists("jim"); exists {
+		t.Errorf("expected no user jim after dry run")
+	}
+
+	_, err = db.RenameUser("james", "Jim", false)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, db := range []*UserDB{udb, func() *UserDB { res, _ := ReadUserDB(udb.fileName); return res }()} {
+		ok, err := db.Authorized("jim", "jamess-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := true, ok; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if exists, _ := db.UserExists("james"); exists {
+			t.Errorf("expected no user james after rename")
+		}
+	}
+	rdb2, err := ReadRoleDB(rdb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, rdb := range []*RoleDB{rdb, rdb2} {
+		if w, g := "[angela jim]", fmt.Sprintf("%v", rdb.ListRolesAndUsers()["admin"]); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+	if w, g := "[token1]", fmt.Sprintf("%v", dep.records["jim"]); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_DB_DeleteUser_Undo(t *testing.T) {
+	var err error
+
+	udb, err := EmptyUserDB("test_files/db_test_undo_users")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	rdb, err := EmptyRoleDB("test_files/db_test_undo_roles")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james"} {
+		err = udb.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+	err = udb.DisableUser("james", "on leave", time.Time{})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = rdb.InsertRole("admin", []string{"angela", "james"})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	db, err := NewDB(udb, rdb)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	db.AddDependent(failingDependent{})
+
+	_, err = db.DeleteUser("james", false)
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	// the user and the user's roles are restored, in memory and on file
+	rdb2, err := ReadRoleDB(rdb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb2, err := ReadUserDB(udb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, rdb := range []*RoleDB{rdb, rdb2} {
+		if w, g := "[angela james]", fmt.Sprintf("%v", rdb.ListRolesAndUsers()["admin"]); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+	for _, udb := range []*UserDB{udb, udb2} {
+		ok, err := udb.Authorized("james", "jamess-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := true, ok; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if disabled, _ := udb.IsDisabled("james"); !disabled {
+			t.Errorf("expected user james to remain disabled")
+		}
+	}
+	err = Validate(udb2, rdb2)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+}
+
+func Test_DB_RenameUser_Undo(t *testing.T) {
+	var err error
+
+	udb, err := EmptyUserDB("test_files/db_test_rename_undo_users")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	rdb, err := EmptyRoleDB("test_files/db_test_rename_undo_roles")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james"} {
+		err = udb.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+	err = rdb.InsertRole("admin", []string{"angela", "james"})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	loggedIn := time.Now()
+
+	db, err := NewDB(udb, rdb)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	dep := &testDependent{records: map[string][]string{"james": {"token1"}}}
+	db.AddDependent(dep)
+	db.AddDependent(failingDependent{})
+
+	_, err = db.RenameUser("james", "jim", false)
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	// the user, the user's roles and the records of other dependents are renamed back, in memory and on file
+	rdb2, err := ReadRoleDB(rdb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb2, err := ReadUserDB(udb.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, rdb := range []*RoleDB{rdb, rdb2} {
+		if w, g := "[angela james]", fmt.Sprintf("%v", rdb.ListRolesAndUsers()["admin"]); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+	for _, udb := range []*UserDB{udb, udb2} {
+		ok, err := udb.Authorized("james", "jamess-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := true, ok; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if exists, _ := udb.UserExists("jim"); exists {
+			t.Errorf("expected no user jim after failed rename")
+		}
+		// sessions are still valid
+		if !udb.SessionValid("james", loggedIn) {
+			t.Errorf("expected sessions of james to remain valid")
+		}
+	}
+	if w, g := "[token1]", fmt.Sprintf("%v", dep.records["james"]); w != g {
+		t.Errorf(fs, w, g)
+	}
+	err = Validate(udb2, rdb2)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package userdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordHistory is the max number of previous password hashes kept per user
+const maxPasswordHistory = 24
+
+// ErrPasswordReused is matched (using errors.Is) by a PolicyError for a password that has been used before (see PasswordPolicy.HistorySize)
+var ErrPasswordReused = errors.New("password has been used before")
+
+// ViolationCode identifies a password policy rule
+type ViolationCode string
+
+// Password policy violation codes
+const (
+	ViolationTooShort    ViolationCode = "too_short"
+	ViolationTooLong     ViolationCode = "too_long"
+	ViolationCharClasses ViolationCode = "char_classes"
+	ViolationUserName    ViolationCode = "similar_to_username"
+	ViolationBreached    ViolationCode = "breached"
+	ViolationReused      ViolationCode = "reused"
+)
+
+// Violation of a password policy rule. Message is an English message, with fmt placeholders for Args, that can be used as an i18n key.
+type Violation struct {
+	Code    ViolationCode
+	Message string
+	Args    []interface{}
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf(v.Message, v.Args...)
+}
+
+// PolicyError is returned for passwords that violate the password policy
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := []string{}
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.String())
+	}
+	return fmt.Sprintf("password policy failed: %s", strings.Join(msgs, "; "))
+}
+
+// Is makes a PolicyError with a ViolationReused match ErrPasswordReused
+func (e *PolicyError) Is(target error) bool {
+	if target != ErrPasswordReused {
+		return false
+	}
+	for _, v := range e.Violations {
+		if v.Code == ViolationReused {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordPolicy defines rules for user passwords. It is evaluated by UserDB.InsertUser and UserDB.UpdatePassword.
+type PasswordPolicy struct {
+	// MinLength is the min number of characters in a password (zero means no minimum)
+	MinLength int
+
+	// MaxLength is the max number of characters in a password (zero means no limit)
+	MaxLength int
+
+	// MinCharClasses is the min number of character classes (lower case letters, upper case letters, digits, other characters) in a password
+	MinCharClasses int
+
+	// CheckUserName rejects passwords that contain the user name (or the local part of an email user name), or vice versa
+	CheckUserName bool
+
+	// Breached is an optional list of breached passwords, that will be rejected
+	Breached *BreachedPasswords
+
+	// MaxAge is the max age of a password, after which the password has to be changed on next login (zero means no expiry)
+	MaxAge time.Duration
+
+	// HistorySize is the number of most recent passwords (including the current one) that cannot be reused (zero means no check; max 24)
+	HistorySize int
+}
+
+func countCharClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+func similarToUserName(userName, password string) bool {
+	password = strings.ToLower(password)
+	for _, name := range []string{userName, strings.Split(userName, "@")[0]} {
+		if len(name) < 3 {
+			continue
+		}
+		if strings.Contains(password, name) || (len(password) >= 3 && strings.Contains(name, password)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates a password for the specified user according to the policy. Rules that need the user's previous passwords (HistorySize) are not checked.
+func (p PasswordPolicy) Check(userName, password string) []Violation {
+	res := []Violation{}
+	n := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && n < p.MinLength {
+		res = append(res, Violation{Code: ViolationTooShort, Message: "Password must have at least %d characters", Args: []interface{}{p.MinLength}})
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		res = append(res, Violation{Code: ViolationTooLong, Message: "Password can have at most %d characters", Args: []interface{}{p.MaxLength}})
+	}
+	if p.MinCharClasses > 0 && countCharClasses(password) < p.MinCharClasses {
+		res = append(res, Violation{Code: ViolationCharClasses, Message: "Password must contain at least %d of the following: lower case letters, upper case letters, digits, other characters", Args: []interface{}{p.MinCharClasses}})
+	}
+	if p.CheckUserName && similarToUserName(normaliseField(userName), password) {
+		res = append(res, Violation{Code: ViolationUserName, Message: "Password is too similar to the user name"})
+	}
+	if p.Breached != nil && n > 0 {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			log.Printf("Couldn't check breached passwords : %v", err)
+		}
+		if count > 0 {
+			res = append(res, Violation{Code: ViolationBreached, Message: "Password has appeared in a data breach"})
+		}
+	}
+	return res
+}
+
+// passwordInfo holds password metadata for a user
+type passwordInfo struct {
+	set        time.Time // time when the password was set (zero if unknown)
+	history    []string  // previous password hashes, most recent first
+	mustChange bool      // password has to be changed on next login
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) setPassword(userName, hash string, set time.Time) {
+	info := udb.passwords[userName]
+	if oldHash, exists := udb.users[userName]; exists {
+		info.history = append([]string{oldHash}, info.history...)
+		if len(info.history) > maxPasswordHistory {
+			info.history = info.history[:maxPasswordHistory]
+		}
+	}
+	info.set = set
+	info.mustChange = false
+	udb.users[userName] = hash
+	udb.passwords[userName] = info
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) checkPasswordHistory(userName, password string) error {
+	n := udb.PasswordPolicy.HistorySize
+	if n <= 0 {
+		return nil
+	}
+	hashes := append([]string{udb.users[userName]}, udb.passwords[userName].history...)
+	if len(hashes) > n {
+		hashes = hashes[:n]
+	}
+	for _, hash := range hashes {
+		match, err := comparePasswordAndHash(password, hash)
+		if err != nil {
+			return err
+		}
+		if match {
+			return &PolicyError{Violations: []Violation{{Code: ViolationReused, Message: "Password has been used before"}}}
+		}
+	}
+	return nil
+}
+
+// RequirePasswordChange forces the user to change password on next login
+func (udb *UserDB) RequirePasswordChange(userName string) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return fmt.Errorf("no such user: %s", userName)
+	}
+	info := udb.passwords[userName]
+	info.mustChange = true
+	udb.passwords[userName] = info
+	if udb.fileName != "" {
+		udb.appendToFile(fmt.Sprintf("%s%s%s", "EXPIRE", FieldSeparator, userName))
+	}
+	return nil
+}
+
+// PasswordChangeRequired checks if the user has to change password, either because a change has been required (see RequirePasswordChange), or because the password is older than PasswordPolicy.MaxAge. Passwords with an unknown age never expire. The second return value is the reason for the required change, if any.
+func (udb *UserDB) PasswordChangeRequired(userName string) (bool, string) {
+	udb.mutex.RLock()
+	defer udb.mutex.RUnlock()
+	userName = normaliseField(userName)
+
+	info, ok := udb.passwords[userName]
+	if !ok {
+		return false, ""
+	}
+	if info.mustChange {
+		return true, "password change required"
+	}
+	if maxAge := udb.PasswordPolicy.MaxAge; maxAge > 0 && !info.set.IsZero() && time.Since(info.set) > maxAge {
+		return true, "password expired"
+	}
+	return false, ""
+}
+
+// PasswordSetTime returns the time when the user's password was set. The zero time is returned if the time is unknown.
+func (udb *UserDB) PasswordSetTime(userName string) time.Time {
+	udb.mutex.RLock()
+	defer udb.mutex.RUnlock()
+	return udb.passwords[normaliseField(userName)].set
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) writePasswordInfo(write func(line string) error, userName string) error {
+	info := udb.passwords[userName]
+	if len(info.history) > 0 {
+		if err := write(fmt.Sprintf("%s%s%s%s%s", "HISTORY", FieldSeparator, userName, FieldSeparator, strings.Join(info.history, ItemSeparator))); err != nil {
+			return err
+		}
+	}
+	if info.mustChange {
+		if err := write(fmt.Sprintf("%s%s%s", "EXPIRE", FieldSeparator, userName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package userdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_PasswordPolicy_Check(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 16, MinCharClasses: 3, CheckUserName: true}
+
+	tests := []struct {
+		userName string
+		password string
+		codes    string
+	}{
+		{"angela", "Secret-123", "[]"},
+		{"angela", "", "[too_short char_classes]"},
+		{"angela", "Sec-1", "[too_short]"},
+		{"angela", "Secret-123456789012", "[too_long]"},
+		{"angela", "secretsecret", "[char_classes]"},
+		{"angela", "Angela-123", "[similar_to_username]"},
+		{"angela@example.com", "my-Angela-1", "[similar_to_username]"},
+	}
+	for _, test := range tests {
+		codes := []ViolationCode{}
+		for _, v := range policy.Check(test.userName, test.password) {
+			codes = append(codes, v.Code)
+		}
+		if w, g := test.codes, fmt.Sprintf("%v", codes); w != g {
+			t.Errorf("%s/%s: "+fs, test.userName, test.password, w, g)
+		}
+	}
+
+	v := policy.Check("angela", "Sec-1")[0]
+	if w, g := "Password must have at least 8 characters", v.String(); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// the zero value policy has no rules
+	for _, password := range []string{"", "a", "angela"} {
+		if w, g := 0, len(PasswordPolicy{}.Check("angela", password)); w != g {
+			t.Errorf("%s: "+fs, password, w, g)
+		}
+	}
+}
+
+func Test_PasswordPolicy_Breached(t *testing.T) {
+	var err error
+
+	// range folder
+	dir := filepath.Join("test_files", "breached_ranges")
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	hash := sha1Hex("password1")
+	err = os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("0000000000000000000000000000000000A:1\n"+hash[5:]+":2427158\n"), 0644)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	// single file
+	file := filepath.Join("test_files", "breached_hashes")
+	err = os.WriteFile(file, []byte(hash+":2427158\nFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n"), 0644)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	for _, path := range []string{dir, file} {
+		bp, err := NewBreachedPasswords(path)
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		count, err := bp.Count("password1")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := 2427158, count; w != g {
+			t.Errorf(fs, w, g)
+		}
+		count, err = bp.Count("correct horse battery staple")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := 0, count; w != g {
+			t.Errorf(fs, w, g)
+		}
+
+		udb := NewUserDB()
+		udb.PasswordPolicy = PasswordPolicy{Breached: bp}
+		err = udb.InsertUser("angela", "password1")
+		var perr *PolicyError
+		if !errors.As(err, &perr) {
+			t.Errorf("expected policy error, got %v", err)
+		} else if w, g := ViolationBreached, perr.Violations[0].Code; w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
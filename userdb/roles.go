@@ -2,6 +2,7 @@ package userdb
 
 import (
 	"fmt"
+	iofs "io/fs"
 	"os"
 	"sort"
 	"strings"
@@ -41,17 +42,31 @@ func EmptyRoleDB(fileName string) (*RoleDB, error) {
 
 // ReadRoleDB reads a role db from file
 func ReadRoleDB(fileName string) (*RoleDB, error) {
-	res := &RoleDB{
-		mutex:       &sync.RWMutex{},
-		fileName:    fileName,
-		roles:       make(map[string]map[string]bool),
-		Constraints: func(role string, users []string) (bool, string) { return true, "" },
-	}
-	if !util.FileExists(fileName) {
-		return res, nil
-	}
+	return readRoleDB(nil, fileName)
+}
+
+// ReadRoleDBFS reads a role db from a file in fsys (e.g. a default role db embedded in the binary). The db is not saved to file, i.e., changes are kept in memory only. If the file doesn't exist, an empty db is returned.
+func ReadRoleDBFS(fsys iofs.FS, fileName string) (*RoleDB, error) {
+	return readRoleDB(fsys, fileName)
+}
 
-	lines, err := util.ReadLines(fileName)
+// readRoleDB reads a role db from a file in fsys, or from the OS file system if fsys is nil
+func readRoleDB(fsys iofs.FS, fileName string) (*RoleDB, error) {
+	res := NewRoleDB()
+	var lines []string
+	var err error
+	if fsys == nil {
+		res.fileName = fileName
+		if !util.FileExists(fileName) {
+			return res, nil
+		}
+		lines, err = util.ReadLines(fileName)
+	} else {
+		if !util.FileExistsFS(fsys, fileName) {
+			return res, nil
+		}
+		lines, err = util.ReadLinesFS(fsys, fileName)
+	}
 	if err != nil {
 		return res, err
 	}
@@ -67,9 +82,14 @@ func ReadRoleDB(fileName string) (*RoleDB, error) {
 				return res, fmt.Errorf("no such role: %s", role)
 			}
 			delete(res.roles, role)
+		} else if fs[0] == "RENAMEUSER" {
+			if len(fs) != 3 {
+				return res, fmt.Errorf("invalid line: %s", l)
+			}
+			res.renameUser(normaliseField(fs[1]), normaliseField(fs[2]))
 		} else {
 			role := fs[0]
-			userNames := strings.Split(fs[1], ItemSeparator)
+			userNames := splitItems(fs[1])
 			userMap := make(map[string]bool)
 			for _, userName := range userNames {
 				userMap[userName] = true
@@ -210,6 +230,101 @@ func (rdb *RoleDB) DeleteUserRole(role, userName string) error {
 	return nil
 }
 
+// DeleteUser removes a user from all roles. The roles themselves are kept, even if they end up empty. Returns the roles from which the user was removed.
+func (rdb *RoleDB) DeleteUser(userName string) ([]string, error) {
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	res := rdb.rolesForUser(userName)
+	for _, role := range res {
+		delete(rdb.roles[role], userName)
+		if rdb.fileName != "" {
+			if err := rdb.appendToFile(rdb.roleLine(role)); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// restoreUser adds a user to the roles, e.g. to undo DeleteUser when a cascading deletion fails (see DB.DeleteUser)
+func (rdb *RoleDB) restoreUser(userName string, roles []string) error {
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+
+	for _, role := range roles {
+		if _, exists := rdb.roles[role]; !exists {
+			rdb.roles[role] = make(map[string]bool)
+		}
+		rdb.roles[role][userName] = true
+		if rdb.fileName != "" {
+			if err := rdb.appendToFile(rdb.roleLine(role)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// roleLine returns the file line for a role, with its users sorted. NB that it is not thread-safe, and should be called after locking.
+func (rdb *RoleDB) roleLine(role string) string {
+	userNames := []string{}
+	for userName := range rdb.roles[role] {
+		userNames = append(userNames, userName)
+	}
+	sort.Strings(userNames)
+	return fmt.Sprintf("%s%s%s", role, FieldSeparator, strings.Join(userNames, ItemSeparator))
+}
+
+// RenameUser changes the user name in all roles that the user is a member of. Returns the affected roles.
+func (rdb *RoleDB) RenameUser(oldName, newName string) ([]string, error) {
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+	oldName = normaliseField(oldName)
+	newName = normaliseField(newName)
+
+	if ok, msg := defaultConstraints("user", newName); !ok {
+		return []string{}, fmt.Errorf("constraints failed: %s", msg)
+	}
+	if rdb.fileName != "" && len(rdb.rolesForUser(oldName)) > 0 {
+		if err := rdb.appendToFile(fmt.Sprintf("%s%s%s%s%s", "RENAMEUSER", FieldSeparator, oldName, FieldSeparator, newName)); err != nil {
+			return []string{}, err
+		}
+	}
+	return rdb.renameUser(oldName, newName), nil
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (rdb *RoleDB) renameUser(oldName, newName string) []string {
+	res := rdb.rolesForUser(oldName)
+	for _, role := range res {
+		userMap := rdb.roles[role]
+		delete(userMap, oldName)
+		userMap[newName] = true
+	}
+	return res
+}
+
+// RolesForUser lists the roles that the specified user is a member of
+func (rdb *RoleDB) RolesForUser(userName string) []string {
+	rdb.mutex.RLock()
+	defer rdb.mutex.RUnlock()
+	return rdb.rolesForUser(normaliseField(userName))
+}
+
+// NB that it is not thread-safe, and should be called after locking.
+func (rdb *RoleDB) rolesForUser(userName string) []string {
+	res := []string{}
+	for role, userMap := range rdb.roles {
+		if userMap[userName] {
+			res = append(res, role)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
 // Authorized is used to check if a user has access to a specified role
 func (rdb *RoleDB) Authorized(role, userName string) bool {
 	rdb.mutex.RLock()
@@ -4,6 +4,7 @@ import (
 	"strings"
 	//"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stts-se/weblib/util"
 )
@@ -126,6 +127,23 @@ func Test_RoleDB_File(t *testing.T) {
 	}
 }
 
+func Test_RoleDB_FS(t *testing.T) {
+	fsys := fstest.MapFS{"roles.txt": {Data: []byte("user\tangela james\nadmin\tjames\nDELETE\tadmin\n")}}
+	rdb, err := ReadRoleDBFS(fsys, "roles.txt")
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	if w, g := "user", strings.Join(rdb.GetRoles(), " "); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := true, rdb.Authorized("user", "james"); w != g {
+		t.Errorf(fs, w, g)
+	}
+	if err := rdb.SaveFile(); err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+}
+
 func Test_RoleDB_Constraints(t *testing.T) {
 	var err error
 	rdb := NewRoleDB()
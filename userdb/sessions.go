@@ -0,0 +1,42 @@
+package userdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// sessionsLine returns the file line for the time from which sessions of a user are valid (see RevokeSessions)
+func sessionsLine(userName string, since time.Time) string {
+	return fmt.Sprintf("%s%s%s%s%s", "REVOKE", FieldSeparator, userName, FieldSeparator, formatTime(since))
+}
+
+// RevokeSessions invalidates all login sessions of the user created up until now (see SessionValid). Sessions are stored client side (e.g. in cookies), so they are invalidated by time rather than removed. The revocation is saved to file, so it survives a restart.
+func (udb *UserDB) RevokeSessions(userName string) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return fmt.Errorf("no such user: %s", userName)
+	}
+	now := time.Now()
+	if udb.fileName != "" {
+		if err := udb.appendToFile(sessionsLine(userName, now)); err != nil {
+			return fmt.Errorf("failed to revoke sessions for user %s : %v", userName, err)
+		}
+	}
+	udb.sessionsValidSince[userName] = now
+	return nil
+}
+
+// SessionValid checks if a login session for the user, created at the specified time, is still valid: the user must exist, and the session must be created after the user was created, renamed or had the sessions revoked (see RevokeSessions). This way, sessions of a deleted user can't be used for a new user with the same name.
+func (udb *UserDB) SessionValid(userName string, created time.Time) bool {
+	udb.mutex.RLock()
+	defer udb.mutex.RUnlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return false
+	}
+	return created.After(udb.sessionsValidSince[userName])
+}
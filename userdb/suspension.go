@@ -0,0 +1,50 @@
+package userdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Suspension holds information about a disabled user account
+type Suspension struct {
+	Reason string    // optional
+	Since  time.Time // time when the account was disabled
+	Until  time.Time // optional; if set, the account is automatically re-enabled at this time
+}
+
+// Active returns true if the suspension is in effect at the specified time
+func (s Suspension) Active(t time.Time) bool {
+	return s.Until.IsZero() || t.Before(s.Until)
+}
+
+func (s Suspension) String() string {
+	res := fmt.Sprintf("disabled since %s", s.Since.Format(time.RFC3339))
+	if !s.Until.IsZero() {
+		res = fmt.Sprintf("%s until %s", res, s.Until.Format(time.RFC3339))
+	}
+	if s.Reason != "" {
+		res = fmt.Sprintf("%s (%s)", res, s.Reason)
+	}
+	return res
+}
+
+// fields returns the suspension as file fields: since, until (or empty), reason (or empty)
+func (s Suspension) fields() string {
+	return strings.Join([]string{formatTime(s.Since), formatTime(s.Until), s.Reason}, FieldSeparator)
+}
+
+func parseSuspension(fields []string) (Suspension, error) {
+	var res Suspension
+	var err error
+	res.Since, err = time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return res, err
+	}
+	res.Until, err = parseTime(fields[1])
+	if err != nil {
+		return res, err
+	}
+	res.Reason = fields[2]
+	return res, nil
+}
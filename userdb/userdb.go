@@ -2,24 +2,35 @@ package userdb
 
 import (
 	"fmt"
+	iofs "io/fs"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/stts-se/weblib/util"
 )
 
 // UserDB a database of users
 type UserDB struct {
-	mutex    *sync.RWMutex
-	fileName string // optional
-	users    map[string]string
+	mutex     *sync.RWMutex
+	fileName  string // optional
+	users     map[string]string
+	passwords map[string]passwordInfo
+	suspended map[string]Suspension
+	// attributes are the profile attributes of the users: user -> name -> value (see SetAttribute)
+	attributes map[string]map[string]string
+	// sessionsValidSince is the time from which login sessions of the users are valid (see SessionValid)
+	sessionsValidSince map[string]time.Time
 
 	// Constraints is used to validate an input user + password
 	// returns true + empty string if the user is valid
 	// returns false + message if the user is invalid
 	Constraints func(user string, password string) (bool, string)
+
+	// PasswordPolicy is used to validate new passwords, and to check for expired passwords
+	PasswordPolicy PasswordPolicy
 }
 
 var prms = &params{
@@ -33,9 +44,13 @@ var prms = &params{
 // NewUserDB creates a new user database
 func NewUserDB() *UserDB {
 	return &UserDB{
-		mutex:       &sync.RWMutex{},
-		users:       make(map[string]string),
-		Constraints: func(user string, password string) (bool, string) { return true, "" },
+		mutex:              &sync.RWMutex{},
+		users:              make(map[string]string),
+		passwords:          make(map[string]passwordInfo),
+		suspended:          make(map[string]Suspension),
+		attributes:         make(map[string]map[string]string),
+		sessionsValidSince: make(map[string]time.Time),
+		Constraints:        func(user string, password string) (bool, string) { return true, "" },
 	}
 }
 
@@ -49,17 +64,31 @@ func EmptyUserDB(fileName string) (*UserDB, error) {
 
 // ReadUserDB reads a user db from file
 func ReadUserDB(fileName string) (*UserDB, error) {
-	res := &UserDB{
-		mutex:       &sync.RWMutex{},
-		fileName:    fileName,
-		users:       make(map[string]string),
-		Constraints: func(user string, password string) (bool, string) { return true, "" },
-	}
-	if !util.FileExists(fileName) {
-		return res, nil
-	}
+	return readUserDB(nil, fileName)
+}
 
-	lines, err := util.ReadLines(fileName)
+// ReadUserDBFS reads a user db from a file in fsys (e.g. a default user db embedded in the binary). The db is not saved to file, i.e., changes are kept in memory only. If the file doesn't exist, an empty db is returned.
+func ReadUserDBFS(fsys iofs.FS, fileName string) (*UserDB, error) {
+	return readUserDB(fsys, fileName)
+}
+
+// readUserDB reads a user db from a file in fsys, or from the OS file system if fsys is nil
+func readUserDB(fsys iofs.FS, fileName string) (*UserDB, error) {
+	res := NewUserDB()
+	var lines []string
+	var err error
+	if fsys == nil {
+		res.fileName = fileName
+		if !util.FileExists(fileName) {
+			return res, nil
+		}
+		lines, err = util.ReadLines(fileName)
+	} else {
+		if !util.FileExistsFS(fsys, fileName) {
+			return res, nil
+		}
+		lines, err = util.ReadLinesFS(fsys, fileName)
+	}
 	if err != nil {
 		return res, err
 	}
@@ -67,15 +96,110 @@ func ReadUserDB(fileName string) (*UserDB, error) {
 	res.mutex.Lock()
 	defer res.mutex.Unlock()
 
+	// checks the number of fields of an instruction line, and that the user (second field) exists
+	var checkFields = func(fs []string, n int) error {
+		if len(fs) != n {
+			return fmt.Errorf("invalid line: %s", strings.Join(fs, FieldSeparator))
+		}
+		if _, exists := res.users[normaliseField(fs[1])]; !exists {
+			return fmt.Errorf("no such user: %s", normaliseField(fs[1]))
+		}
+		return nil
+	}
+
 	for _, l := range lines {
 		fs := strings.Split(l, FieldSeparator)
-		if fs[0] == "DELETE" {
-			userName := normaliseField(fs[1])
-			if _, exists := res.users[userName]; !exists {
-				return res, fmt.Errorf("no such user: %s", userName)
+		switch fs[0] {
+		case "DELETE":
+			if err := checkFields(fs, 2); err != nil {
+				return res, err
 			}
+			userName := normaliseField(fs[1])
 			delete(res.users, userName)
-		} else {
+			delete(res.suspended, userName)
+			delete(res.passwords, userName)
+			delete(res.attributes, userName)
+			delete(res.sessionsValidSince, userName)
+		case "RENAME":
+			if len(fs) == 3 { // without timestamp
+				fs = append(fs, "")
+			}
+			if err := checkFields(fs, 4); err != nil {
+				return res, err
+			}
+			oldName := normaliseField(fs[1])
+			newName := normaliseField(fs[2])
+			if err := res.checkRename(oldName, newName); err != nil {
+				return res, err
+			}
+			renamed, err := parseTime(fs[3])
+			if err != nil {
+				return res, fmt.Errorf("invalid line: %s : %v", l, err)
+			}
+			res.rename(oldName, newName, renamed)
+		case "PASSWORD":
+			if len(fs) == 3 { // without timestamp
+				fs = append(fs, "")
+			}
+			if err := checkFields(fs, 4); err != nil {
+				return res, err
+			}
+			set, err := parseTime(fs[3])
+			if err != nil {
+				return res, fmt.Errorf("invalid line: %s : %v", l, err)
+			}
+			res.setPassword(normaliseField(fs[1]), fs[2], set)
+		case "HISTORY":
+			if err := checkFields(fs, 3); err != nil {
+				return res, err
+			}
+			userName := normaliseField(fs[1])
+			info := res.passwords[userName]
+			info.history = splitItems(fs[2])
+			res.passwords[userName] = info
+		case "EXPIRE":
+			if err := checkFields(fs, 2); err != nil {
+				return res, err
+			}
+			userName := normaliseField(fs[1])
+			info := res.passwords[userName]
+			info.mustChange = true
+			res.passwords[userName] = info
+		case "DISABLE":
+			if err := checkFields(fs, 5); err != nil {
+				return res, err
+			}
+			susp, err := parseSuspension(fs[2:])
+			if err != nil {
+				return res, fmt.Errorf("invalid line: %s : %v", l, err)
+			}
+			res.suspended[normaliseField(fs[1])] = susp
+		case "ENABLE":
+			if err := checkFields(fs, 2); err != nil {
+				return res, err
+			}
+			delete(res.suspended, normaliseField(fs[1]))
+		case "ATTR":
+			if err := checkFields(fs, 4); err != nil {
+				return res, err
+			}
+			res.setAttribute(normaliseField(fs[1]), fs[2], fs[3])
+		case "REVOKE":
+			if err := checkFields(fs, 3); err != nil {
+				return res, err
+			}
+			since, err := parseTime(fs[2])
+			if err != nil {
+				return res, fmt.Errorf("invalid line: %s : %v", l, err)
+			}
+			res.sessionsValidSince[normaliseField(fs[1])] = since
+		default:
+			if len(fs) == 2 { // without timestamp
+				fs = append(fs, "")
+			}
+			if len(fs) != 3 {
+				return res, fmt.Errorf("invalid line: %s", l)
+			}
 			userName := normaliseField(fs[0])
 			password := fs[1]
 			if _, exists := res.users[userName]; exists {
@@ -85,7 +209,13 @@ func ReadUserDB(fileName string) (*UserDB, error) {
 			if ok, msg := res.CheckConstraints(userName, password); !ok {
 				return res, fmt.Errorf("constraints failed: %s", msg)
 			}
+			set, err := parseTime(fs[2])
+			if err != nil {
+				return res, fmt.Errorf("invalid line: %s : %v", l, err)
+			}
 			res.users[userName] = password
+			res.passwords[userName] = passwordInfo{set: set}
+			res.sessionsValidSince[userName] = set
 		}
 	}
 	return res, nil
@@ -139,6 +269,9 @@ func (udb *UserDB) InsertUser(userName, password string) error {
 	if ok, msg := udb.CheckConstraints(userName, password); !ok {
 		return fmt.Errorf("constraints failed: %s", msg)
 	}
+	if violations := udb.PasswordPolicy.Check(userName, password); len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
 
 	passwordHash, err := generateFromPassword(password, prms)
 	if err != nil {
@@ -149,25 +282,141 @@ func (udb *UserDB) InsertUser(userName, password string) error {
 		return fmt.Errorf("user already exists: %s", userName)
 	}
 
+	now := time.Now()
 	udb.users[userName] = passwordHash
+	udb.passwords[userName] = passwordInfo{set: now}
+	udb.sessionsValidSince[userName] = now
 	if udb.fileName != "" {
-		udb.appendToFile(fmt.Sprintf("%s%s%s", userName, FieldSeparator, passwordHash))
+		udb.appendToFile(fmt.Sprintf("%s%s%s%s%s", userName, FieldSeparator, passwordHash, FieldSeparator, formatTime(now)))
 	}
 	return nil
 }
 
 // DeleteUser is used to delete a user from the database
 func (udb *UserDB) DeleteUser(userName string) error {
+	_, err := udb.deleteUser(userName)
+	return err
+}
+
+// userRecord holds everything stored for a user, so that a deleted user can be restored (see restoreUser)
+type userRecord struct {
+	userName   string
+	hash       string
+	password   passwordInfo
+	suspension *Suspension
+	attributes map[string]string
+	sessions   time.Time
+}
+
+// deleteUser deletes a user, and returns the deleted user record. If the deletion can't be saved to file, nothing is deleted.
+func (udb *UserDB) deleteUser(userName string) (userRecord, error) {
 	udb.mutex.Lock()
 	defer udb.mutex.Unlock()
 	userName = normaliseField(userName)
 
-	if _, exists := udb.users[userName]; !exists {
-		return fmt.Errorf("no such user: %s", userName)
+	hash, exists := udb.users[userName]
+	if !exists {
+		return userRecord{}, fmt.Errorf("no such user: %s", userName)
+	}
+	if udb.fileName != "" {
+		if err := udb.appendToFile(fmt.Sprintf("%s%s%s", "DELETE", FieldSeparator, userName)); err != nil {
+			return userRecord{}, fmt.Errorf("failed to delete user %s : %v", userName, err)
+		}
+	}
+	rec := userRecord{userName: userName, hash: hash, password: udb.passwords[userName], attributes: udb.attributes[userName], sessions: udb.sessionsValidSince[userName]}
+	if susp, ok := udb.suspended[userName]; ok {
+		rec.suspension = &susp
 	}
 	delete(udb.users, userName)
+	delete(udb.passwords, userName)
+	delete(udb.suspended, userName)
+	delete(udb.attributes, userName)
+	delete(udb.sessionsValidSince, userName)
+	return rec, nil
+}
+
+// restoreUser restores a deleted user (see deleteUser), e.g. when a cascading deletion fails
+func (udb *UserDB) restoreUser(rec userRecord) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+
+	if _, exists := udb.users[rec.userName]; exists {
+		return fmt.Errorf("user already exists: %s", rec.userName)
+	}
+	udb.users[rec.userName] = rec.hash
+	udb.passwords[rec.userName] = rec.password
+	if rec.suspension != nil {
+		udb.suspended[rec.userName] = *rec.suspension
+	}
+	if len(rec.attributes) > 0 {
+		udb.attributes[rec.userName] = rec.attributes
+	}
+	udb.sessionsValidSince[rec.userName] = rec.sessions
 	if udb.fileName != "" {
-		udb.appendToFile(fmt.Sprintf("%s%s%s", "DELETE", FieldSeparator, userName))
+		if err := udb.writeUser(udb.appendToFile, rec.userName); err != nil {
+			return fmt.Errorf("failed to restore user %s : %v", rec.userName, err)
+		}
+	}
+	return nil
+}
+
+// RenameUser changes the user name of an existing user, keeping the password. Login sessions created before the rename are invalidated.
+func (udb *UserDB) RenameUser(oldName, newName string) error {
+	_, err := udb.renameUser(oldName, newName, time.Now())
+	return err
+}
+
+// renameUser renames a user, with sessions valid from the time renamed (see rename), and returns the time from which sessions of oldName were valid, so that the rename can be undone. If the rename can't be saved to file, nothing is renamed.
+func (udb *UserDB) renameUser(oldName, newName string, renamed time.Time) (time.Time, error) {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	oldName = normaliseField(oldName)
+	newName = normaliseField(newName)
+
+	if err := udb.checkRename(oldName, newName); err != nil {
+		return time.Time{}, err
+	}
+	if udb.fileName != "" {
+		if err := udb.appendToFile(strings.Join([]string{"RENAME", oldName, newName, formatTime(renamed)}, FieldSeparator)); err != nil {
+			return time.Time{}, fmt.Errorf("failed to rename user %s : %v", oldName, err)
+		}
+	}
+	prev := udb.sessionsValidSince[oldName]
+	udb.rename(oldName, newName, renamed)
+	return prev, nil
+}
+
+// rename moves the user's records to newName. Sessions of newName are valid from the time renamed, if set (older files have no time for renames). NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) rename(oldName, newName string, renamed time.Time) {
+	udb.users[newName] = udb.users[oldName]
+	delete(udb.users, oldName)
+	udb.passwords[newName] = udb.passwords[oldName]
+	delete(udb.passwords, oldName)
+	if susp, ok := udb.suspended[oldName]; ok {
+		udb.suspended[newName] = susp
+		delete(udb.suspended, oldName)
+	}
+	if attrs, ok := udb.attributes[oldName]; ok {
+		udb.attributes[newName] = attrs
+		delete(udb.attributes, oldName)
+	}
+	udb.sessionsValidSince[newName] = udb.sessionsValidSince[oldName]
+	if !renamed.IsZero() {
+		udb.sessionsValidSince[newName] = renamed
+	}
+	delete(udb.sessionsValidSince, oldName)
+}
+
+// checkRename checks that oldName exists, and that newName is a valid user name that is not in use. Only the user name is validated, since the password is not known (only its hash). NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) checkRename(oldName, newName string) error {
+	if _, exists := udb.users[oldName]; !exists {
+		return fmt.Errorf("no such user: %s", oldName)
+	}
+	if _, exists := udb.users[newName]; exists {
+		return fmt.Errorf("user already exists: %s", newName)
+	}
+	if ok, msg := defaultConstraints("user", newName); !ok {
+		return fmt.Errorf("constraints failed: %s", msg)
 	}
 	return nil
 }
@@ -185,19 +434,77 @@ func (udb *UserDB) UpdatePassword(userName string, password string) error {
 	if _, exists := udb.users[userName]; !exists {
 		return fmt.Errorf("no such user: %s", userName)
 	}
+	if violations := udb.PasswordPolicy.Check(userName, password); len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	if err := udb.checkPasswordHistory(userName, password); err != nil {
+		return err
+	}
 	passwordHash, err := generateFromPassword(password, prms)
 	if err != nil {
 		return fmt.Errorf("failed to get user '%s' from user db : %v", userName, err)
 	}
 
-	udb.users[userName] = passwordHash
+	now := time.Now()
+	udb.setPassword(userName, passwordHash, now)
+	if udb.fileName != "" {
+		udb.appendToFile(fmt.Sprintf("%s%s%s%s%s%s%s", "PASSWORD", FieldSeparator, userName, FieldSeparator, passwordHash, FieldSeparator, formatTime(now)))
+	}
+	return nil
+}
+
+// DisableUser disables (suspends) a user account, without deleting it. The reason is optional. If until is non-zero, the account is automatically re-enabled at that time.
+func (udb *UserDB) DisableUser(userName, reason string, until time.Time) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return fmt.Errorf("no such user: %s", userName)
+	}
+	if strings.Contains(reason, FieldSeparator) || strings.Contains(reason, "\n") {
+		return fmt.Errorf("reason cannot contain tabs or newlines")
+	}
+	susp := Suspension{Reason: reason, Since: time.Now(), Until: until}
+	udb.suspended[userName] = susp
+	if udb.fileName != "" {
+		udb.appendToFile(fmt.Sprintf("%s%s%s%s%s", "DISABLE", FieldSeparator, userName, FieldSeparator, susp.fields()))
+	}
+	return nil
+}
+
+// EnableUser re-enables a disabled user account
+func (udb *UserDB) EnableUser(userName string) error {
+	udb.mutex.Lock()
+	defer udb.mutex.Unlock()
+	userName = normaliseField(userName)
+
+	if _, exists := udb.users[userName]; !exists {
+		return fmt.Errorf("no such user: %s", userName)
+	}
+	if _, disabled := udb.suspended[userName]; !disabled {
+		return fmt.Errorf("user is not disabled: %s", userName)
+	}
+	delete(udb.suspended, userName)
 	if udb.fileName != "" {
-		udb.appendToFile(fmt.Sprintf("%s%s%s", "DELETE", FieldSeparator, userName))
-		udb.appendToFile(fmt.Sprintf("%s%s%s", userName, FieldSeparator, passwordHash))
+		udb.appendToFile(fmt.Sprintf("%s%s%s", "ENABLE", FieldSeparator, userName))
 	}
 	return nil
 }
 
+// IsDisabled checks if a user account is currently disabled. If it is, the second return value holds the suspension details.
+func (udb *UserDB) IsDisabled(userName string) (bool, Suspension) {
+	udb.mutex.RLock()
+	defer udb.mutex.RUnlock()
+	userName = normaliseField(userName)
+
+	susp, ok := udb.suspended[userName]
+	if !ok || !susp.Active(time.Now()) {
+		return false, Suspension{}
+	}
+	return true, susp
+}
+
 // Authorized is used to check if the password matches the specified user name
 func (udb *UserDB) Authorized(userName, password string) (bool, error) {
 
@@ -205,14 +512,12 @@ func (udb *UserDB) Authorized(userName, password string) (bool, error) {
 	defer udb.mutex.RUnlock()
 	userName = normaliseField(userName)
 
-	ok := false
-
-	hash, err := udb.GetPasswordHash(userName)
-	if err != nil {
-		return ok, fmt.Errorf("failed to get user '%s' from user db : %v", userName, err)
+	hash, exists := udb.users[userName]
+	if !exists {
+		return false, fmt.Errorf("failed to get user '%s' from user db : no such user: %s", userName, userName)
 	}
 
-	ok, err = comparePasswordAndHash(password, hash)
+	ok, err := comparePasswordAndHash(password, hash)
 	if err != nil {
 		return ok, err
 	}
@@ -246,8 +551,40 @@ func (udb *UserDB) SaveFile() error {
 	}
 	defer fh.Close()
 
-	for userName, hash := range udb.users {
-		fmt.Fprintf(fh, "%s%s%s\n", userName, FieldSeparator, hash)
+	var write = func(line string) error {
+		_, err := fmt.Fprintf(fh, "%s\n", line)
+		return err
+	}
+	for userName := range udb.users {
+		if err := udb.writeUser(write, userName); err != nil {
+			return fmt.Errorf("failed to write file : %v", err)
+		}
+	}
+	return nil
+}
+
+// writeUser writes the file lines for a user: the user line, followed by password info, attributes, session validity and suspension. NB that it is not thread-safe, and should be called after locking.
+func (udb *UserDB) writeUser(write func(line string) error, userName string) error {
+	if err := write(fmt.Sprintf("%s%s%s%s%s", userName, FieldSeparator, udb.users[userName], FieldSeparator, formatTime(udb.passwords[userName].set))); err != nil {
+		return err
+	}
+	if err := udb.writePasswordInfo(write, userName); err != nil {
+		return err
+	}
+	for name, value := range udb.attributes[userName] {
+		if err := write(attributeLine(userName, name, value)); err != nil {
+			return err
+		}
+	}
+	if since, ok := udb.sessionsValidSince[userName]; ok && !since.IsZero() {
+		if err := write(sessionsLine(userName, since)); err != nil {
+			return err
+		}
+	}
+	if susp, ok := udb.suspended[userName]; ok && susp.Active(time.Now()) {
+		if err := write(fmt.Sprintf("%s%s%s%s%s", "DISABLE", FieldSeparator, userName, FieldSeparator, susp.fields())); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -1,8 +1,12 @@
 package userdb
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stts-se/weblib/util"
 )
@@ -145,6 +149,42 @@ func Test_UserDB_File(t *testing.T) {
 	//lines, err := util.ReadLines(udb1.fileName)
 }
 
+func Test_UserDB_FS(t *testing.T) {
+	hash, err := generateFromPassword("angelas-secret", prms)
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	fsys := fstest.MapFS{"users.txt": {Data: []byte("angela" + FieldSeparator + hash + "\n")}}
+
+	udb, err := ReadUserDBFS(fsys, "users.txt")
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	ok, err := udb.Authorized("angela", "angelas-secret")
+	if err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+	if w, g := true, ok; w != g {
+		t.Errorf(fs, w, g)
+	}
+	// changes are kept in memory only
+	if err := udb.InsertUser("james", "jamess-secret"); err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if err := udb.SaveFile(); err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	// missing file
+	udb, err = ReadUserDBFS(fsys, "missing.txt")
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	if w, g := 0, len(udb.GetUsers()); w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
 func Test_UserDB_Constraints(t *testing.T) {
 	var err error
 	udb := NewUserDB()
@@ -188,3 +228,394 @@ func Test_UserDB_Constraints(t *testing.T) {
 	}
 
 }
+
+func Test_UserDB_RenameConstraints(t *testing.T) {
+	var err error
+	udb := NewUserDB()
+
+	// password constraints don't apply to renames, since only the password hash is known
+	udb.Constraints = func(userName, password string) (bool, string) {
+		if len(password) > 20 {
+			return false, "password too long"
+		}
+		return true, ""
+	}
+	err = udb.InsertUser("angela", "angelas-secret")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb.RenameUser("angela", "angie")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb.RenameUser("angie", "")
+	if err == nil {
+		t.Errorf("Fail: expected error")
+	}
+	err = udb.RenameUser("angie", "tab\tseparated")
+	if err == nil {
+		t.Errorf("Fail: expected error")
+	}
+}
+
+func Test_UserDB_Disable(t *testing.T) {
+	var err error
+	udb1, err := EmptyUserDB("test_files/userdb_test_disable")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james", "carole"} {
+		err = udb1.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+
+	err = udb1.DisableUser("angela", "left the project", time.Time{})
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.DisableUser("james", "", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.DisableUser("carole", "incident", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.DisableUser("nobody", "", time.Time{})
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	err = udb1.DisableUser("carole", "tab\tseparated", time.Time{})
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	err = udb1.UpdatePassword("carole", "new-secret")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	udb2, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, udb := range []*UserDB{udb1, udb2} {
+		disabled, susp := udb.IsDisabled("angela")
+		if w, g := true, disabled; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := "left the project", susp.Reason; w != g {
+			t.Errorf(fs, w, g)
+		}
+		// auto-reactivated
+		disabled, _ = udb.IsDisabled("james")
+		if w, g := false, disabled; w != g {
+			t.Errorf(fs, w, g)
+		}
+		disabled, _ = udb.IsDisabled("carole")
+		if w, g := true, disabled; w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+
+	err = udb2.EnableUser("angela")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if disabled, _ := udb2.IsDisabled("angela"); disabled {
+		t.Errorf("expected user angela to be enabled")
+	}
+	udb3, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if disabled, _ := udb3.IsDisabled("angela"); disabled {
+		t.Errorf("expected user angela to be enabled")
+	}
+	ok, err := udb3.Authorized("carole", "new-secret")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if w, g := true, ok; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
+
+func Test_UserDB_Attributes(t *testing.T) {
+	var err error
+	udb1, err := EmptyUserDB("test_files/userdb_test_attributes")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james", "carole"} {
+		err = udb1.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+
+	err = udb1.SetAttribute("angela", "gender", "female")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.SetAttribute("james", "gender", "male")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.SetAttribute("james", "title", "Dr")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	// an empty value removes the attribute
+	err = udb1.SetAttribute("james", "title", "")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.SetAttribute("carole", "gender", "female")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.RenameUser("carole", "caroline")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.SetAttribute("nobody", "gender", "female")
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	err = udb1.SetAttribute("angela", "gender", "tab\tseparated")
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	err = udb1.SetAttribute("angela", "", "female")
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+
+	udb2, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, udb := range []*UserDB{udb1, udb2} {
+		for _, test := range []struct {
+			user string
+			exp  map[string]string
+		}{
+			{"angela", map[string]string{"gender": "female"}},
+			{"james", map[string]string{"gender": "male"}},
+			{"caroline", map[string]string{"gender": "female"}},
+		} {
+			attrs, err := udb.Attributes(test.user)
+			if err != nil {
+				t.Errorf("Fail: %v", err)
+			}
+			if w, g := test.exp, attrs; !reflect.DeepEqual(w, g) {
+				t.Errorf(fs, w, g)
+			}
+		}
+		_, err = udb.Attributes("carole")
+		if err == nil {
+			t.Errorf("Fail: expected error here")
+		}
+	}
+
+	err = udb2.DeleteUser("angela")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb2.InsertUser("angela", "angelas-new-secret")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb2.SaveFile()
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb3, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, test := range []struct {
+		user string
+		exp  int
+	}{
+		{"angela", 0},
+		{"james", 1},
+		{"caroline", 1},
+	} {
+		attrs, err := udb3.Attributes(test.user)
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+		if w, g := test.exp, len(attrs); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+}
+
+func Test_UserDB_Sessions(t *testing.T) {
+	var err error
+	udb1, err := EmptyUserDB("test_files/userdb_test_sessions")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, u := range []string{"angela", "james", "carole"} {
+		err = udb1.InsertUser(u, u+"s-secret")
+		if err != nil {
+			t.Errorf("Fail: %v", err)
+		}
+	}
+	before := time.Now()
+	err = udb1.RevokeSessions("james")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.RevokeSessions("nobody")
+	if err == nil {
+		t.Errorf("Fail: expected error here")
+	}
+	err = udb1.RenameUser("carole", "caroline")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.DeleteUser("angela")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.InsertUser("angela", "angelas-new-secret")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	after := time.Now()
+
+	udb2, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb2.SaveFile()
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb3, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	for _, udb := range []*UserDB{udb1, udb2, udb3} {
+		for _, test := range []struct {
+			user    string
+			created time.Time
+			exp     bool
+		}{
+			{"james", before, false},
+			{"james", after, true},
+			{"caroline", before, false},
+			{"caroline", after, true},
+			{"carole", after, false},
+			// sessions of the deleted user are not valid for the new user with the same name
+			{"angela", before, false},
+			{"angela", after, true},
+		} {
+			if w, g := test.exp, udb.SessionValid(test.user, test.created); w != g {
+				t.Errorf("%s: "+fs, test.user, w, g)
+			}
+		}
+	}
+}
+
+func Test_UserDB_PasswordPolicy(t *testing.T) {
+	var err error
+	udb1, err := EmptyUserDB("test_files/userdb_test_password_policy")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb1.PasswordPolicy = PasswordPolicy{MaxAge: time.Hour, HistorySize: 2}
+
+	err = udb1.InsertUser("angela", "secret1")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if required, _ := udb1.PasswordChangeRequired("angela"); required {
+		t.Errorf("expected no password change required")
+	}
+
+	// reuse current password
+	err = udb1.UpdatePassword("angela", "secret1")
+	if !errors.Is(err, ErrPasswordReused) {
+		t.Errorf(fs, ErrPasswordReused, err)
+	}
+	err = udb1.UpdatePassword("angela", "secret2")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	err = udb1.UpdatePassword("angela", "secret1")
+	if !errors.Is(err, ErrPasswordReused) {
+		t.Errorf(fs, ErrPasswordReused, err)
+	}
+	err = udb1.UpdatePassword("angela", "secret3")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	// secret1 is no longer among the last two passwords
+	err = udb1.UpdatePassword("angela", "secret1")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	err = udb1.RequirePasswordChange("angela")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+
+	udb2, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb2.PasswordPolicy = udb1.PasswordPolicy
+	for _, udb := range []*UserDB{udb1, udb2} {
+		required, reason := udb.PasswordChangeRequired("angela")
+		if w, g := true, required; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := "password change required", reason; w != g {
+			t.Errorf(fs, w, g)
+		}
+		if w, g := 3, len(udb.passwords["angela"].history); w != g {
+			t.Errorf(fs, w, g)
+		}
+	}
+	err = udb2.UpdatePassword("angela", "secret3")
+	if !errors.Is(err, ErrPasswordReused) {
+		t.Errorf(fs, ErrPasswordReused, err)
+	}
+	err = udb2.UpdatePassword("angela", "secret4")
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if required, _ := udb2.PasswordChangeRequired("angela"); required {
+		t.Errorf("expected no password change required")
+	}
+
+	// compacted file
+	err = udb2.SaveFile()
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	udb3, err := ReadUserDB(udb1.fileName)
+	if err != nil {
+		t.Errorf("Fail: %v", err)
+	}
+	if w, g := 4, len(udb3.passwords["angela"].history); w != g {
+		t.Errorf(fs, w, g)
+	}
+
+	// expired password
+	udb3.PasswordPolicy.MaxAge = time.Nanosecond
+	required, reason := udb3.PasswordChangeRequired("angela")
+	if w, g := true, required; w != g {
+		t.Errorf(fs, w, g)
+	}
+	if w, g := "password expired", reason; w != g {
+		t.Errorf(fs, w, g)
+	}
+}
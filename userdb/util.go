@@ -31,6 +31,17 @@ func normaliseField(field string) string {
 	return strings.TrimSpace(strings.ToLower(field))
 }
 
+// splitItems splits a list of items (see ItemSeparator), ignoring empty items
+func splitItems(s string) []string {
+	res := []string{}
+	for _, item := range strings.Split(s, ItemSeparator) {
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
 func contains(slice []string, value string) bool {
 	for _, s := range slice {
 		if s == value {
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// ReadLinesFS reads a file in fsys into a slice of lines (see ReadLines)
+func ReadLinesFS(fsys fs.FS, fileName string) ([]string, error) {
+	fh, err := fsys.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' : %v", fileName, err)
+	}
+	defer fh.Close()
+	return readLines(fh, fileName)
+}
+
+// FileExistsFS returns true if the file exists in fsys (without checking what type it is)
+func FileExistsFS(fsys fs.FS, fileName string) bool {
+	if _, err := fs.Stat(fsys, fileName); errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	return true
+}
+
+// OverlayFS is a file system made up of layers, where files in the upper layers override files with the same name in the lower layers. It can be used to let files on disk override defaults embedded in the binary:
+//
+//	//go:embed i18n
+//	var defaults embed.FS
+//	...
+//	fsys := util.NewOverlayFS(os.DirFS("."), defaults)
+//
+// The entries of a folder are merged from all layers. Files can't be removed by an upper layer.
+type OverlayFS struct {
+	// layers, topmost first
+	layers []fs.FS
+}
+
+// NewOverlayFS returns an overlay of the layers, topmost (overriding) layer first
+func NewOverlayFS(layers ...fs.FS) *OverlayFS {
+	return &OverlayFS{layers: layers}
+}
+
+// Open opens the named file in the topmost layer where it exists
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, l := range o.layers {
+		f, err := l.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat returns the file info of the named file in the topmost layer where it exists
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, l := range o.layers {
+		info, err := fs.Stat(l, name)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the entries of the named folder in all layers where it exists, sorted by file name. For entries in several layers, the entry of the topmost layer is used.
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	seen := make(map[string]bool)
+	res := []fs.DirEntry{}
+	found := false
+	for _, l := range o.layers {
+		entries, err := fs.ReadDir(l, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				res = append(res, e)
+				seen[e.Name()] = true
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"compress/gzip"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -65,21 +66,26 @@ func GetServerURL(r *http.Request) string {
 
 //ReadLines read a file into a slice of lines
 func ReadLines(fileName string) ([]string, error) {
-	var res []string
-	var scanner *bufio.Scanner
 	fh, err := os.Open(fileName)
 	if err != nil {
-		return res, fmt.Errorf("failed to read '%s' : %v", fileName, err)
+		return nil, fmt.Errorf("failed to read '%s' : %v", fileName, err)
 	}
+	defer fh.Close()
+	return readLines(fh, fileName)
+}
 
+// readLines reads lines from r (gzipped, if fileName ends with .gz)
+func readLines(r io.Reader, fileName string) ([]string, error) {
+	var res []string
+	var scanner *bufio.Scanner
 	if strings.HasSuffix(fileName, ".gz") {
-		gz, err := gzip.NewReader(fh)
+		gz, err := gzip.NewReader(r)
 		if err != nil {
 			return res, fmt.Errorf("failed to read '%s' : %v", fileName, err)
 		}
 		scanner = bufio.NewScanner(gz)
 	} else {
-		scanner = bufio.NewScanner(fh)
+		scanner = bufio.NewScanner(r)
 	}
 	for scanner.Scan() {
 		res = append(res, scanner.Text())